@@ -1,60 +1,303 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"time"
+	_ "time/tzdata" // Fusos de RATE_LIMIT_QUOTA_TIMEZONE mesmo em imagens sem tzdata
 
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/access"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/admin"
 	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/config"
 	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/limiter"
 	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/middleware"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/reload"
 	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/storage"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/tokens"
 	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
 )
 
 func main() {
 	// 1. Carrega configurações do .env
 	cfg := config.LoadConfig()
 
-	// 2. Conecta ao Redis
-	redisClient, err := storage.NewRedisClient(cfg)
+	// Valida o algoritmo antes de aceitar requisições
+	algorithm, err := limiter.ParseAlgorithm(cfg.RateLimitAlgorithm)
 	if err != nil {
-		log.Fatalf("Erro ao conectar Redis: %v", err)
+		log.Fatalf("Configuração inválida: %v", err)
 	}
-	defer redisClient.Close() // Fecha conexão ao terminar
+	cfg.RateLimitAlgorithm = string(algorithm)
+	if err := middleware.ValidateRates(cfg); err != nil {
+		log.Fatalf("Configuração inválida: %v", err)
+	}
+
+	for _, quotas := range []string{cfg.RateLimitIPQuotas, cfg.RateLimitTokenQuotas} {
+		if _, err := limiter.ParseQuotas(quotas); err != nil {
+			log.Fatalf("Configuração inválida: %v", err)
+		}
+	}
+	if _, err := middleware.ParseInvalidTokenPolicy(cfg.RateLimitInvalidTokenPolicy); err != nil {
+		log.Fatalf("Configuração inválida: %v", err)
+	}
+	trustedProxies, err := middleware.ParseTrustedProxies(cfg.RateLimitTrustedProxies)
+	if err != nil {
+		log.Fatalf("Configuração inválida: %v", err)
+	}
+	if _, err := middleware.ParseProxyHeader(cfg.RateLimitClientIPHeader); err != nil {
+		log.Fatalf("Configuração inválida: %v", err)
+	}
+	if _, err := middleware.NewIPPrefixes(cfg.RateLimitIPv4Prefix, cfg.RateLimitIPv6Prefix); err != nil {
+		log.Fatalf("Configuração inválida: %v", err)
+	}
+	composite, err := middleware.ParseCompositeLimits(cfg)
+	if err != nil {
+		log.Fatalf("Configuração inválida: %v", err)
+	}
+	keyExtractor, err := middleware.ParseKeyExtractor(cfg.RateLimitKey)
+	if err != nil {
+		log.Fatalf("Configuração inválida: %v", err)
+	}
+	quotaLocation, err := time.LoadLocation(cfg.RateLimitQuotaTimezone)
+	if err != nil {
+		log.Fatalf("Configuração inválida: RATE_LIMIT_QUOTA_TIMEZONE: %v", err)
+	}
+	onError, err := limiter.ParseFailureMode(cfg.RateLimitOnError)
+	if err != nil {
+		log.Fatalf("Configuração inválida: RATE_LIMIT_ON_ERROR: %v", err)
+	}
+
+	// 2. Cria o storage configurado (Redis ou memória)
+	strategy, redisClient, closeStorage, err := newStorage(cfg)
+	if err != nil {
+		log.Fatalf("Erro ao inicializar storage: %v", err)
+	}
+	defer closeStorage() // Libera conexões/goroutines ao terminar
 
-	// 3. Cria strategy e rate limiter
-	redisStrategy := limiter.NewRedisStrategy(redisClient)
-	rateLimiter := limiter.NewRateLimiter(redisStrategy)
+	// HMAC dos tokens nas chaves do Redis (chave padrão sem RATE_LIMIT_TOKEN_HASH_SECRET)
+	hasher := tokens.NewHasher(cfg.RateLimitTokenHashSecret)
+
+	// Regras e planos declarativos: qualquer erro nos arquivos impede a inicialização
+	limits, err := middleware.LoadLimits(cfg, redisClient, hasher)
+	if err != nil {
+		log.Fatalf("Configuração inválida: %v", err)
+	}
+
+	// 3. Cria rate limiter
+	limiterOpts := []limiter.Option{limiter.WithQuotaLocation(quotaLocation)}
+	var breaker *limiter.CircuitBreaker
+	if cfg.RateLimitBreakerThreshold > 0 {
+		// Redis fora do ar: falha na hora em vez de esperar o timeout a cada requisição
+		// O mesmo breaker vale para concorrência, cadastro de tokens e planos
+		breaker = limiter.NewCircuitBreaker(cfg.RateLimitBreakerThreshold, cfg.RateLimitBreakerCooldown)
+		limiterOpts = append(limiterOpts, limiter.WithCircuitBreaker(breaker))
+	}
+	rateLimiter := limiter.NewRateLimiter(strategy, limiterOpts...)
 
 	// 4. Cria middleware
-	rateLimiterMiddleware := middleware.NewRateLimiterMiddleware(rateLimiter, cfg)
+	middlewareOpts := []middleware.Option{middleware.WithTokenHasher(hasher), middleware.WithCircuitBreaker(breaker)}
+	if concurrencyStorage, ok := strategy.(limiter.ConcurrencyStorage); ok {
+		// Ativo sempre que suportado: regras do arquivo também podem limitar concorrência
+		concurrencyLimiter := limiter.NewConcurrencyLimiter(concurrencyStorage, cfg.RateLimitLeaseTTL, limiter.WithLeaseBreaker(breaker))
+		middlewareOpts = append(middlewareOpts, middleware.WithConcurrencyLimiter(concurrencyLimiter))
+	} else if cfg.RateLimitIPConcurrency > 0 || cfg.RateLimitTokenConcurrency > 0 {
+		log.Fatalf("Configuração inválida: STORAGE_DRIVER %q não suporta limite de concorrência", cfg.StorageDriver)
+	}
+	middlewareOpts = append(middlewareOpts, middleware.WithLimits(limits))
+	if keyExtractor != nil {
+		middlewareOpts = append(middlewareOpts, middleware.WithKeyExtractor(keyExtractor))
+	}
+
+	var tokenStore *tokens.Store
+	if cfg.RateLimitTokenStore {
+		if redisClient == nil {
+			log.Fatalf("Configuração inválida: RATE_LIMIT_TOKEN_STORE requer STORAGE_DRIVER=redis")
+		}
+		tokenStore = tokens.NewStore(redisClient, tokens.WithStoreHasher(hasher))
+		middlewareOpts = append(middlewareOpts, middleware.WithTokenStore(tokenStore))
+	}
+
+	// Listas allow/deny alteráveis pela API (no Redis, compartilhadas entre instâncias)
+	var accessStore *access.Store
+	if cfg.AdminAPIKey != "" {
+		accessStore = access.NewStore(redisClient)
+		if err := accessStore.Refresh(context.Background()); err != nil {
+			log.Fatalf("Erro ao carregar listas de acesso: %v", err)
+		}
+		accessStore.StartRefresh(cfg.RateLimitAccessRefresh)
+		defer accessStore.Close()
+		middlewareOpts = append(middlewareOpts, middleware.WithAccessStore(accessStore))
+	}
+	rateLimiterMiddleware := middleware.NewRateLimiterMiddleware(rateLimiter, cfg, middlewareOpts...)
+
+	// Hot reload: .env e arquivos de regras/planos/listas são relidos ao mudar ou com SIGHUP
+	watched := []string{config.File(), cfg.RateLimitRulesFile, cfg.RateLimitTokensFile, cfg.RateLimitAccessFile, cfg.RateLimitJWTJWKSFile}
+	watcher, err := reload.NewWatcher(watched, func(reason string) {
+		reloadLimits(rateLimiterMiddleware, redisClient, hasher, reason)
+	})
+	if err != nil {
+		log.Printf("Aviso: hot reload por arquivo desativado: %v", err)
+	} else {
+		defer watcher.Close()
+	}
 
 	// 5. Configura Gin router
 	router := gin.Default()
 
+	// Logs do Gin usam os mesmos proxies confiáveis do rate limiter (sem eles, o
+	// Gin confia em qualquer X-Forwarded-For)
+	proxies := make([]string, len(trustedProxies))
+	for i, prefix := range trustedProxies {
+		proxies[i] = prefix.String()
+	}
+	if err := router.SetTrustedProxies(proxies); err != nil {
+		log.Fatalf("Configuração inválida: RATE_LIMIT_TRUSTED_PROXIES: %v", err)
+	}
+
 	// Aplica middleware de rate limiting globalmente
 	router.Use(rateLimiterMiddleware.Middleware())
 
 	// 6. Define rotas de exemplo
 	setupRoutes(router)
 
+	// API de administração (só com ADMIN_API_KEY)
+	if cfg.AdminAPIKey != "" {
+		adminGroup := router.Group("/admin")
+		accessHandler := admin.NewAccessHandler(accessStore, func() *access.List {
+			return rateLimiterMiddleware.Limits().Access
+		}, hasher)
+		accessHandler.Register(adminGroup, cfg.AdminAPIKey)
+
+		if tokenStore != nil {
+			// Os planos válidos são os do arquivo em uso, inclusive após um reload
+			tokenHandler := admin.NewTokenHandler(tokenStore, func(plan string) bool {
+				_, ok := rateLimiterMiddleware.Limits().Tokens.Plan(plan)
+				return ok
+			}, hasher)
+			tokenHandler.Register(adminGroup, cfg.AdminAPIKey)
+		}
+	}
+
 	// 7. Inicia servidor
 	addr := fmt.Sprintf(":%s", cfg.ServerPort)
 	fmt.Printf("🚀 Servidor iniciando na porta %s\n", cfg.ServerPort)
-	fmt.Printf("📊 Rate Limit IP: %d req/s\n", cfg.RateLimitIPRPS)
+	fmt.Printf("📊 Rate Limit IP: %d req/s (por /%d IPv4, /%d IPv6)\n", cfg.RateLimitIPRPS, cfg.RateLimitIPv4Prefix, cfg.RateLimitIPv6Prefix)
 	fmt.Printf("🔑 Rate Limit Token: %d req/s\n", cfg.RateLimitTokenRPS)
+	fmt.Printf("🧮 Algoritmo: %s\n", cfg.RateLimitAlgorithm)
+	if cfg.RateLimitIPQuotas != "" || cfg.RateLimitTokenQuotas != "" {
+		fmt.Printf("📅 Quotas: IP [%s] / Token [%s] (fuso %s)\n", cfg.RateLimitIPQuotas, cfg.RateLimitTokenQuotas, quotaLocation)
+	}
+	if cfg.RateLimitIPConcurrency > 0 || cfg.RateLimitTokenConcurrency > 0 {
+		fmt.Printf("🚦 Concorrência máxima: IP %d / Token %d (0 = sem limite)\n", cfg.RateLimitIPConcurrency, cfg.RateLimitTokenConcurrency)
+	}
+
+	if keyExtractor != nil {
+		fmt.Printf("🏷️  Chave do limite: %s\n", cfg.RateLimitKey)
+	}
+	if len(composite) > 0 {
+		fmt.Printf("🧷 Limites compostos com o token: %s\n", cfg.RateLimitComposite)
+	}
+	fmt.Printf("🧯 Storage indisponível: fail-%s (regras podem sobrepor com on_error)\n", onError)
+	if cfg.RateLimitBreakerThreshold > 0 {
+		fmt.Printf("🔌 Circuit breaker: abre após %d falhas seguidas, por %s\n", cfg.RateLimitBreakerThreshold, cfg.RateLimitBreakerCooldown)
+	}
+	if cfg.RateLimitGlobalRPS > 0 {
+		fmt.Printf("🌐 Limite global: %d req/s (todos os clientes e instâncias)\n", cfg.RateLimitGlobalRPS)
+	}
+	if cfg.RateLimitRulesFile != "" {
+		fmt.Printf("📜 Regras: %s\n", cfg.RateLimitRulesFile)
+	}
+	if cfg.RateLimitTokensFile != "" {
+		fmt.Printf("🎟️  Planos por token: %s\n", cfg.RateLimitTokensFile)
+	}
+	if tokenStore != nil {
+		fmt.Printf("🔐 Cadastro de tokens ativo (tokens inválidos: %s)\n", cfg.RateLimitInvalidTokenPolicy)
+	}
+	if cfg.RateLimitAccessFile != "" {
+		fmt.Printf("🚧 Listas de acesso: %s\n", cfg.RateLimitAccessFile)
+	}
+	if limits.JWT != nil {
+		fmt.Printf("🪪 Bearer JWT: %s (chave pelo claim %s)\n", cfg.RateLimitJWTJWKSFile, cfg.RateLimitJWTKeyClaim)
+	}
+	if len(trustedProxies) > 0 {
+		fmt.Printf("🛡️  Proxies confiáveis: %s (header %s)\n", cfg.RateLimitTrustedProxies, cfg.RateLimitClientIPHeader)
+	}
+	if cfg.RateLimitTokenHashSecret != "" {
+		fmt.Printf("🧂 Tokens guardados no Redis como HMAC\n")
+	} else {
+		fmt.Printf("🧂 Tokens guardados no Redis como HMAC da chave padrão (defina RATE_LIMIT_TOKEN_HASH_SECRET em produção)\n")
+	}
 
 	if err := router.Run(addr); err != nil {
 		log.Fatalf("Erro ao iniciar servidor: %v", err)
 	}
 }
 
+// reloadLimits relê a configuração, as regras e os planos e aplica no middleware
+// Qualquer erro mantém os limites atuais
+func reloadLimits(rlm *middleware.RateLimiterMiddleware, redisClient redis.UniversalClient, hasher *tokens.Hasher, reason string) {
+	cfg, err := config.Reload()
+	if err != nil {
+		log.Printf("♻️  Reload (%s) rejeitado, mantendo os limites atuais: %v", reason, err)
+		return
+	}
+
+	limits, err := middleware.LoadLimits(cfg, redisClient, hasher)
+	if err != nil {
+		log.Printf("♻️  Reload (%s) rejeitado, mantendo os limites atuais: %v", reason, err)
+		return
+	}
+
+	changes, err := rlm.Reload(limits)
+	if err != nil {
+		log.Printf("♻️  Reload (%s) rejeitado, mantendo os limites atuais: %v", reason, err)
+		return
+	}
+
+	if len(changes) == 0 {
+		log.Printf("♻️  Reload (%s): nenhuma mudança", reason)
+		return
+	}
+	log.Printf("♻️  Reload (%s) aplicado:", reason)
+	for _, change := range changes {
+		log.Printf("   - %s", change)
+	}
+}
+
+// newStorage cria a StorageStrategy de acordo com STORAGE_DRIVER
+// O cliente Redis é nil no storage em memória
+func newStorage(cfg *config.Config) (limiter.StorageStrategy, redis.UniversalClient, func(), error) {
+	switch cfg.StorageDriver {
+	case "memory":
+		memoryStrategy := limiter.NewMemoryStrategy(cfg.MemoryShards, cfg.MemoryCleanupInterval)
+		fmt.Println("🧠 Usando storage em memória (instância única)")
+		return memoryStrategy, nil, func() { memoryStrategy.Close() }, nil
+
+	case "redis", "":
+		redisClient, err := storage.NewRedisClient(cfg)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		redisStrategy := limiter.NewRedisStrategy(redisClient)
+		if err := redisStrategy.LoadScripts(context.Background()); err != nil {
+			// Não é fatal: os scripts são enviados via EVAL na primeira execução
+			log.Printf("Aviso: %v", err)
+		}
+		return redisStrategy, redisClient, func() { redisClient.Close() }, nil
+
+	default:
+		return nil, nil, nil, fmt.Errorf("STORAGE_DRIVER desconhecido: %q", cfg.StorageDriver)
+	}
+}
+
 func setupRoutes(router *gin.Engine) {
 	// Rota simples para teste
 	router.GET("/", func(c *gin.Context) {
 		c.JSON(200, gin.H{
 			"message": "Rate Limiter funcionando!",
-			"ip":      c.ClientIP(),
+			"ip":      c.GetString(middleware.ClientIPKey),
 			"token":   c.GetHeader("API_KEY"),
 		})
 	})
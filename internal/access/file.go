@@ -0,0 +1,91 @@
+package access
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/tokens"
+	"gopkg.in/yaml.v3"
+)
+
+/*
+	Arquivo das listas (YAML ou JSON):
+
+	  allow:
+	    ips: [10.0.0.0/8, 127.0.0.1]    # Health checks e rede interna
+	    tokens: [internal-monitoring]
+	  deny:
+	    ips: [203.0.113.0/24]
+	    tokens: [leaked-token-123]
+*/
+
+// File é o formato do arquivo de listas
+type File struct {
+	Allow ListSpec `yaml:"allow"`
+	Deny  ListSpec `yaml:"deny"`
+}
+
+// ListSpec são as entradas de uma lista como escritas no arquivo
+type ListSpec struct {
+	IPs    []string `yaml:"ips"`
+	Tokens []string `yaml:"tokens"`
+}
+
+// LoadFile lê e valida o arquivo de listas
+// Os tokens passam pelo hasher, como os tokens das requisições
+func LoadFile(path string, hasher *tokens.Hasher) (*List, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler arquivo de listas: %w", err)
+	}
+
+	list, err := Parse(data, hasher)
+	if err != nil {
+		return nil, fmt.Errorf("erro no arquivo de listas %s:\n%w", path, err)
+	}
+	return list, nil
+}
+
+// Parse valida o conteúdo de um arquivo de listas, acumulando todos os erros
+func Parse(data []byte, hasher *tokens.Hasher) (*List, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	var file File
+	if err := decoder.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	var (
+		entries []Entry
+		errs    []error
+	)
+	for _, list := range []struct {
+		action Action
+		spec   ListSpec
+	}{{Allow, file.Allow}, {Deny, file.Deny}} {
+		for i, ip := range list.spec.IPs {
+			prefix, err := ParsePrefix(ip)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s.ips[%d]: %w", list.action, i, err))
+				continue
+			}
+			entries = append(entries, Entry{Action: list.action, Type: EntryIP, Value: prefix.String(), Source: "file"})
+		}
+		for i, token := range list.spec.Tokens {
+			if token == "" {
+				errs = append(errs, fmt.Errorf("%s.tokens[%d]: token vazio", list.action, i))
+				continue
+			}
+			entries = append(entries, Entry{Action: list.action, Type: EntryToken, Value: hasher.Hash(token), Source: "file"})
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return NewList(entries)
+}
@@ -0,0 +1,263 @@
+package access
+
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+)
+
+/*
+	Listas de liberação (allow) e bloqueio (deny) por IP/CIDR e por token.
+
+	  allow → a requisição não passa pelo rate limiter (ex: health checks, rede interna)
+	  deny  → HTTP 403 antes de tocar nos contadores (ex: abusadores conhecidos)
+
+	Deny tem precedência sobre allow. Os CIDRs ficam agrupados por tamanho de
+	prefixo, então a busca custa um acesso a mapa por tamanho distinto em uso
+	(no máximo 33 para IPv4 e 129 para IPv6), independente do número de entradas.
+	Os tokens são guardados como o HMAC do Hasher (ou como estão, sem HMAC).
+*/
+
+// Action é o efeito de uma entrada
+type Action string
+
+const (
+	Allow Action = "allow"
+	Deny  Action = "deny"
+)
+
+// EntryType é o que a entrada identifica
+type EntryType string
+
+const (
+	EntryIP    EntryType = "ip"    // IP ou CIDR
+	EntryToken EntryType = "token" // Token de API (HMAC com hasher)
+)
+
+// Entry é uma entrada de uma das listas
+type Entry struct {
+	Action Action    `json:"action"`
+	Type   EntryType `json:"type"`
+	Value  string    `json:"value"` // CIDR normalizado (ex: 10.0.0.0/8) ou token
+	Reason string    `json:"reason,omitempty"`
+	Source string    `json:"source"` // "file" ou "api"
+}
+
+// ID identifica a entrada dentro das listas
+func (e Entry) ID() string {
+	return fmt.Sprintf("%s:%s:%s", e.Action, e.Type, e.Value)
+}
+
+// ParseAction valida o nome de uma lista
+func ParseAction(s string) (Action, error) {
+	switch action := Action(strings.ToLower(strings.TrimSpace(s))); action {
+	case Allow, Deny:
+		return action, nil
+	default:
+		return "", fmt.Errorf("lista inválida: %q (use allow ou deny)", s)
+	}
+}
+
+// ParsePrefix aceita IP ou CIDR; IPs viram /32 ou /128
+func ParsePrefix(s string) (netip.Prefix, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("CIDR inválido %q", s)
+		}
+		if prefix.Addr().Is4In6() {
+			return netip.Prefix{}, fmt.Errorf("CIDR inválido %q: use a notação IPv4", s)
+		}
+		return prefix.Masked(), nil
+	}
+
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("IP inválido %q", s)
+	}
+	addr = addr.Unmap().WithZone("")
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// List é um conjunto imutável de entradas, pronto para consulta
+// List nil não tem entradas
+type List struct {
+	entries []Entry
+	allow   matcher
+	deny    matcher
+}
+
+type matcher struct {
+	prefixes map[netip.Prefix]struct{}
+	bits     []int // Tamanhos de prefixo em uso, do mais longo para o mais curto
+	tokens   map[string]struct{}
+}
+
+// NewList valida e indexa as entradas
+func NewList(entries []Entry) (*List, error) {
+	l := &List{allow: newMatcher(), deny: newMatcher()}
+	for _, entry := range entries {
+		m := &l.allow
+		switch entry.Action {
+		case Allow:
+		case Deny:
+			m = &l.deny
+		default:
+			return nil, fmt.Errorf("lista inválida: %q", entry.Action)
+		}
+
+		switch entry.Type {
+		case EntryIP:
+			prefix, err := ParsePrefix(entry.Value)
+			if err != nil {
+				return nil, err
+			}
+			entry.Value = prefix.String()
+			m.addPrefix(prefix)
+		case EntryToken:
+			if entry.Value == "" {
+				return nil, fmt.Errorf("token vazio")
+			}
+			m.tokens[entry.Value] = struct{}{}
+		default:
+			return nil, fmt.Errorf("tipo inválido: %q (use ip ou token)", entry.Type)
+		}
+		l.entries = append(l.entries, entry)
+	}
+	return l, nil
+}
+
+func newMatcher() matcher {
+	return matcher{
+		prefixes: make(map[netip.Prefix]struct{}),
+		tokens:   make(map[string]struct{}),
+	}
+}
+
+func (m *matcher) addPrefix(prefix netip.Prefix) {
+	if _, ok := m.prefixes[prefix]; ok {
+		return
+	}
+	m.prefixes[prefix] = struct{}{}
+
+	for _, bits := range m.bits {
+		if bits == prefix.Bits() {
+			return
+		}
+	}
+	m.bits = append(m.bits, prefix.Bits())
+	sort.Sort(sort.Reverse(sort.IntSlice(m.bits)))
+}
+
+func (m *matcher) match(addr netip.Addr, hasAddr bool, token string) bool {
+	if token != "" {
+		if _, ok := m.tokens[token]; ok {
+			return true
+		}
+	}
+	if !hasAddr {
+		return false
+	}
+	for _, bits := range m.bits {
+		if bits > addr.BitLen() {
+			continue
+		}
+		prefix, err := addr.Prefix(bits)
+		if err != nil {
+			continue
+		}
+		if _, ok := m.prefixes[prefix]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Match retorna Deny, Allow ou "" (nenhuma entrada) para o IP e o token
+// O token deve vir do mesmo Hasher usado para criar as entradas
+func (l *List) Match(ip, token string) Action {
+	if l == nil {
+		return ""
+	}
+
+	addr, err := netip.ParseAddr(ip)
+	hasAddr := err == nil
+	if hasAddr {
+		addr = addr.Unmap().WithZone("")
+	}
+
+	if l.deny.match(addr, hasAddr, token) {
+		return Deny
+	}
+	if l.allow.match(addr, hasAddr, token) {
+		return Allow
+	}
+	return ""
+}
+
+// Entries retorna as entradas da lista
+func (l *List) Entries() []Entry {
+	if l == nil {
+		return nil
+	}
+	return append([]Entry(nil), l.entries...)
+}
+
+// Combine junta o resultado de várias listas: deny em qualquer uma vence
+func Combine(actions ...Action) Action {
+	var result Action
+	for _, action := range actions {
+		switch action {
+		case Deny:
+			return Deny
+		case Allow:
+			result = Allow
+		}
+	}
+	return result
+}
+
+// Diff descreve as entradas adicionadas e removidas entre duas listas
+func Diff(old, updated *List) []string {
+	oldIDs, newIDs := ids(old), ids(updated)
+
+	var changes []string
+	for _, entry := range updated.Entries() {
+		if !oldIDs[entry.ID()] {
+			changes = append(changes, fmt.Sprintf("%s %s %s adicionado", entry.Action, entry.Type, entry.display()))
+		}
+	}
+	for _, entry := range old.Entries() {
+		if !newIDs[entry.ID()] {
+			changes = append(changes, fmt.Sprintf("%s %s %s removido", entry.Action, entry.Type, entry.display()))
+		}
+	}
+	return changes
+}
+
+func ids(l *List) map[string]bool {
+	ids := make(map[string]bool)
+	for _, entry := range l.Entries() {
+		ids[entry.ID()] = true
+	}
+	return ids
+}
+
+// Masked retorna a entrada com só o início do token, para exibir sem HMAC
+func (e Entry) Masked() Entry {
+	e.Value = e.display()
+	return e
+}
+
+// display mostra só o início dos tokens em logs
+func (e Entry) display() string {
+	if e.Type != EntryToken {
+		return e.Value
+	}
+	if len(e.Value) <= 4 {
+		return "****"
+	}
+	return e.Value[:4] + "****"
+}
@@ -0,0 +1,188 @@
+package access
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+/*
+	Entradas adicionadas em tempo de execução (API /admin/access).
+
+	Com Redis, ficam no hash abaixo (campo = ID da entrada, valor = JSON) e
+	cada instância relê o hash periodicamente, então uma entrada adicionada em
+	uma instância vale em todas após o próximo refresh. Sem Redis (storage em
+	memória, instância única), ficam só na memória do processo.
+
+	  accesslist:{access}:entries
+*/
+
+const storeKey = "accesslist:{access}:entries"
+
+var ErrEntryNotFound = errors.New("entrada não encontrada")
+
+// Store guarda as entradas adicionadas pela API de administração
+type Store struct {
+	client redis.UniversalClient // nil = só em memória
+
+	mu     sync.Mutex
+	memory map[string]Entry
+
+	list atomic.Pointer[List] // Snapshot consultado pelo middleware
+	stop chan struct{}
+	once sync.Once
+}
+
+// NewStore cria o Store; client nil guarda as entradas só em memória
+func NewStore(client redis.UniversalClient) *Store {
+	s := &Store{
+		client: client,
+		memory: make(map[string]Entry),
+		stop:   make(chan struct{}),
+	}
+	s.list.Store(&List{allow: newMatcher(), deny: newMatcher()})
+	return s
+}
+
+// List retorna as entradas em vigor (nil-safe)
+func (s *Store) List() *List {
+	if s == nil {
+		return nil
+	}
+	return s.list.Load()
+}
+
+// Add valida e grava uma entrada; tokens devem chegar já passados pelo Hasher
+func (s *Store) Add(ctx context.Context, entry Entry) (Entry, error) {
+	entry.Source = "api"
+	validated, err := NewList([]Entry{entry})
+	if err != nil {
+		return Entry{}, err
+	}
+	entry = validated.entries[0] // CIDR normalizado
+
+	if s.client == nil {
+		s.mu.Lock()
+		s.memory[entry.ID()] = entry
+		s.mu.Unlock()
+		return entry, s.Refresh(ctx)
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return Entry{}, err
+	}
+	if err := s.client.HSet(ctx, storeKey, entry.ID(), data).Err(); err != nil {
+		return Entry{}, fmt.Errorf("erro ao gravar entrada: %w", err)
+	}
+	return entry, s.Refresh(ctx)
+}
+
+// Remove apaga uma entrada adicionada pela API
+func (s *Store) Remove(ctx context.Context, entry Entry) error {
+	if entry.Type == EntryIP {
+		prefix, err := ParsePrefix(entry.Value)
+		if err != nil {
+			return err
+		}
+		entry.Value = prefix.String()
+	}
+
+	if s.client == nil {
+		s.mu.Lock()
+		_, ok := s.memory[entry.ID()]
+		delete(s.memory, entry.ID())
+		s.mu.Unlock()
+		if !ok {
+			return ErrEntryNotFound
+		}
+		return s.Refresh(ctx)
+	}
+
+	removed, err := s.client.HDel(ctx, storeKey, entry.ID()).Result()
+	if err != nil {
+		return fmt.Errorf("erro ao remover entrada: %w", err)
+	}
+	if removed == 0 {
+		return ErrEntryNotFound
+	}
+	return s.Refresh(ctx)
+}
+
+// Refresh relê as entradas e troca o snapshot
+// Em caso de erro, o snapshot atual continua valendo
+func (s *Store) Refresh(ctx context.Context) error {
+	entries, err := s.entries(ctx)
+	if err != nil {
+		return err
+	}
+	list, err := NewList(entries)
+	if err != nil {
+		return err
+	}
+	s.list.Store(list)
+	return nil
+}
+
+func (s *Store) entries(ctx context.Context) ([]Entry, error) {
+	if s.client == nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		entries := make([]Entry, 0, len(s.memory))
+		for _, entry := range s.memory {
+			entries = append(entries, entry)
+		}
+		return entries, nil
+	}
+
+	values, err := s.client.HGetAll(ctx, storeKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler listas: %w", err)
+	}
+
+	entries := make([]Entry, 0, len(values))
+	for id, value := range values {
+		var entry Entry
+		if err := json.Unmarshal([]byte(value), &entry); err != nil {
+			return nil, fmt.Errorf("erro ao ler entrada %s: %w", id, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// StartRefresh relê as entradas do Redis a cada intervalo, até o Close
+func (s *Store) StartRefresh(interval time.Duration) {
+	if s.client == nil || interval <= 0 {
+		return // Em memória, o snapshot já é atualizado a cada alteração
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.Refresh(context.Background()); err != nil {
+					log.Printf("Erro ao atualizar listas de acesso: %v", err)
+				}
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Close encerra o refresh periódico
+func (s *Store) Close() error {
+	s.once.Do(func() { close(s.stop) })
+	return nil
+}
@@ -0,0 +1,127 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/access"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/tokens"
+	"github.com/gin-gonic/gin"
+)
+
+/*
+	API das listas de acesso, protegida pelo header X-Admin-Key:
+
+	  GET    /admin/access                    → entradas do arquivo e da API
+	  POST   /admin/access/:list              → adiciona {"ip": "10.0.0.0/8"} ou {"token": "..."} em allow ou deny
+	  DELETE /admin/access/:list?ip=10.0.0.0/8 → remove uma entrada adicionada pela API (ou ?token=...)
+
+	Entradas do arquivo só mudam editando o arquivo (hot reload).
+*/
+
+// AccessHandler expõe as listas de acesso
+type AccessHandler struct {
+	store    *access.Store
+	fileList func() *access.List // Listas do arquivo em uso (mudam no reload)
+	hasher   *tokens.Hasher
+}
+
+// NewAccessHandler cria o handler; hasher deve ser o mesmo do middleware
+func NewAccessHandler(store *access.Store, fileList func() *access.List, hasher *tokens.Hasher) *AccessHandler {
+	return &AccessHandler{store: store, fileList: fileList, hasher: hasher}
+}
+
+// Register adiciona as rotas ao grupo, exigindo a chave de administração
+func (h *AccessHandler) Register(group *gin.RouterGroup, adminKey string) {
+	accessGroup := group.Group("/access", RequireAdminKey(adminKey))
+	accessGroup.GET("", h.list)
+	accessGroup.POST("/:list", h.add)
+	accessGroup.DELETE("/:list", h.remove)
+}
+
+type accessRequest struct {
+	IP     string `json:"ip"`
+	Token  string `json:"token"`
+	Reason string `json:"reason"`
+}
+
+func (h *AccessHandler) list(c *gin.Context) {
+	var entries []access.Entry
+	if h.fileList != nil {
+		entries = append(entries, h.fileList().Entries()...)
+	}
+	entries = append(entries, h.store.List().Entries()...)
+
+	// Sem HMAC, os tokens apareceriam inteiros
+	if !h.hasher.Enabled() {
+		for i := range entries {
+			entries[i] = entries[i].Masked()
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"entries": entries})
+}
+
+func (h *AccessHandler) add(c *gin.Context) {
+	var req accessRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entry, err := h.entry(c.Param("list"), req.IP, req.Token)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	entry.Reason = req.Reason
+
+	// Erros de validação já foram tratados acima; aqui só sobram erros do storage
+	entry, err = h.store.Add(c.Request.Context(), entry)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !h.hasher.Enabled() {
+		entry = entry.Masked()
+	}
+	c.JSON(http.StatusCreated, entry)
+}
+
+func (h *AccessHandler) remove(c *gin.Context) {
+	entry, err := h.entry(c.Param("list"), c.Query("ip"), c.Query("token"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = h.store.Remove(c.Request.Context(), entry)
+	switch {
+	case errors.Is(err, access.ErrEntryNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// entry monta e valida a entrada; exatamente um entre ip e token
+func (h *AccessHandler) entry(list, ip, token string) (access.Entry, error) {
+	action, err := access.ParseAction(list)
+	if err != nil {
+		return access.Entry{}, err
+	}
+
+	switch {
+	case ip != "" && token == "":
+		prefix, err := access.ParsePrefix(ip)
+		if err != nil {
+			return access.Entry{}, err
+		}
+		return access.Entry{Action: action, Type: access.EntryIP, Value: prefix.String()}, nil
+	case token != "" && ip == "":
+		return access.Entry{Action: action, Type: access.EntryToken, Value: h.hasher.Hash(token)}, nil
+	default:
+		return access.Entry{}, errors.New("informe ip ou token")
+	}
+}
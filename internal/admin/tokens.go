@@ -0,0 +1,236 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/tokens"
+	"github.com/gin-gonic/gin"
+)
+
+/*
+	API de administração dos tokens, protegida pelo header X-Admin-Key:
+
+	  POST   /admin/tokens       → cria um token (o segredo só aparece nesta resposta)
+	  GET    /admin/tokens       → lista os tokens, sem os segredos
+	  GET    /admin/tokens/:id   → detalhes de um token
+	  PATCH  /admin/tokens/:id   → altera plano, dono ou expiração
+	  DELETE /admin/tokens/:id   → revoga o token
+	  POST   /admin/tokens/resolve → chave do limiter de um token (com HMAC, o
+	                                 token não aparece nas chaves do Redis)
+*/
+
+// PlanValidator informa se um plano existe (planos vêm do arquivo de tokens)
+type PlanValidator func(plan string) bool
+
+// TokenHandler expõe o cadastro de tokens
+type TokenHandler struct {
+	store     *tokens.Store
+	validPlan PlanValidator
+	hasher    *tokens.Hasher
+}
+
+// NewTokenHandler cria o handler; validPlan nil aceita qualquer plano
+// hasher deve ser o mesmo do middleware (nil = tokens sem HMAC)
+func NewTokenHandler(store *tokens.Store, validPlan PlanValidator, hasher *tokens.Hasher) *TokenHandler {
+	return &TokenHandler{store: store, validPlan: validPlan, hasher: hasher}
+}
+
+// Register adiciona as rotas ao grupo, exigindo a chave de administração
+func (h *TokenHandler) Register(group *gin.RouterGroup, adminKey string) {
+	tokensGroup := group.Group("/tokens", RequireAdminKey(adminKey))
+	tokensGroup.POST("", h.create)
+	tokensGroup.POST("/resolve", h.resolve)
+	tokensGroup.GET("", h.list)
+	tokensGroup.GET("/:id", h.get)
+	tokensGroup.PATCH("/:id", h.update)
+	tokensGroup.DELETE("/:id", h.revoke)
+}
+
+// RequireAdminKey rejeita requisições sem o header X-Admin-Key correto
+func RequireAdminKey(adminKey string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		provided := c.GetHeader("X-Admin-Key")
+		// Comparação em tempo constante, para não vazar a chave por timing
+		if adminKey == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(adminKey)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid admin key"})
+			return
+		}
+		c.Next()
+	}
+}
+
+type createRequest struct {
+	Plan      string     `json:"plan"`
+	Owner     string     `json:"owner" binding:"required"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	ExpiresIn string     `json:"expires_in"` // Alternativa a expires_at, ex: "720h"
+}
+
+type createResponse struct {
+	Token string `json:"token"`
+	*tokens.Record
+}
+
+type updateRequest struct {
+	Plan      *string    `json:"plan"`
+	Owner     *string    `json:"owner"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+func (h *TokenHandler) create(c *gin.Context) {
+	var req createRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.checkPlan(req.Plan); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	expiresAt := req.ExpiresAt
+	if req.ExpiresIn != "" {
+		duration, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || duration <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("expires_in inválido: %q", req.ExpiresIn)})
+			return
+		}
+		at := time.Now().Add(duration)
+		expiresAt = &at
+	}
+
+	secret, record, err := h.store.Create(c.Request.Context(), tokens.NewToken{
+		Plan:      req.Plan,
+		Owner:     req.Owner,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, createResponse{Token: secret, Record: record})
+}
+
+func (h *TokenHandler) list(c *gin.Context) {
+	records, err := h.store.List(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].CreatedAt.Before(records[j].CreatedAt)
+	})
+	c.JSON(http.StatusOK, gin.H{"tokens": records})
+}
+
+func (h *TokenHandler) get(c *gin.Context) {
+	record, err := h.store.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, record)
+}
+
+func (h *TokenHandler) update(c *gin.Context) {
+	var req updateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Plan != nil {
+		if err := h.checkPlan(*req.Plan); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	record, err := h.store.Update(c.Request.Context(), c.Param("id"), tokens.Update{
+		Plan:      req.Plan,
+		Owner:     req.Owner,
+		ExpiresAt: req.ExpiresAt,
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, record)
+}
+
+func (h *TokenHandler) revoke(c *gin.Context) {
+	record, err := h.store.Revoke(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, record)
+}
+
+type resolveRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type resolveResponse struct {
+	Key     string         `json:"key"`     // Identidade usada pelo limiter (ex: "token:<hmac>")
+	Pattern string         `json:"pattern"` // Padrão para SCAN de todas as chaves do token
+	Hashed  bool           `json:"hashed"`
+	Record  *tokens.Record `json:"record,omitempty"` // Se o token estiver cadastrado
+}
+
+// resolve traduz um token para as chaves do limiter, já que com HMAC não dá
+// para encontrá-las a partir do token. O token vai no corpo, não na URL, para
+// não aparecer em logs de acesso
+func (h *TokenHandler) resolve(c *gin.Context) {
+	var req resolveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// O padrão cobre todas as chaves que contêm a identidade do token:
+	// rate:{token:<hmac>}, rate:{token_ip:token:<hmac>:<ip>} (limites compostos)
+	// e rate:{<regra>:token:<hmac>} (regras sem limits próprios)
+	key := "token:" + h.hasher.Hash(req.Token)
+	response := resolveResponse{
+		Key:     key,
+		Pattern: fmt.Sprintf("*%s[}:]*", key),
+		Hashed:  h.hasher.Enabled(),
+	}
+
+	record, err := h.store.Authenticate(c.Request.Context(), req.Token)
+	switch {
+	case record != nil:
+		response.Record = record // Inclusive revogados e expirados
+	case err != nil && !errors.Is(err, tokens.ErrTokenNotFound):
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// checkPlan aceita plano vazio (limite padrão de token) ou um plano existente
+func (h *TokenHandler) checkPlan(plan string) error {
+	if plan == "" || h.validPlan == nil || h.validPlan(plan) {
+		return nil
+	}
+	return fmt.Errorf("plano %q não existe", plan)
+}
+
+func respondError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, tokens.ErrTokenNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, tokens.ErrTokenRevoked):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -1,7 +1,11 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"io/fs"
 	"log"
+	"reflect"
 	"time"
 
 	"github.com/spf13/viper"
@@ -13,6 +17,83 @@ type Config struct {
 	RateLimitIPBlockTime    time.Duration `mapstructure:"RATE_LIMIT_IP_BLOCK_TIME"`
 	RateLimitTokenRPS       int           `mapstructure:"RATE_LIMIT_TOKEN_RPS"`
 	RateLimitTokenBlockTime time.Duration `mapstructure:"RATE_LIMIT_TOKEN_BLOCK_TIME"`
+	RateLimitAlgorithm      string        `mapstructure:"RATE_LIMIT_ALGORITHM"`
+	RateLimitIPBurst        int           `mapstructure:"RATE_LIMIT_IP_BURST"`
+	RateLimitTokenBurst     int           `mapstructure:"RATE_LIMIT_TOKEN_BURST"`
+	RateLimitMaxWait        time.Duration `mapstructure:"RATE_LIMIT_MAX_WAIT"`
+
+	// Fila: acima do limite, a requisição aguarda a reposição (até RATE_LIMIT_MAX_WAIT)
+	// em vez de receber 429, com qualquer algoritmo; regras podem ativar com queue
+	RateLimitQueue bool `mapstructure:"RATE_LIMIT_QUEUE"`
+
+	// Requisições simultâneas (0 = sem limite)
+	RateLimitIPConcurrency    int           `mapstructure:"RATE_LIMIT_IP_CONCURRENCY"`
+	RateLimitTokenConcurrency int           `mapstructure:"RATE_LIMIT_TOKEN_CONCURRENCY"`
+	RateLimitLeaseTTL         time.Duration `mapstructure:"RATE_LIMIT_LEASE_TTL"` // Validade da vaga se a instância cair sem liberá-la
+
+	// Quotas de longo prazo (ex: "100000/day,5000/hour"), alinhados ao calendário
+	RateLimitIPQuotas      string `mapstructure:"RATE_LIMIT_IP_QUOTAS"`
+	RateLimitTokenQuotas   string `mapstructure:"RATE_LIMIT_TOKEN_QUOTAS"`
+	RateLimitQuotaTimezone string `mapstructure:"RATE_LIMIT_QUOTA_TIMEZONE"` // Fuso em que as janelas viram (ex: America/Sao_Paulo)
+
+	// Limites compostos: quem tem token também passa por estes limites (ex: "ip,token_ip")
+	RateLimitComposite        string        `mapstructure:"RATE_LIMIT_COMPOSITE"`
+	RateLimitTokenIPRPS       int           `mapstructure:"RATE_LIMIT_TOKEN_IP_RPS"` // Limite de cada par token + IP
+	RateLimitTokenIPBlockTime time.Duration `mapstructure:"RATE_LIMIT_TOKEN_IP_BLOCK_TIME"`
+
+	// Limite agregado de todos os clientes, compartilhado entre as instâncias (0 = sem limite)
+	RateLimitGlobalRPS   int `mapstructure:"RATE_LIMIT_GLOBAL_RPS"`
+	RateLimitGlobalBurst int `mapstructure:"RATE_LIMIT_GLOBAL_BURST"`
+
+	// Storage fora do ar: open (deixa passar) ou closed (503); regras podem sobrepor com on_error
+	RateLimitOnError string `mapstructure:"RATE_LIMIT_ON_ERROR"`
+
+	// Circuit breaker: falhas seguidas até parar de consultar o storage (0 = desativado)
+	RateLimitBreakerThreshold int           `mapstructure:"RATE_LIMIT_BREAKER_THRESHOLD"`
+	RateLimitBreakerCooldown  time.Duration `mapstructure:"RATE_LIMIT_BREAKER_COOLDOWN"` // Tempo aberto antes de testar o storage de novo
+
+	// Arquivo de regras por rota/identidade (YAML ou JSON); vazio = só os limites acima
+	RateLimitRulesFile string `mapstructure:"RATE_LIMIT_RULES_FILE"`
+
+	// Planos por token (YAML ou JSON) e hash opcional do Redis com as associações token → plano
+	RateLimitTokensFile      string `mapstructure:"RATE_LIMIT_TOKENS_FILE"`
+	RateLimitTokensRedisHash string `mapstructure:"RATE_LIMIT_TOKENS_REDIS_HASH"`
+
+	// Cadastro de tokens no Redis (criados pela API /admin/tokens)
+	RateLimitTokenStore         bool   `mapstructure:"RATE_LIMIT_TOKEN_STORE"`
+	RateLimitInvalidTokenPolicy string `mapstructure:"RATE_LIMIT_INVALID_TOKEN_POLICY"` // allow | ip | reject
+	AdminAPIKey                 string `mapstructure:"ADMIN_API_KEY"`                   // Vazio = API de administração desativada
+
+	// Segredo do HMAC aplicado aos tokens nas chaves do Redis (vazio = token em texto puro)
+	RateLimitTokenHashSecret string `mapstructure:"RATE_LIMIT_TOKEN_HASH_SECRET"`
+
+	// Proxies confiáveis (CIDRs ou IPs); headers de IP de outras origens são ignorados
+	RateLimitTrustedProxies string `mapstructure:"RATE_LIMIT_TRUSTED_PROXIES"`
+	RateLimitClientIPHeader string `mapstructure:"RATE_LIMIT_CLIENT_IP_HEADER"` // X-Forwarded-For | Forwarded | X-Real-IP
+
+	// Tamanho do prefixo que divide o mesmo limite de IP (0 = endereço completo)
+	RateLimitIPv4Prefix int `mapstructure:"RATE_LIMIT_IPV4_PREFIX"`
+	RateLimitIPv6Prefix int `mapstructure:"RATE_LIMIT_IPV6_PREFIX"`
+
+	// Listas allow/deny por IP/CIDR e token (YAML ou JSON) e intervalo de releitura
+	// das entradas alteradas pela API /admin/access
+	RateLimitAccessFile    string        `mapstructure:"RATE_LIMIT_ACCESS_FILE"`
+	RateLimitAccessRefresh time.Duration `mapstructure:"RATE_LIMIT_ACCESS_REFRESH"`
+
+	// Bearer JWT: chaves de verificação (JWKS local) e claims da chave e do plano
+	RateLimitJWTJWKSFile  string `mapstructure:"RATE_LIMIT_JWT_JWKS_FILE"`
+	RateLimitJWTKeyClaim  string `mapstructure:"RATE_LIMIT_JWT_KEY_CLAIM"`  // ex: sub ou tenant
+	RateLimitJWTPlanClaim string `mapstructure:"RATE_LIMIT_JWT_PLAN_CLAIM"` // Vazio = limite de token
+	RateLimitJWTIssuer    string `mapstructure:"RATE_LIMIT_JWT_ISSUER"`     // Vazio = qualquer iss
+	RateLimitJWTAudience  string `mapstructure:"RATE_LIMIT_JWT_AUDIENCE"`   // Vazio = qualquer aud
+
+	// Chave própria no lugar da padrão, com o mesmo limite (ex: "header:X-Tenant-ID,route")
+	RateLimitKey string `mapstructure:"RATE_LIMIT_KEY"`
+
+	// Storage: "redis" (padrão) ou "memory" (instância única, sem Redis)
+	StorageDriver         string        `mapstructure:"STORAGE_DRIVER"`
+	MemoryShards          int           `mapstructure:"MEMORY_SHARDS"`
+	MemoryCleanupInterval time.Duration `mapstructure:"MEMORY_CLEANUP_INTERVAL"`
 
 	// Redis
 	RedisHost     string `mapstructure:"REDIS_HOST"`
@@ -20,6 +101,12 @@ type Config struct {
 	RedisPassword string `mapstructure:"REDIS_PASSWORD"`
 	RedisDB       int    `mapstructure:"REDIS_DB"`
 
+	// Redis Sentinel/Cluster: REDIS_MODE = single | sentinel | cluster
+	RedisMode             string `mapstructure:"REDIS_MODE"`
+	RedisAddrs            string `mapstructure:"REDIS_ADDRS"` // Nós do cluster ou sentinels (host:porta,host:porta)
+	RedisMasterName       string `mapstructure:"REDIS_MASTER_NAME"`
+	RedisSentinelPassword string `mapstructure:"REDIS_SENTINEL_PASSWORD"`
+
 	// Server
 	ServerPort string `mapstructure:"SERVER_PORT"`
 }
@@ -42,15 +129,121 @@ func LoadConfig() *Config {
 	viper.SetDefault("RATE_LIMIT_IP_BLOCK_TIME", "300s")
 	viper.SetDefault("RATE_LIMIT_TOKEN_RPS", 100)
 	viper.SetDefault("RATE_LIMIT_TOKEN_BLOCK_TIME", "600s")
+	viper.SetDefault("RATE_LIMIT_ALGORITHM", "fixed_window")
+	viper.SetDefault("RATE_LIMIT_IP_BURST", 0)
+	viper.SetDefault("RATE_LIMIT_TOKEN_BURST", 0)
+	viper.SetDefault("RATE_LIMIT_MAX_WAIT", "1s")
+	viper.SetDefault("RATE_LIMIT_QUEUE", false)
+	viper.SetDefault("RATE_LIMIT_IP_CONCURRENCY", 0)
+	viper.SetDefault("RATE_LIMIT_TOKEN_CONCURRENCY", 0)
+	viper.SetDefault("RATE_LIMIT_LEASE_TTL", "30s")
+	viper.SetDefault("RATE_LIMIT_TOKEN_IP_BLOCK_TIME", "60s")
+	viper.SetDefault("RATE_LIMIT_ON_ERROR", "open")
+	viper.SetDefault("RATE_LIMIT_BREAKER_THRESHOLD", 5)
+	viper.SetDefault("RATE_LIMIT_BREAKER_COOLDOWN", "10s")
+	viper.SetDefault("RATE_LIMIT_QUOTA_TIMEZONE", "UTC")
+	viper.SetDefault("RATE_LIMIT_INVALID_TOKEN_POLICY", "allow")
+	viper.SetDefault("RATE_LIMIT_CLIENT_IP_HEADER", "X-Forwarded-For")
+	viper.SetDefault("RATE_LIMIT_IPV4_PREFIX", 32)
+	viper.SetDefault("RATE_LIMIT_IPV6_PREFIX", 64)
+	viper.SetDefault("RATE_LIMIT_ACCESS_REFRESH", "5s")
+	viper.SetDefault("RATE_LIMIT_JWT_KEY_CLAIM", "sub")
+	viper.SetDefault("RATE_LIMIT_JWT_PLAN_CLAIM", "plan")
+	viper.SetDefault("STORAGE_DRIVER", "redis")
+	viper.SetDefault("MEMORY_SHARDS", 64)
+	viper.SetDefault("MEMORY_CLEANUP_INTERVAL", "60s")
 	viper.SetDefault("REDIS_HOST", "localhost")
 	viper.SetDefault("REDIS_PORT", "6379")
 	viper.SetDefault("REDIS_DB", 0)
+	viper.SetDefault("REDIS_MODE", "single")
 	viper.SetDefault("SERVER_PORT", "8080")
 
+	config, err := unmarshal()
+	if err != nil {
+		log.Fatalf("Erro ao fazer unmarshal da config: %v", err)
+	}
+
+	return config
+}
+
+// Reload relê o .env e as variáveis de ambiente, depois de LoadConfig
+// Diferente de LoadConfig, um .env inválido é erro: quem chama mantém a config atual
+func Reload() (*Config, error) {
+	if err := viper.ReadInConfig(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("erro ao ler .env: %w", err)
+	}
+	return unmarshal()
+}
+
+// File retorna o caminho do .env usado, para observar alterações
+func File() string {
+	return viper.ConfigFileUsed()
+}
+
+func unmarshal() (*Config, error) {
 	var config Config
 	if err := viper.Unmarshal(&config); err != nil {
-		log.Fatalf("Erro ao fazer unmarshal da config: %v", err)
+		return nil, err
+	}
+	return &config, nil
+}
+
+// restartKeys são lidas só na inicialização; mudanças nelas exigem reinício
+var restartKeys = map[string]bool{
+	"RATE_LIMIT_LEASE_TTL":         true,
+	"RATE_LIMIT_BREAKER_THRESHOLD": true,
+	"RATE_LIMIT_BREAKER_COOLDOWN":  true,
+	"RATE_LIMIT_TOKEN_STORE":       true,
+	"ADMIN_API_KEY":                true,
+	"RATE_LIMIT_TOKEN_HASH_SECRET": true,
+	"RATE_LIMIT_ACCESS_REFRESH":    true,
+	"RATE_LIMIT_QUOTA_TIMEZONE":    true,
+	"RATE_LIMIT_TRUSTED_PROXIES":   true, // Aplicado ao Gin (SetTrustedProxies) só no startup
+	"RATE_LIMIT_KEY":               true,
+	"STORAGE_DRIVER":               true,
+	"MEMORY_SHARDS":                true,
+	"MEMORY_CLEANUP_INTERVAL":      true,
+	"REDIS_HOST":                   true,
+	"REDIS_PORT":                   true,
+	"REDIS_PASSWORD":               true,
+	"REDIS_DB":                     true,
+	"REDIS_MODE":                   true,
+	"REDIS_ADDRS":                  true,
+	"REDIS_MASTER_NAME":            true,
+	"REDIS_SENTINEL_PASSWORD":      true,
+	"SERVER_PORT":                  true,
+}
+
+// secretKeys não têm o valor exibido no Diff
+var secretKeys = map[string]bool{
+	"REDIS_PASSWORD":               true,
+	"REDIS_SENTINEL_PASSWORD":      true,
+	"ADMIN_API_KEY":                true,
+	"RATE_LIMIT_TOKEN_HASH_SECRET": true,
+}
+
+// Diff lista as variáveis que mudaram entre duas configurações
+// Senhas não têm o valor exibido
+func Diff(old, updated *Config) []string {
+	var changes []string
+
+	oldValue, newValue := reflect.ValueOf(*old), reflect.ValueOf(*updated)
+	for i := 0; i < oldValue.NumField(); i++ {
+		key := oldValue.Type().Field(i).Tag.Get("mapstructure")
+		before, after := oldValue.Field(i).Interface(), newValue.Field(i).Interface()
+		if before == after {
+			continue
+		}
+
+		change := fmt.Sprintf("%s: %v → %v", key, before, after)
+		if secretKeys[key] {
+			change = fmt.Sprintf("%s: alterada", key)
+		}
+		if restartKeys[key] {
+			change += " (requer reinício)"
+		}
+		changes = append(changes, change)
 	}
 
-	return &config
+	return changes
 }
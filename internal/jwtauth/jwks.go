@@ -0,0 +1,139 @@
+package jwtauth
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+)
+
+/*
+	Chaves de verificação em um arquivo JWKS local (RFC 7517):
+
+	  {"keys": [
+	    {"kty": "RSA", "kid": "2025-01", "alg": "RS256", "n": "...", "e": "AQAB"},
+	    {"kty": "oct", "kid": "legacy", "alg": "HS256", "k": "..."}
+	  ]}
+
+	Chaves RSA verificam RS256 e chaves oct (segredo compartilhado) verificam
+	HS256. Chaves com "use" diferente de "sig" são ignoradas.
+*/
+
+// KeySet são as chaves de um arquivo JWKS, por kid
+type KeySet struct {
+	keys map[string]key
+}
+
+type key struct {
+	alg   string // RS256 ou HS256
+	value any    // *rsa.PublicKey ou []byte
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	K   string `json:"k"`
+}
+
+// LoadJWKS lê e valida um arquivo JWKS
+func LoadJWKS(path string) (*KeySet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler JWKS: %w", err)
+	}
+
+	keys, err := ParseJWKS(data)
+	if err != nil {
+		return nil, fmt.Errorf("erro no JWKS %s:\n%w", path, err)
+	}
+	return keys, nil
+}
+
+// ParseJWKS valida o conteúdo de um JWKS, acumulando os erros de todas as chaves
+func ParseJWKS(data []byte) (*KeySet, error) {
+	var file struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+
+	set := &KeySet{keys: make(map[string]key)}
+	var errs []error
+	for i, raw := range file.Keys {
+		if raw.Use != "" && raw.Use != "sig" {
+			continue
+		}
+		parsed, err := raw.parse()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("keys[%d] (%s): %w", i, raw.Kid, err))
+			continue
+		}
+		if _, ok := set.keys[raw.Kid]; ok {
+			errs = append(errs, fmt.Errorf("keys[%d]: kid %q duplicado", i, raw.Kid))
+			continue
+		}
+		set.keys[raw.Kid] = parsed
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	if len(set.keys) == 0 {
+		return nil, errors.New("nenhuma chave de assinatura")
+	}
+	return set, nil
+}
+
+func (k jwk) parse() (key, error) {
+	switch k.Kty {
+	case "RSA":
+		if k.Alg != "" && k.Alg != "RS256" {
+			return key{}, fmt.Errorf("alg %q não suportado para RSA (use RS256)", k.Alg)
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil || len(n) == 0 {
+			return key{}, errors.New("n inválido")
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return key{}, errors.New("e inválido")
+		}
+		return key{alg: "RS256", value: &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}}, nil
+	case "oct":
+		if k.Alg != "" && k.Alg != "HS256" {
+			return key{}, fmt.Errorf("alg %q não suportado para oct (use HS256)", k.Alg)
+		}
+		secret, err := base64.RawURLEncoding.DecodeString(k.K)
+		if err != nil || len(secret) < 32 {
+			return key{}, errors.New("k inválido (mínimo 32 bytes)")
+		}
+		return key{alg: "HS256", value: secret}, nil
+	default:
+		return key{}, fmt.Errorf("kty %q não suportado (use RSA ou oct)", k.Kty)
+	}
+}
+
+// IDs retorna os kids do conjunto, em ordem
+func (s *KeySet) IDs() []string {
+	if s == nil {
+		return nil
+	}
+	ids := make([]string, 0, len(s.keys))
+	for id := range s.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,138 @@
+package jwtauth
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Verifier confere a assinatura e a validade (exp, nbf, iss, aud) de bearer JWTs
+type Verifier struct {
+	keys   *KeySet
+	parser *jwt.Parser
+}
+
+// Option configura o Verifier
+type Option func(*options)
+
+type options struct {
+	issuer   string
+	audience string
+	leeway   time.Duration
+}
+
+// WithIssuer exige o claim iss
+func WithIssuer(issuer string) Option {
+	return func(o *options) {
+		o.issuer = issuer
+	}
+}
+
+// WithAudience exige o valor em aud
+func WithAudience(audience string) Option {
+	return func(o *options) {
+		o.audience = audience
+	}
+}
+
+// WithLeeway tolera diferença de relógio em exp e nbf
+func WithLeeway(leeway time.Duration) Option {
+	return func(o *options) {
+		o.leeway = leeway
+	}
+}
+
+// NewVerifier cria o Verifier com as chaves do JWKS
+func NewVerifier(keys *KeySet, opts ...Option) *Verifier {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	parserOpts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"RS256", "HS256"}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(o.leeway),
+	}
+	if o.issuer != "" {
+		parserOpts = append(parserOpts, jwt.WithIssuer(o.issuer))
+	}
+	if o.audience != "" {
+		parserOpts = append(parserOpts, jwt.WithAudience(o.audience))
+	}
+
+	return &Verifier{keys: keys, parser: jwt.NewParser(parserOpts...)}
+}
+
+// Verify valida o token e retorna os claims
+func (v *Verifier) Verify(token string) (map[string]any, error) {
+	claims := jwt.MapClaims{}
+	if _, err := v.parser.ParseWithClaims(token, claims, v.key); err != nil {
+		return nil, fmt.Errorf("JWT inválido: %w", err)
+	}
+	return claims, nil
+}
+
+// key escolhe a chave pelo kid; sem kid, só vale se o JWKS tiver uma única chave
+// O algoritmo do token precisa ser o da chave, para evitar confusão de algoritmo
+// (ex: HS256 assinado com a chave pública RSA)
+func (v *Verifier) key(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	k, ok := v.keys.keys[kid]
+	if !ok && kid == "" && len(v.keys.keys) == 1 {
+		for _, only := range v.keys.keys {
+			k, ok = only, true
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("kid %q desconhecido", kid)
+	}
+	if token.Method.Alg() != k.alg {
+		return nil, fmt.Errorf("alg %s não corresponde à chave %q", token.Method.Alg(), kid)
+	}
+	return k.value, nil
+}
+
+// KeyIDs retorna os kids aceitos (nil-safe)
+func (v *Verifier) KeyIDs() []string {
+	if v == nil {
+		return nil
+	}
+	return v.keys.IDs()
+}
+
+// BearerToken extrai o token do header Authorization: Bearer <token>
+func BearerToken(r *http.Request) (string, bool) {
+	scheme, token, found := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// Diff descreve as chaves adicionadas e removidas entre dois Verifiers
+func Diff(old, updated *Verifier) []string {
+	oldIDs := make(map[string]bool)
+	for _, id := range old.KeyIDs() {
+		oldIDs[id] = true
+	}
+
+	var changes []string
+	for _, id := range updated.KeyIDs() {
+		if !oldIDs[id] {
+			changes = append(changes, fmt.Sprintf("chave JWT %s adicionada", id))
+		}
+		delete(oldIDs, id)
+	}
+	for _, id := range old.KeyIDs() {
+		if oldIDs[id] {
+			changes = append(changes, fmt.Sprintf("chave JWT %s removida", id))
+		}
+	}
+	return changes
+}
@@ -0,0 +1,130 @@
+package limiter
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Algorithm identifica o algoritmo de rate limiting usado por um LimitConfig
+type Algorithm string
+
+const (
+	// FixedWindow conta requisições em janelas fixas de 1 segundo (INCR + EXPIRE)
+	FixedWindow Algorithm = "fixed_window"
+
+	// TokenBucket permite rajadas até a capacidade do bucket, repondo RPS tokens por segundo
+	TokenBucket Algorithm = "token_bucket"
+
+	// SlidingWindowLog registra o timestamp de cada requisição (ZSET) e conta as do último segundo
+	SlidingWindowLog Algorithm = "sliding_window_log"
+
+	// SlidingWindowCounter aproxima a janela deslizante ponderando o contador da janela anterior
+	SlidingWindowCounter Algorithm = "sliding_window_counter"
+
+	// GCRA (Generic Cell Rate Algorithm) guarda apenas o TAT em uma única chave
+	GCRA Algorithm = "gcra"
+
+	// LeakyBucket enfileira o excesso: requisições aguardam (até MaxWait) e são
+	// liberadas em ritmo constante de RPS por segundo
+	LeakyBucket Algorithm = "leaky_bucket"
+)
+
+// ParseAlgorithm converte uma string (ex: vinda do .env) em Algorithm
+// String vazia resulta no algoritmo padrão (FixedWindow)
+func ParseAlgorithm(s string) (Algorithm, error) {
+	alg := Algorithm(strings.ToLower(strings.TrimSpace(s)))
+	switch alg {
+	case "":
+		return FixedWindow, nil
+	case FixedWindow, TokenBucket, SlidingWindowLog, SlidingWindowCounter, GCRA, LeakyBucket:
+		return alg, nil
+	default:
+		return "", fmt.Errorf("algoritmo de rate limit desconhecido: %q", s)
+	}
+}
+
+// AlgorithmRequest descreve uma avaliação de algoritmo delegada ao storage
+type AlgorithmRequest struct {
+	Algorithm Algorithm
+	Key       string        // Identidade limitada (ex: "ip:1.2.3.4")
+	Limit     int           // Requisições permitidas por Period
+	Period    time.Duration // Período de referência do limite
+	Burst     int           // Rajada máxima (TokenBucket e GCRA)
+	MaxWait   time.Duration // Espera máxima na fila (LeakyBucket)
+	BlockTime time.Duration // Bloqueio aplicado quando o algoritmo nega (0 desativa)
+	Now       time.Time     // Instante da avaliação
+	Cost      int           // Unidades consumidas pela requisição (<= 0 vale 1)
+
+	// Quotas verificados antes do algoritmo e consumidos só se ele permitir
+	Quotas []QuotaWindow
+
+	// DryRun decide sem consumir o limite nem os quotas e sem aplicar o
+	// bloqueio de uma negação (ver CheckAll)
+	DryRun bool
+}
+
+// Decision é o resultado de um algoritmo aplicado pelo storage
+type Decision struct {
+	Allowed    bool
+	Blocked    bool // A identidade já estava bloqueada; o algoritmo nem foi consultado
+	Remaining  int
+	ResetAfter time.Duration // Tempo até o limite estar totalmente restaurado
+	RetryAfter time.Duration // Tempo até a próxima requisição ser aceita (0 se permitida)
+	Delay      time.Duration // Quanto a requisição aceita deve aguardar antes de seguir (LeakyBucket)
+
+	QuotaExceeded bool  // Negada por um quota esgotado; o algoritmo nem foi consultado
+	Quotas        []int // Restante em cada janela de AlgorithmRequest.Quotas
+}
+
+// cost retorna o custo efetivo da requisição (mínimo 1)
+func (req AlgorithmRequest) cost() int {
+	return max(req.Cost, 1)
+}
+
+// ErrUnsupportedAlgorithm indica que o storage não implementa o algoritmo pedido
+var ErrUnsupportedAlgorithm = errors.New("algoritmo não suportado pelo storage")
+
+// ErrUnsupportedCost indica que o storage só sabe contar requisições de custo 1
+var ErrUnsupportedCost = errors.New("custo por requisição não suportado pelo storage")
+
+// ErrUnsupportedMultiKey indica que o storage não avalia estes limites juntos;
+// CheckAll volta para a avaliação em duas fases
+var ErrUnsupportedMultiKey = errors.New("avaliação atômica de vários limites não suportada pelo storage")
+
+/*
+	Layout de chaves compatível com Redis Cluster:
+
+	A identidade vai entre chaves ({...}), a "hash tag" do Redis Cluster.
+	Apenas o trecho entre chaves entra no cálculo do slot, então todas as
+	chaves rate: e block: da mesma identidade caem no mesmo slot e podem ser
+	usadas juntas no mesmo script Lua (sem erro CROSSSLOT).
+
+	rate:{ip:1.2.3.4}      → contador da janela fixa
+	rate:{ip:1.2.3.4}:tb   → estado de um algoritmo
+	rate:{ip:1.2.3.4}:conc → leases de concorrência
+	rate:{ip:1.2.3.4}:q:day:1735689600 → contador de uma janela de quota
+	block:{ip:1.2.3.4}     → bloqueio
+*/
+
+// windowKey monta a chave do contador de janela fixa
+func windowKey(key string) string {
+	return fmt.Sprintf("rate:{%s}", key)
+}
+
+// blockKey monta a chave de bloqueio da identidade
+func blockKey(key string) string {
+	return fmt.Sprintf("block:{%s}", key)
+}
+
+// stateKey monta a chave de estado de um algoritmo para a identidade informada
+// Ex: stateKey("ip:1.2.3.4", "tb") → "rate:{ip:1.2.3.4}:tb"
+func stateKey(key, suffix string) string {
+	return fmt.Sprintf("rate:{%s}:%s", key, suffix)
+}
+
+// leaseKey monta a chave das leases de concorrência da identidade
+func leaseKey(key string) string {
+	return stateKey(key, "conc")
+}
@@ -0,0 +1,151 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+	Circuit breaker do storage:
+
+	  fechado  → as avaliações vão ao storage; Threshold falhas seguidas abrem
+	  aberto   → as avaliações falham na hora com ErrCircuitOpen, sem esperar
+	             o timeout de um Redis fora do ar
+	  meio     → passado o Cooldown, uma única avaliação de teste vai ao
+	             storage: sucesso fecha, falha abre de novo
+
+	O que fazer com a requisição quando a avaliação falha é decidido pelo
+	FailureMode (fail-open ou fail-closed).
+*/
+
+// ErrCircuitOpen indica que o storage foi considerado indisponível e não foi consultado
+var ErrCircuitOpen = errors.New("circuit breaker aberto: storage indisponível")
+
+// FailureMode define o que fazer com a requisição quando o storage falha
+type FailureMode string
+
+const (
+	FailOpen   FailureMode = "open"   // Deixa a requisição passar sem limite
+	FailClosed FailureMode = "closed" // Recusa a requisição (503)
+)
+
+// ParseFailureMode valida um modo de falha (vazio = open)
+func ParseFailureMode(s string) (FailureMode, error) {
+	switch mode := FailureMode(strings.ToLower(strings.TrimSpace(s))); mode {
+	case "":
+		return FailOpen, nil
+	case FailOpen, FailClosed:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("modo de falha inválido: %q (use open ou closed)", s)
+	}
+}
+
+// CircuitBreaker evita consultar um storage que está falhando em sequência
+type CircuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int       // Falhas seguidas
+	openUntil time.Time // Zero = fechado
+	probing   bool      // Avaliação de teste em andamento (meio aberto)
+}
+
+// NewCircuitBreaker abre o circuito após threshold falhas seguidas e tenta
+// novamente depois de cooldown
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		threshold: max(threshold, 1),
+		cooldown:  cooldown,
+	}
+}
+
+// allow retorna ErrCircuitOpen se o storage não deve ser consultado agora
+func (b *CircuitBreaker) allow(now time.Time) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.openUntil.IsZero() {
+		return nil
+	}
+	if b.probing || now.Before(b.openUntil) {
+		return ErrCircuitOpen
+	}
+
+	// Meio aberto: só esta avaliação vai ao storage
+	b.probing = true
+	return nil
+}
+
+// Call executa uma operação do storage feita fora do RateLimiter (ex: cadastro
+// de tokens, planos no Redis) pelo mesmo circuit breaker: com o circuito
+// aberto, falha com ErrCircuitOpen sem chamar fn. fn deve retornar só os erros
+// do storage; respostas esperadas (ex: token não encontrado) não são falhas
+// Com breaker nil, fn é sempre chamada
+func (b *CircuitBreaker) Call(fn func() error) error {
+	return b.call(time.Now, fn)
+}
+
+// call executa fn entre allow e record, com o relógio de quem chama
+func (b *CircuitBreaker) call(now func() time.Time, fn func() error) error {
+	if b == nil {
+		return fn()
+	}
+	if err := b.allow(now()); err != nil {
+		return err
+	}
+	err := fn()
+	b.record(err, now())
+	return err
+}
+
+// record registra o resultado de uma avaliação liberada por allow
+func (b *CircuitBreaker) record(err error, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	probe := b.probing
+	b.probing = false
+
+	switch {
+	case err == nil:
+		if !b.openUntil.IsZero() {
+			log.Printf("Circuit breaker fechado: storage respondeu novamente")
+		}
+		b.failures = 0
+		b.openUntil = time.Time{}
+	case !storageFailure(err):
+		// Erro da requisição (configuração, cliente desconectado), não do storage
+	default:
+		b.failures++
+		if probe || b.failures >= b.threshold {
+			if b.openUntil.IsZero() {
+				log.Printf("Circuit breaker aberto por %s após %d falhas seguidas: %v", b.cooldown, b.failures, err)
+			}
+			b.openUntil = now.Add(b.cooldown)
+		}
+	}
+}
+
+// storageFailure diz se o erro indica um storage com problemas
+func storageFailure(err error) bool {
+	return !errors.Is(err, context.Canceled) &&
+		!errors.Is(err, ErrUnsupportedAlgorithm) &&
+		!errors.Is(err, ErrUnsupportedCost) &&
+		!errors.Is(err, ErrUnsupportedQuotas) &&
+		!errors.Is(err, ErrUnsupportedMultiKey)
+}
+
+// WithCircuitBreaker faz o RateLimiter parar de consultar o storage depois de
+// falhas seguidas (RATE_LIMIT_BREAKER_*)
+func WithCircuitBreaker(breaker *CircuitBreaker) Option {
+	return func(rl *RateLimiter) {
+		rl.breaker = breaker
+	}
+}
@@ -0,0 +1,202 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+/*
+	Limites compostos: a mesma requisição é avaliada contra vários limites
+	(ex: token, IP e token+IP) e é negada se qualquer um deles negar.
+
+	Com um MultiAlgorithmStorage (Redis de nó único ou Sentinel, memória),
+	todos os limites são avaliados em uma única operação atômica: decide
+	todos sem consumir e só então consome, sem nenhuma outra avaliação no
+	meio. Uma requisição negada pelo IP não gasta o orçamento do token.
+
+	No Redis Cluster as identidades ficam em slots diferentes e não cabem no
+	mesmo script; a avaliação volta para duas fases:
+
+	  1. dry run de cada limite, sem consumir: a primeira negação encerra
+	     (e aplica o bloqueio dela, como num Check comum)
+	  2. todos permitiram: cada limite é consumido
+
+	Nesse modo a avaliação não é atômica: entre as fases, requisições
+	concorrentes ainda podem esgotar um limite. A requisição é então negada
+	e os limites consumidos antes dela na fase 2 não são devolvidos.
+*/
+
+// LimitCheck é um dos limites avaliados por CheckAll
+type LimitCheck struct {
+	Key    string
+	Config LimitConfig
+}
+
+// CheckAll avalia vários limites para a mesma requisição e nega se qualquer
+// um negar; só consome os limites quando todos permitem
+// Retorna o resultado que descreve a requisição (o limite que negou ou, se
+// todos permitiram, o de menos requisições restantes) e o índice dele
+// Sem MultiAlgorithmStorage, avalia em duas fases (ver acima); storages sem
+// dry run consomem cada limite em sequência
+func (rl *RateLimiter) CheckAll(ctx context.Context, checks []LimitCheck, opts ...CheckOption) (*CheckResult, int, error) {
+	if len(checks) == 0 {
+		return nil, -1, fmt.Errorf("nenhum limite para avaliar")
+	}
+	if len(checks) == 1 {
+		result, err := rl.Check(ctx, checks[0].Key, checks[0].Config, opts...)
+		return result, 0, err
+	}
+
+	// Uma única operação atômica, se o storage suportar estas chaves juntas
+	if multi, ok := rl.storage.(MultiAlgorithmStorage); ok {
+		options := checkOptions{cost: 1}
+		for _, opt := range opts {
+			opt(&options)
+		}
+
+		result, index, err := rl.checkAllAtomic(ctx, multi, checks, options)
+		if !errors.Is(err, ErrUnsupportedMultiKey) {
+			return result, index, err
+		}
+	}
+
+	// 1. Dry run: nenhum limite é consumido se algum negar
+	if _, ok := rl.storage.(AlgorithmStorage); ok {
+		peekOpts := append(append([]CheckOption(nil), opts...), dryRun())
+		for i, check := range checks {
+			result, err := rl.Check(ctx, check.Key, check.Config, peekOpts...)
+			if err != nil {
+				return nil, i, err
+			}
+			if !result.Allowed {
+				// O dry run não grava nada: o bloqueio da negação é aplicado aqui,
+				// pelo circuit breaker como as demais operações do storage
+				if config := check.Config.effective(); !result.Blocked && !result.QuotaExceeded && config.BlockTime > 0 {
+					err := rl.breaker.call(rl.now, func() error {
+						return rl.block(ctx, check.Key, config)
+					})
+					if err != nil {
+						return nil, i, err
+					}
+				}
+				return result, i, nil
+			}
+		}
+	}
+
+	// 2. Consumo de todos os limites
+	results := make([]*CheckResult, len(checks))
+	for i, check := range checks {
+		result, err := rl.Check(ctx, check.Key, check.Config, opts...)
+		if err != nil {
+			return nil, i, err
+		}
+		if !result.Allowed {
+			return result, i, nil
+		}
+		results[i] = result
+	}
+	result, index := combine(results)
+	return result, index, nil
+}
+
+// checkAllAtomic avalia todos os limites em uma única operação do storage
+// Com circuit breaker aberto, falha com ErrCircuitOpen sem consultar o storage
+func (rl *RateLimiter) checkAllAtomic(ctx context.Context, multi MultiAlgorithmStorage, checks []LimitCheck, options checkOptions) (*CheckResult, int, error) {
+	now := rl.now()
+	configs := make([]LimitConfig, len(checks))
+	reqs := make([]AlgorithmRequest, len(checks))
+	quotas := make([][]QuotaWindow, len(checks))
+	for i, check := range checks {
+		configs[i] = check.Config.effective()
+		reqs[i], quotas[i] = rl.algorithmRequest(check.Key, configs[i], options, now)
+	}
+
+	var decisions []*Decision
+	var denied int
+	err := rl.breaker.call(rl.now, func() (err error) {
+		decisions, denied, err = multi.EvaluateAll(ctx, reqs)
+		return err
+	})
+	if errors.Is(err, ErrCircuitOpen) {
+		return nil, -1, err
+	}
+	if err != nil {
+		return nil, -1, fmt.Errorf("erro ao avaliar limites: %w", err)
+	}
+
+	if denied >= 0 {
+		return checkResult(decisions[denied], configs[denied], quotas[denied], now), denied, nil
+	}
+	results := make([]*CheckResult, len(checks))
+	for i, decision := range decisions {
+		results[i] = checkResult(decision, configs[i], quotas[i], now)
+	}
+	result, index := combine(results)
+	return result, index, nil
+}
+
+// combine junta os resultados de limites que permitiram a requisição: o
+// reportado é o do limite mais apertado e a espera (LeakyBucket) é a maior
+// entre os limites
+func combine(results []*CheckResult) (*CheckResult, int) {
+	var combined *CheckResult
+	index := -1
+	for i, result := range results {
+		if !result.Allowed {
+			// Só acontece se o mesmo limite aparece duas vezes na avaliação
+			return result, i
+		}
+		if combined == nil || result.Remaining < combined.Remaining {
+			delay := result.Delay
+			if combined != nil {
+				delay = max(delay, combined.Delay)
+			}
+			combined, index = result, i
+			combined.Delay = delay
+		} else {
+			combined.Delay = max(combined.Delay, result.Delay)
+		}
+	}
+	return combined, index
+}
+
+// dryRun avalia sem consumir (usado pela fase 1 do CheckAll)
+func dryRun() CheckOption {
+	return func(o *checkOptions) {
+		o.dryRun = true
+	}
+}
+
+// evaluateAll é a avaliação de EvaluateAll para storages que já garantem que
+// nada acontece entre as chamadas de evaluate (ex: locks da memória)
+func evaluateAll(reqs []AlgorithmRequest, evaluate func(AlgorithmRequest) (*Decision, error)) ([]*Decision, int, error) {
+	decisions := make([]*Decision, len(reqs))
+
+	// 1. Todos decidem sem consumir; a primeira negação encerra. Ela é avaliada
+	// de novo para valer, o que só aplica o bloqueio: negar não consome
+	for i, req := range reqs {
+		req.DryRun = true
+		decision, err := evaluate(req)
+		if err != nil {
+			return nil, i, err
+		}
+		if !decision.Allowed {
+			req.DryRun = false
+			decisions[i], err = evaluate(req)
+			return decisions, i, err
+		}
+	}
+
+	// 2. Todos permitiram: cada limite é consumido
+	for i, req := range reqs {
+		req.DryRun = false
+		decision, err := evaluate(req)
+		if err != nil {
+			return nil, i, err
+		}
+		decisions[i] = decision
+	}
+	return decisions, -1, nil
+}
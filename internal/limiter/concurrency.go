@@ -0,0 +1,161 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+/*
+	ConcurrencyLimiter limita quantas requisições de uma identidade podem estar
+	em andamento ao mesmo tempo (ex: no máximo 5 exportações simultâneas).
+
+	Cada requisição aceita recebe uma Lease, que ocupa uma vaga até ser liberada
+	com Release. A lease tem validade (leaseTTL): enquanto está ativa ela é
+	renovada periodicamente em background (heartbeat); se a instância cair sem
+	liberar, o heartbeat para e a vaga volta sozinha após leaseTTL.
+*/
+
+const defaultLeaseTTL = 30 * time.Second
+
+type ConcurrencyLimiter struct {
+	storage  ConcurrencyStorage
+	leaseTTL time.Duration
+	now      func() time.Time
+	breaker  *CircuitBreaker // Opcional: o mesmo do RateLimiter
+}
+
+// ConcurrencyOption configura parâmetros opcionais do ConcurrencyLimiter
+type ConcurrencyOption func(*ConcurrencyLimiter)
+
+// WithLeaseClock substitui o relógio usado nas expirações das leases (útil em testes)
+func WithLeaseClock(now func() time.Time) ConcurrencyOption {
+	return func(cl *ConcurrencyLimiter) {
+		cl.now = now
+	}
+}
+
+// WithLeaseBreaker faz as leases passarem pelo circuit breaker do RateLimiter:
+// com o storage fora do ar, Acquire falha na hora com ErrCircuitOpen
+func WithLeaseBreaker(breaker *CircuitBreaker) ConcurrencyOption {
+	return func(cl *ConcurrencyLimiter) {
+		cl.breaker = breaker
+	}
+}
+
+// NewConcurrencyLimiter cria o limitador de concorrência
+// leaseTTL <= 0 usa o padrão (30s)
+func NewConcurrencyLimiter(storage ConcurrencyStorage, leaseTTL time.Duration, opts ...ConcurrencyOption) *ConcurrencyLimiter {
+	if leaseTTL <= 0 {
+		leaseTTL = defaultLeaseTTL
+	}
+
+	cl := &ConcurrencyLimiter{
+		storage:  storage,
+		leaseTTL: leaseTTL,
+		now:      time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(cl)
+	}
+
+	return cl
+}
+
+type AcquireResult struct {
+	Acquired bool
+	InFlight int    // Requisições em andamento, incluindo esta se adquirida
+	Lease    *Lease // nil quando não adquirida
+}
+
+// Lease representa uma vaga ocupada; deve ser liberada com Release
+type Lease struct {
+	limiter *ConcurrencyLimiter
+	key     string
+	id      string
+	stop    chan struct{}
+	once    sync.Once
+}
+
+// Acquire tenta ocupar uma das limit vagas da identidade
+func (cl *ConcurrencyLimiter) Acquire(ctx context.Context, key string, limit int) (*AcquireResult, error) {
+	id := newLeaseID(cl.now())
+
+	var acquired bool
+	var inFlight int
+	err := cl.breaker.call(cl.now, func() (err error) {
+		acquired, inFlight, err = cl.storage.AcquireLease(ctx, key, id, limit, cl.leaseTTL, cl.now())
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("erro ao adquirir vaga de concorrência: %w", err)
+	}
+
+	result := &AcquireResult{Acquired: acquired, InFlight: inFlight}
+	if !acquired {
+		return result, nil
+	}
+
+	result.Lease = &Lease{
+		limiter: cl,
+		key:     key,
+		id:      id,
+		stop:    make(chan struct{}),
+	}
+	go result.Lease.heartbeat()
+
+	return result, nil
+}
+
+// Release libera a vaga e encerra o heartbeat
+// Chamadas repetidas não têm efeito
+func (l *Lease) Release(ctx context.Context) error {
+	var err error
+	l.once.Do(func() {
+		close(l.stop)
+		releaseErr := l.limiter.breaker.call(l.limiter.now, func() error {
+			return l.limiter.storage.ReleaseLease(ctx, l.key, l.id)
+		})
+		if releaseErr != nil {
+			err = fmt.Errorf("erro ao liberar vaga de concorrência: %w", releaseErr)
+		}
+	})
+	return err
+}
+
+// heartbeat renova a lease algumas vezes dentro de cada leaseTTL, para que
+// requisições mais longas que o TTL não percam a vaga
+func (l *Lease) heartbeat() {
+	interval := l.limiter.leaseTTL / 3
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			var renewed bool
+			err := l.limiter.breaker.call(l.limiter.now, func() (err error) {
+				renewed, err = l.limiter.storage.RenewLease(ctx, l.key, l.id, l.limiter.leaseTTL, l.limiter.now())
+				return err
+			})
+			cancel()
+
+			// Lease já expirou (ex: storage indisponível por mais que o TTL):
+			// a vaga pode ter sido ocupada por outra requisição, não há o que renovar
+			if err == nil && !renewed {
+				return
+			}
+		case <-l.stop:
+			return
+		}
+	}
+}
+
+// newLeaseID gera um identificador único entre instâncias
+func newLeaseID(now time.Time) string {
+	return fmt.Sprintf("%d-%d", now.UnixNano(), rand.Uint64())
+}
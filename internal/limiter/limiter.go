@@ -3,32 +3,125 @@ package limiter
 import (
 	"context"
 	"fmt"
+	"math"
 	"time"
 )
 
 type RateLimiter struct {
-	storage StorageStrategy
+	storage  StorageStrategy
+	now      func() time.Time
+	location *time.Location  // Fuso horário das janelas de quota
+	breaker  *CircuitBreaker // Opcional: para de consultar um storage fora do ar
 }
 
 type LimitConfig struct {
 	RPS       int           // Requests per second
 	BlockTime time.Duration // Tempo de bloqueio quando excedido
+	Algorithm Algorithm     // Algoritmo usado (vazio = FixedWindow)
+	Burst     int           // Rajada máxima no TokenBucket e GCRA (0 = RPS)
+	MaxWait   time.Duration // Espera máxima na fila do LeakyBucket antes de negar
+
+	MaxConcurrent int // Requisições simultâneas permitidas (0 = sem limite; aplicado pelo ConcurrencyLimiter)
+
+	Quotas []Quota // Limites de longo prazo avaliados junto com o RPS (ex: 100k/dia)
 }
 
 type CheckResult struct {
-	Allowed   bool
-	Remaining int
-	ResetTime time.Time
-	Blocked   bool
+	Allowed bool
+	// Limit, Remaining e ResetTime descrevem a janela mais restritiva
+	// (o RPS ou um dos quotas)
+	Limit      int
+	Remaining  int
+	ResetTime  time.Time
+	RetryAfter time.Duration // Quanto aguardar antes de tentar novamente (0 se permitida)
+	Delay      time.Duration // Quanto segurar a requisição permitida antes de liberá-la (LeakyBucket)
+	Blocked    bool
+
+	QuotaExceeded bool // Negada porque um quota de longo prazo se esgotou
+}
+
+// Option configura parâmetros opcionais do RateLimiter
+type Option func(*RateLimiter)
+
+// WithClock substitui o relógio usado pelos algoritmos (útil em testes)
+func WithClock(now func() time.Time) Option {
+	return func(rl *RateLimiter) {
+		rl.now = now
+	}
 }
 
-func NewRateLimiter(storage StorageStrategy) *RateLimiter {
-	return &RateLimiter{
-		storage: storage,
+// WithQuotaLocation define o fuso horário em que as janelas de quota viram
+// (ex: um quota diário zera à meia-noite desse fuso). Padrão: UTC
+func WithQuotaLocation(loc *time.Location) Option {
+	return func(rl *RateLimiter) {
+		rl.location = loc
 	}
 }
 
-func (rl *RateLimiter) Check(ctx context.Context, key string, config LimitConfig) (*CheckResult, error) {
+// CheckOption configura uma chamada específica de Check
+type CheckOption func(*checkOptions)
+
+type checkOptions struct {
+	cost   int
+	dryRun bool
+}
+
+// WithCost define quantas unidades a requisição consome (padrão 1)
+// Ex: uma busca pode custar 5, um upload em lote pode custar pelo tamanho
+func WithCost(cost int) CheckOption {
+	return func(o *checkOptions) {
+		o.cost = cost
+	}
+}
+
+func NewRateLimiter(storage StorageStrategy, opts ...Option) *RateLimiter {
+	rl := &RateLimiter{
+		storage:  storage,
+		now:      time.Now,
+		location: time.UTC,
+	}
+
+	for _, opt := range opts {
+		opt(rl)
+	}
+
+	return rl
+}
+
+// Check avalia e consome o limite da chave
+// Com circuit breaker aberto, falha com ErrCircuitOpen sem consultar o storage
+func (rl *RateLimiter) Check(ctx context.Context, key string, config LimitConfig, opts ...CheckOption) (*CheckResult, error) {
+	options := checkOptions{cost: 1}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	var result *CheckResult
+	err := rl.breaker.call(rl.now, func() (err error) {
+		result, err = rl.check(ctx, key, config, options)
+		return err
+	})
+	return result, err
+}
+
+func (rl *RateLimiter) check(ctx context.Context, key string, config LimitConfig, options checkOptions) (*CheckResult, error) {
+
+	// Storages atômicos decidem tudo (bloqueio + algoritmo + bloqueio) em um round-trip
+	if algStorage, ok := rl.storage.(AlgorithmStorage); ok {
+		return rl.checkAtomic(ctx, algStorage, key, config, options)
+	}
+
+	// Fluxo de três etapas para storages simples: só suporta janela fixa
+	if config.Algorithm != "" && config.Algorithm != FixedWindow {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, config.Algorithm)
+	}
+	if len(config.Quotas) > 0 {
+		return nil, ErrUnsupportedQuotas
+	}
+	if options.cost > 1 {
+		return nil, ErrUnsupportedCost
+	}
+
 	// Verifica se está bloqueado
 	blocked, err := rl.storage.IsBlocked(ctx, key)
 	if err != nil {
@@ -36,14 +129,23 @@ func (rl *RateLimiter) Check(ctx context.Context, key string, config LimitConfig
 	}
 
 	if blocked {
+		// O bloqueio dura no máximo BlockTime a partir de agora
 		return &CheckResult{
-			Allowed: false,
-			Blocked: true,
+			Allowed:    false,
+			Limit:      config.RPS,
+			ResetTime:  rl.now().Add(config.BlockTime),
+			RetryAfter: config.BlockTime,
+			Blocked:    true,
 		}, nil
 	}
 
+	return rl.checkFixedWindow(ctx, key, config)
+}
+
+// checkFixedWindow conta requisições em janelas de 1 segundo usando Increment
+func (rl *RateLimiter) checkFixedWindow(ctx context.Context, key string, config LimitConfig) (*CheckResult, error) {
 	// Chave para contagem de requisições
-	countKey := fmt.Sprintf("rate:%s", key)
+	countKey := windowKey(key)
 
 	// Incrementa contador com TTL de 1 segundo
 	count, existed, err := rl.storage.Increment(ctx, countKey, time.Second)
@@ -57,7 +159,7 @@ func (rl *RateLimiter) Check(ctx context.Context, key string, config LimitConfig
 	}
 
 	// Calcula informações de reset
-	resetTime := time.Now().Add(time.Second)
+	resetTime := rl.now().Add(time.Second)
 	remaining := config.RPS - count
 	if remaining < 0 {
 		remaining = 0
@@ -66,22 +168,156 @@ func (rl *RateLimiter) Check(ctx context.Context, key string, config LimitConfig
 	// Verifica se excedeu o limite
 	if count > config.RPS {
 		// Bloqueia por BlockTime
-		if err := rl.storage.Block(ctx, key, config.BlockTime); err != nil {
-			return nil, fmt.Errorf("erro ao bloquear chave: %w", err)
+		if err := rl.block(ctx, key, config); err != nil {
+			return nil, err
 		}
 
 		return &CheckResult{
-			Allowed:   false,
-			Remaining: 0,
-			ResetTime: resetTime,
-			Blocked:   false, // Acabou de ser bloqueado
+			Allowed:    false,
+			Limit:      config.RPS,
+			Remaining:  0,
+			ResetTime:  resetTime,
+			RetryAfter: retryAfter(time.Second, config),
+			Blocked:    false, // Acabou de ser bloqueado
 		}, nil
 	}
 
 	return &CheckResult{
 		Allowed:   true,
+		Limit:     config.RPS,
 		Remaining: remaining,
 		ResetTime: resetTime,
 		Blocked:   false,
 	}, nil
 }
+
+// checkAtomic delega a decisão completa ao storage em uma única operação
+func (rl *RateLimiter) checkAtomic(ctx context.Context, algStorage AlgorithmStorage, key string, config LimitConfig, options checkOptions) (*CheckResult, error) {
+	config = config.effective()
+	now := rl.now()
+
+	req, quotas := rl.algorithmRequest(key, config, options, now)
+	decision, err := algStorage.Evaluate(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao avaliar algoritmo %s: %w", config.Algorithm, err)
+	}
+
+	return checkResult(decision, config, quotas, now), nil
+}
+
+// effective preenche os padrões do limite: sem algoritmo vale FixedWindow e,
+// sem burst, o bucket comporta exatamente 1 segundo de requisições
+// Fila cheia no leaky bucket não bloqueia: o cliente só volta quando houver vaga
+func (config LimitConfig) effective() LimitConfig {
+	if config.Algorithm == "" {
+		config.Algorithm = FixedWindow
+	}
+	if config.Burst <= 0 {
+		config.Burst = config.RPS
+	}
+	if config.Algorithm == LeakyBucket {
+		config.BlockTime = 0
+	}
+	return config
+}
+
+// algorithmRequest monta a avaliação de um limite (já com effective) e
+// resolve a janela de calendário corrente de cada quota
+func (rl *RateLimiter) algorithmRequest(key string, config LimitConfig, options checkOptions, now time.Time) (AlgorithmRequest, []QuotaWindow) {
+	var quotas []QuotaWindow
+	for _, quota := range config.Quotas {
+		quotas = append(quotas, quota.window(now.In(rl.location)))
+	}
+
+	return AlgorithmRequest{
+		Algorithm: config.Algorithm,
+		Key:       key,
+		Limit:     config.RPS,
+		Period:    time.Second,
+		Burst:     config.Burst,
+		MaxWait:   config.MaxWait,
+		BlockTime: config.BlockTime,
+		Now:       now,
+		Cost:      options.cost,
+		Quotas:    quotas,
+		DryRun:    options.dryRun,
+	}, quotas
+}
+
+// checkResult converte a decisão do storage no resultado do limite
+func checkResult(decision *Decision, config LimitConfig, quotas []QuotaWindow, now time.Time) *CheckResult {
+	if decision.Blocked {
+		// O storage devolve o tempo restante exato do bloqueio
+		return &CheckResult{
+			Allowed:    false,
+			Limit:      config.RPS,
+			ResetTime:  now.Add(decision.RetryAfter),
+			RetryAfter: decision.RetryAfter,
+			Blocked:    true,
+		}
+	}
+
+	result := &CheckResult{
+		Allowed:   decision.Allowed,
+		Limit:     config.RPS,
+		Remaining: decision.Remaining,
+		ResetTime: now.Add(decision.ResetAfter),
+		Delay:     decision.Delay,
+		Blocked:   false,
+	}
+	if decision.QuotaExceeded {
+		// O algoritmo não foi consultado: só os quotas descrevem o estado
+		result.Remaining = math.MaxInt
+		result.ResetTime = time.Time{}
+	}
+	mostRestrictive(result, quotas, decision.Quotas)
+
+	if decision.QuotaExceeded {
+		// Quota esgotado não gera bloqueio: basta aguardar o fim da janela
+		result.QuotaExceeded = true
+		result.RetryAfter = decision.RetryAfter
+	} else if !decision.Allowed {
+		// O storage já aplicou o bloqueio, se configurado
+		result.RetryAfter = retryAfter(decision.RetryAfter, config)
+	}
+
+	return result
+}
+
+// mostRestrictive faz o resultado refletir a janela com menos requisições
+// restantes; em caso de empate, a que demora mais para ser restaurada
+func mostRestrictive(result *CheckResult, quotas []QuotaWindow, remaining []int) {
+	for i, quota := range quotas {
+		if i >= len(remaining) {
+			break
+		}
+		if remaining[i] < result.Remaining ||
+			(remaining[i] == result.Remaining && !quota.End.Before(result.ResetTime)) {
+			result.Limit = quota.Limit
+			result.Remaining = remaining[i]
+			result.ResetTime = quota.End
+		}
+	}
+}
+
+// block aplica o bloqueio temporário configurado (BlockTime zero desativa o bloqueio)
+func (rl *RateLimiter) block(ctx context.Context, key string, config LimitConfig) error {
+	if config.BlockTime <= 0 {
+		return nil
+	}
+
+	if err := rl.storage.Block(ctx, key, config.BlockTime); err != nil {
+		return fmt.Errorf("erro ao bloquear chave: %w", err)
+	}
+
+	return nil
+}
+
+// retryAfter considera o bloqueio: se BlockTime estiver ativo, ele prevalece
+// sobre o tempo calculado pelo algoritmo
+func retryAfter(algorithmRetry time.Duration, config LimitConfig) time.Duration {
+	if config.BlockTime > algorithmRetry {
+		return config.BlockTime
+	}
+	return algorithmRetry
+}
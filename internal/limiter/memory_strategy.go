@@ -0,0 +1,653 @@
+package limiter
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+/*
+	MemoryStrategy guarda o estado do rate limiter na memória do processo.
+
+	- Sharding: as chaves são distribuídas em N shards, cada um com seu próprio
+	  mutex, reduzindo a contenção entre goroutines
+	- TTL: toda entrada tem expiração; entradas vencidas são ignoradas na leitura
+	- Janitor: goroutine em background remove periodicamente as entradas vencidas
+
+	Indicado para deploys de uma única instância e para testes. Com várias
+	instâncias, cada uma teria seus próprios contadores - use Redis.
+*/
+
+const (
+	defaultMemoryShards          = 64
+	defaultMemoryCleanupInterval = time.Minute
+)
+
+type MemoryStrategy struct {
+	shards []*memoryShard
+	stop   chan struct{}
+	once   sync.Once
+}
+
+type memoryShard struct {
+	mu      sync.Mutex
+	entries map[string]*memoryEntry
+}
+
+type memoryEntry struct {
+	value     interface{}
+	expiresAt time.Time // Zero = sem expiração
+}
+
+// tokenBucketState é o equivalente em memória do hash {tokens, ts} do Redis
+type tokenBucketState struct {
+	tokens float64
+	ts     float64 // ms
+}
+
+// NewMemoryStrategy cria o storage em memória e inicia o janitor
+// Valores <= 0 usam os padrões (64 shards, limpeza a cada minuto)
+func NewMemoryStrategy(shards int, cleanupInterval time.Duration) *MemoryStrategy {
+	if shards <= 0 {
+		shards = defaultMemoryShards
+	}
+	if cleanupInterval <= 0 {
+		cleanupInterval = defaultMemoryCleanupInterval
+	}
+
+	m := &MemoryStrategy{
+		shards: make([]*memoryShard, shards),
+		stop:   make(chan struct{}),
+	}
+	for i := range m.shards {
+		m.shards[i] = &memoryShard{entries: make(map[string]*memoryEntry)}
+	}
+
+	go m.janitor(cleanupInterval)
+
+	return m
+}
+
+// Close encerra o janitor
+func (m *MemoryStrategy) Close() error {
+	m.once.Do(func() { close(m.stop) })
+	return nil
+}
+
+// Len retorna o número de entradas armazenadas, incluindo as ainda não limpas
+func (m *MemoryStrategy) Len() int {
+	total := 0
+	for _, shard := range m.shards {
+		shard.mu.Lock()
+		total += len(shard.entries)
+		shard.mu.Unlock()
+	}
+	return total
+}
+
+func (m *MemoryStrategy) Get(ctx context.Context, key string) (int, error) {
+	shard := m.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	count, _ := shard.get(key, time.Now()).intValue()
+	return count, nil
+}
+
+func (m *MemoryStrategy) Set(ctx context.Context, key string, tokens int, ttl time.Duration) error {
+	shard := m.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	shard.set(key, tokens, expiry(time.Now(), ttl))
+	return nil
+}
+
+func (m *MemoryStrategy) Increment(ctx context.Context, key string, ttl time.Duration) (int, bool, error) {
+	shard := m.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	now := time.Now()
+	count, existed := shard.get(key, now).intValue()
+	count++
+
+	// Mesmo comportamento do RedisStrategy: o TTL é renovado a cada incremento
+	shard.set(key, count, expiry(now, ttl))
+	return count, existed, nil
+}
+
+func (m *MemoryStrategy) IsBlocked(ctx context.Context, key string) (bool, error) {
+	shard := m.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	return shard.get(blockKey(key), time.Now()) != nil, nil
+}
+
+func (m *MemoryStrategy) Block(ctx context.Context, key string, blockTime time.Duration) error {
+	shard := m.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	shard.set(blockKey(key), "blocked", expiry(time.Now(), blockTime))
+	return nil
+}
+
+// Evaluate executa a decisão completa sob o lock do shard da identidade,
+// o equivalente em memória dos scripts Lua do RedisStrategy
+func (m *MemoryStrategy) Evaluate(ctx context.Context, req AlgorithmRequest) (*Decision, error) {
+	// Bloqueio e estado da mesma identidade ficam sempre no mesmo shard
+	shard := m.shard(req.Key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	return shard.evaluate(req)
+}
+
+// EvaluateAll avalia vários limites com os shards de todos eles travados,
+// então nenhuma outra avaliação acontece entre a decisão e o consumo
+func (m *MemoryStrategy) EvaluateAll(ctx context.Context, reqs []AlgorithmRequest) ([]*Decision, int, error) {
+	// Sempre na ordem dos shards, para que duas avaliações não travem uma à outra
+	locked := make(map[int]bool)
+	for _, req := range reqs {
+		locked[m.shardIndex(req.Key)] = true
+	}
+	indexes := make([]int, 0, len(locked))
+	for index := range locked {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	for _, index := range indexes {
+		m.shards[index].mu.Lock()
+		defer m.shards[index].mu.Unlock()
+	}
+
+	return evaluateAll(reqs, func(req AlgorithmRequest) (*Decision, error) {
+		return m.shard(req.Key).evaluate(req)
+	})
+}
+
+// evaluate faz a decisão completa de Evaluate; o lock do shard já está com quem chama
+func (s *memoryShard) evaluate(req AlgorithmRequest) (*Decision, error) {
+	now := req.Now
+	cost := req.cost()
+	if block := s.get(blockKey(req.Key), now); block != nil {
+		retry := req.BlockTime
+		if !block.expiresAt.IsZero() {
+			retry = block.expiresAt.Sub(now)
+		}
+		return &Decision{Allowed: false, Blocked: true, ResetAfter: retry, RetryAfter: retry}, nil
+	}
+
+	// Quotas são verificados antes do algoritmo e consumidos só se ele permitir
+	var quotaRemaining []int
+	var quotaRetry time.Duration
+	for _, quota := range req.Quotas {
+		used, _ := s.get(quotaKey(req.Key, quota.ID), now).intValue()
+		quotaRemaining = append(quotaRemaining, max(0, quota.Limit-used))
+
+		// Quota esgotado: só volta no fim da janela (o mais distante, se vários)
+		if used+cost > quota.Limit {
+			quotaRetry = max(quotaRetry, quota.ttl(now))
+		}
+	}
+	if quotaRetry > 0 {
+		return &Decision{
+			Allowed:       false,
+			ResetAfter:    quotaRetry,
+			RetryAfter:    quotaRetry,
+			QuotaExceeded: true,
+			Quotas:        quotaRemaining,
+		}, nil
+	}
+
+	if req.DryRun {
+		// Decide normalmente e devolve o estado anterior: nada é consumido
+		defer s.restore(s.snapshot(algorithmKeys(req), now))
+	}
+
+	var decision *Decision
+	switch req.Algorithm {
+	case FixedWindow, "":
+		decision = s.fixedWindow(req)
+	case TokenBucket:
+		decision = s.tokenBucket(req)
+	case SlidingWindowLog:
+		decision = s.slidingWindowLog(req)
+	case SlidingWindowCounter:
+		decision = s.slidingWindowCounter(req)
+	case GCRA:
+		decision = s.gcra(req)
+	case LeakyBucket:
+		decision = s.leakyBucket(req)
+	default:
+		return nil, ErrUnsupportedAlgorithm
+	}
+
+	if decision.Allowed && !req.DryRun {
+		for i, quota := range req.Quotas {
+			key := quotaKey(req.Key, quota.ID)
+			// A janela expira no seu fim de calendário
+			if entry := s.get(key, now); entry != nil {
+				used, _ := entry.intValue()
+				entry.value = used + cost
+			} else {
+				s.set(key, cost, now.Add(quota.ttl(now)))
+			}
+			quotaRemaining[i] = max(0, quotaRemaining[i]-cost)
+		}
+	} else if !decision.Allowed && req.BlockTime > 0 && !req.DryRun {
+		s.set(blockKey(req.Key), "blocked", now.Add(req.BlockTime))
+	}
+	decision.Quotas = quotaRemaining
+
+	return decision, nil
+}
+
+// algorithmKeys lista as chaves de estado que o algoritmo pode gravar
+func algorithmKeys(req AlgorithmRequest) []string {
+	index := req.Now.UnixMilli() / req.Period.Milliseconds()
+	return []string{
+		windowKey(req.Key),
+		stateKey(req.Key, "tb"),
+		stateKey(req.Key, "log"),
+		stateKey(req.Key, strconv.FormatInt(index, 10)),
+		stateKey(req.Key, "gcra"),
+		stateKey(req.Key, "leaky"),
+	}
+}
+
+// snapshot copia as entradas das chaves (nil = ausente), inclusive o log
+// do sliding window, que é alterado no lugar
+func (s *memoryShard) snapshot(keys []string, now time.Time) map[string]*memoryEntry {
+	saved := make(map[string]*memoryEntry, len(keys))
+	for _, key := range keys {
+		entry := s.get(key, now)
+		if entry == nil {
+			saved[key] = nil
+			continue
+		}
+		copied := *entry
+		if log, ok := entry.value.([]float64); ok {
+			copied.value = append([]float64(nil), log...)
+		}
+		saved[key] = &copied
+	}
+	return saved
+}
+
+// restore devolve as entradas salvas por snapshot
+func (s *memoryShard) restore(saved map[string]*memoryEntry) {
+	for key, entry := range saved {
+		if entry == nil {
+			delete(s.entries, key)
+		} else {
+			s.entries[key] = entry
+		}
+	}
+}
+
+func (s *memoryShard) fixedWindow(req AlgorithmRequest) *Decision {
+	key := windowKey(req.Key)
+	entry := s.get(key, req.Now)
+
+	count, _ := entry.intValue()
+	ttl := req.Period
+	if entry != nil && !entry.expiresAt.IsZero() {
+		ttl = entry.expiresAt.Sub(req.Now)
+	}
+
+	cost := req.cost()
+	if count+cost > req.Limit {
+		return &Decision{Allowed: false, Remaining: max(0, req.Limit-count), ResetAfter: ttl, RetryAfter: ttl}
+	}
+
+	count += cost
+	// O TTL é definido só na abertura da janela, para que ela realmente termine
+	if entry == nil {
+		s.set(key, count, req.Now.Add(req.Period))
+	} else {
+		entry.value = count
+	}
+
+	return &Decision{Allowed: true, Remaining: req.Limit - count, ResetAfter: ttl}
+}
+
+func (s *memoryShard) tokenBucket(req AlgorithmRequest) *Decision {
+	key := stateKey(req.Key, "tb")
+	capacity := float64(req.Burst)
+	rate := float64(req.Limit) / float64(req.Period.Milliseconds())
+	now := float64(req.Now.UnixMilli())
+
+	state, ok := s.get(key, req.Now).valueOr(nil).(tokenBucketState)
+	if !ok {
+		state = tokenBucketState{tokens: capacity, ts: now}
+	}
+
+	// Reposição proporcional ao tempo decorrido (pode ser fracionária)
+	elapsed := math.Max(0, now-state.ts)
+	tokens := math.Min(capacity, state.tokens+elapsed*rate)
+
+	cost := float64(req.cost())
+	decision := &Decision{}
+	if tokens >= cost {
+		tokens -= cost
+		decision.Allowed = true
+	} else {
+		decision.RetryAfter = millis(math.Ceil((cost - tokens) / rate))
+	}
+
+	reset := math.Ceil((capacity - tokens) / rate)
+	decision.Remaining = int(math.Floor(tokens))
+	decision.ResetAfter = millis(reset)
+
+	s.set(key, tokenBucketState{tokens: tokens, ts: math.Max(state.ts, now)}, req.Now.Add(millis(math.Max(reset, 1))))
+	return decision
+}
+
+func (s *memoryShard) slidingWindowLog(req AlgorithmRequest) *Decision {
+	key := stateKey(req.Key, "log")
+	window := float64(req.Period.Milliseconds())
+	now := float64(req.Now.UnixMilli())
+
+	// Descarta requisições que já saíram da janela deslizante
+	entries, _ := s.get(key, req.Now).valueOr(nil).([]float64)
+	cut := sort.SearchFloat64s(entries, math.Nextafter(now-window, math.Inf(1)))
+	entries = entries[cut:]
+
+	cost := req.cost()
+	decision := &Decision{}
+	if len(entries)+cost <= req.Limit {
+		// Mantém o log ordenado mesmo que requisições cheguem fora de ordem
+		pos := sort.SearchFloat64s(entries, now)
+		units := make([]float64, cost)
+		for i := range units {
+			units[i] = now
+		}
+		entries = append(entries[:pos], append(units, entries[pos:]...)...)
+		decision.Allowed = true
+	} else if index := len(entries) + cost - req.Limit - 1; index < len(entries) {
+		// Abrem vagas suficientes quando as unidades mais antigas saírem da janela
+		decision.RetryAfter = millis(entries[index] + window - now)
+	}
+
+	if len(entries) > 0 {
+		decision.ResetAfter = millis(entries[len(entries)-1] + window - now)
+	}
+	decision.Remaining = max(0, req.Limit-len(entries))
+
+	s.set(key, entries, req.Now.Add(req.Period))
+	return decision
+}
+
+func (s *memoryShard) slidingWindowCounter(req AlgorithmRequest) *Decision {
+	windowMs := req.Period.Milliseconds()
+	nowMs := req.Now.UnixMilli()
+	index := nowMs / windowMs
+	currentKey := stateKey(req.Key, strconv.FormatInt(index, 10))
+
+	current, _ := s.get(currentKey, req.Now).intValue()
+	previous, _ := s.get(stateKey(req.Key, strconv.FormatInt(index-1, 10)), req.Now).intValue()
+
+	// Quanto da janela anterior ainda se sobrepõe à janela deslizante
+	window := float64(windowMs)
+	limit := float64(req.Limit)
+	elapsed := float64(nowMs % windowMs)
+	estimate := float64(previous)*((window-elapsed)/window) + float64(current)
+
+	cost := req.cost()
+	decision := &Decision{}
+	switch {
+	case estimate+float64(cost) <= limit:
+		current += cost
+		s.set(currentKey, current, req.Now.Add(2*req.Period))
+		estimate += float64(cost)
+		decision.Allowed = true
+	case cost > req.Limit:
+		// Custo maior que o limite nunca cabe na janela
+	case current+cost <= req.Limit:
+		target := window * (1 - (limit-float64(current+cost))/float64(previous))
+		decision.RetryAfter = millis(math.Ceil(target - elapsed))
+	default:
+		target := window * (1 - (limit-float64(cost))/float64(current))
+		decision.RetryAfter = millis(math.Ceil(window - elapsed + target))
+	}
+
+	switch {
+	case current > 0:
+		decision.ResetAfter = millis(2*window - elapsed)
+	case previous > 0:
+		decision.ResetAfter = millis(window - elapsed)
+	}
+	decision.Remaining = int(math.Max(0, math.Floor(limit-estimate)))
+
+	return decision
+}
+
+func (s *memoryShard) gcra(req AlgorithmRequest) *Decision {
+	key := stateKey(req.Key, "gcra")
+	interval := float64(req.Period.Milliseconds()) / float64(req.Limit)
+	now := float64(req.Now.UnixMilli())
+
+	tat, ok := s.get(key, req.Now).valueOr(nil).(float64)
+	if !ok {
+		tat = now
+	}
+	tat = math.Max(tat, now)
+
+	// A requisição é aceita se o novo TAT não ultrapassar a tolerância de rajada
+	// (cada unidade de custo ocupa um intervalo de emissão)
+	newTat := tat + interval*float64(req.cost())
+	allowAt := newTat - interval*float64(req.Burst)
+
+	if now < allowAt {
+		return &Decision{
+			Allowed:    false,
+			Remaining:  int(math.Max(0, math.Floor(float64(req.Burst)-(tat-now)/interval))),
+			ResetAfter: millis(math.Ceil(tat - now)),
+			RetryAfter: millis(math.Ceil(allowAt - now)),
+		}
+	}
+
+	reset := millis(math.Ceil(newTat - now))
+	s.set(key, newTat, req.Now.Add(reset))
+
+	return &Decision{
+		Allowed:    true,
+		Remaining:  int(math.Floor((now - allowAt) / interval)),
+		ResetAfter: reset,
+	}
+}
+
+func (s *memoryShard) leakyBucket(req AlgorithmRequest) *Decision {
+	key := stateKey(req.Key, "leaky")
+	interval := float64(req.Period.Milliseconds()) / float64(req.Limit)
+	maxWait := float64(req.MaxWait.Milliseconds())
+	now := float64(req.Now.UnixMilli())
+
+	emptyAt, ok := s.get(key, req.Now).valueOr(nil).(float64)
+	if !ok {
+		emptyAt = now
+	}
+	emptyAt = math.Max(emptyAt, now)
+
+	// A requisição só sai depois que as que estão na fila vazarem
+	delay := emptyAt - now
+	if delay > maxWait {
+		return &Decision{
+			Allowed:    false,
+			ResetAfter: millis(math.Ceil(delay)),
+			RetryAfter: millis(math.Ceil(delay - maxWait)),
+		}
+	}
+
+	// Cada unidade de custo ocupa um intervalo de saída
+	newEmptyAt := emptyAt + interval*float64(req.cost())
+	reset := millis(math.Ceil(newEmptyAt - now))
+	s.set(key, newEmptyAt, req.Now.Add(reset))
+
+	// Quantas requisições ainda cabem na fila sem exceder a espera máxima
+	remaining := 0
+	if newEmptyAt-now <= maxWait {
+		remaining = int(math.Floor((maxWait-(newEmptyAt-now))/interval)) + 1
+	}
+
+	return &Decision{
+		Allowed:    true,
+		Remaining:  remaining,
+		ResetAfter: reset,
+		Delay:      millis(math.Ceil(delay)),
+	}
+}
+
+func (m *MemoryStrategy) AcquireLease(ctx context.Context, key, leaseID string, limit int, ttl time.Duration, now time.Time) (bool, int, error) {
+	shard := m.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	leases := shard.leases(key, now)
+	if len(leases) >= limit {
+		return false, len(leases), nil
+	}
+
+	leases[leaseID] = now.Add(ttl)
+	shard.set(leaseKey(key), leases, now.Add(ttl))
+	return true, len(leases), nil
+}
+
+func (m *MemoryStrategy) RenewLease(ctx context.Context, key, leaseID string, ttl time.Duration, now time.Time) (bool, error) {
+	shard := m.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	leases := shard.leases(key, now)
+	if _, ok := leases[leaseID]; !ok {
+		return false, nil
+	}
+
+	leases[leaseID] = now.Add(ttl)
+	shard.set(leaseKey(key), leases, now.Add(ttl))
+	return true, nil
+}
+
+func (m *MemoryStrategy) ReleaseLease(ctx context.Context, key, leaseID string) error {
+	shard := m.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	entry := shard.get(leaseKey(key), time.Now())
+	if leases, ok := entry.valueOr(nil).(map[string]time.Time); ok {
+		delete(leases, leaseID)
+		if len(leases) == 0 {
+			delete(shard.entries, leaseKey(key))
+		}
+	}
+	return nil
+}
+
+// leases retorna as leases ativas da identidade, descartando as vencidas
+// (o equivalente ao ZREMRANGEBYSCORE do script Redis)
+func (s *memoryShard) leases(key string, now time.Time) map[string]time.Time {
+	leases, ok := s.get(leaseKey(key), now).valueOr(nil).(map[string]time.Time)
+	if !ok {
+		return make(map[string]time.Time)
+	}
+
+	for id, expiresAt := range leases {
+		if !now.Before(expiresAt) {
+			delete(leases, id)
+		}
+	}
+	return leases
+}
+
+// shard escolhe o shard da chave via hash FNV-1a
+func (m *MemoryStrategy) shard(key string) *memoryShard {
+	return m.shards[m.shardIndex(key)]
+}
+
+func (m *MemoryStrategy) shardIndex(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(len(m.shards)))
+}
+
+// get retorna a entrada se ela existir e não estiver expirada
+func (s *memoryShard) get(key string, now time.Time) *memoryEntry {
+	entry, ok := s.entries[key]
+	if !ok {
+		return nil
+	}
+	if entry.expired(now) {
+		delete(s.entries, key)
+		return nil
+	}
+	return entry
+}
+
+func (s *memoryShard) set(key string, value interface{}, expiresAt time.Time) {
+	s.entries[key] = &memoryEntry{value: value, expiresAt: expiresAt}
+}
+
+// janitor remove periodicamente as entradas expiradas de todos os shards
+func (m *MemoryStrategy) janitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			now := time.Now()
+			for _, shard := range m.shards {
+				shard.mu.Lock()
+				for key, entry := range shard.entries {
+					if entry.expired(now) {
+						delete(shard.entries, key)
+					}
+				}
+				shard.mu.Unlock()
+			}
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+func (e *memoryEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
+
+// intValue lê um contador; entradas ausentes valem 0
+func (e *memoryEntry) intValue() (int, bool) {
+	if e == nil {
+		return 0, false
+	}
+	count, ok := e.value.(int)
+	return count, ok
+}
+
+// valueOr retorna o valor da entrada ou o padrão quando ela não existe
+func (e *memoryEntry) valueOr(def interface{}) interface{} {
+	if e == nil {
+		return def
+	}
+	return e.value
+}
+
+// expiry converte um TTL em instante de expiração (TTL <= 0 = sem expiração,
+// como o SET do Redis)
+func expiry(now time.Time, ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return now.Add(ttl)
+}
+
+func millis(ms float64) time.Duration {
+	return time.Duration(ms * float64(time.Millisecond))
+}
@@ -0,0 +1,119 @@
+package limiter
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// QuotaPeriod é a janela de calendário de um Quota
+type QuotaPeriod string
+
+const (
+	QuotaMinute QuotaPeriod = "minute"
+	QuotaHour   QuotaPeriod = "hour"
+	QuotaDay    QuotaPeriod = "day"
+	QuotaMonth  QuotaPeriod = "month"
+)
+
+// Quota limita o total de requisições em uma janela longa (ex: 100k por dia)
+// As janelas são alinhadas ao calendário: um quota diário zera à meia-noite
+// no fuso horário do RateLimiter (WithQuotaLocation)
+type Quota struct {
+	Limit  int
+	Period QuotaPeriod
+}
+
+// QuotaWindow é a janela corrente de um Quota, resolvida pelo RateLimiter
+type QuotaWindow struct {
+	Limit int
+	ID    string    // Identifica a janela no storage (ex: "day:1735689600")
+	End   time.Time // Fim da janela, quando o quota é restaurado
+}
+
+// ErrUnsupportedQuotas indica que o storage não consegue avaliar quotas
+var ErrUnsupportedQuotas = errors.New("quotas não suportados pelo storage")
+
+// ParseQuotas converte uma lista no formato "100000/day,5000/hour" em quotas
+// String vazia resulta em nenhum quota
+func ParseQuotas(s string) ([]Quota, error) {
+	var quotas []Quota
+	seen := make(map[QuotaPeriod]bool)
+
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		limitStr, periodStr, ok := strings.Cut(part, "/")
+		if !ok {
+			return nil, fmt.Errorf("quota inválido %q: use o formato <limite>/<período>", part)
+		}
+
+		limit, err := strconv.Atoi(strings.TrimSpace(limitStr))
+		if err != nil || limit <= 0 {
+			return nil, fmt.Errorf("quota inválido %q: limite deve ser um inteiro positivo", part)
+		}
+
+		period := QuotaPeriod(strings.ToLower(strings.TrimSpace(periodStr)))
+		switch period {
+		case QuotaMinute, QuotaHour, QuotaDay, QuotaMonth:
+		default:
+			return nil, fmt.Errorf("quota inválido %q: período deve ser minute, hour, day ou month", part)
+		}
+
+		if seen[period] {
+			return nil, fmt.Errorf("quota duplicado para o período %s", period)
+		}
+		seen[period] = true
+
+		quotas = append(quotas, Quota{Limit: limit, Period: period})
+	}
+
+	return quotas, nil
+}
+
+// window calcula a janela de calendário que contém now (já no fuso desejado)
+func (q Quota) window(now time.Time) QuotaWindow {
+	var start, end time.Time
+
+	switch q.Period {
+	case QuotaMinute:
+		start = now.Truncate(time.Minute)
+		end = start.Add(time.Minute)
+	case QuotaHour:
+		// Subtrai os minutos locais em vez de usar time.Date, que é ambíguo
+		// na hora repetida do fim do horário de verão
+		start = now.Add(-time.Duration(now.Minute())*time.Minute -
+			time.Duration(now.Second())*time.Second -
+			time.Duration(now.Nanosecond()))
+		end = start.Add(time.Hour)
+	case QuotaDay:
+		start = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		end = time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+	case QuotaMonth:
+		start = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+		end = time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, now.Location())
+	}
+
+	return QuotaWindow{
+		Limit: q.Limit,
+		ID:    fmt.Sprintf("%s:%d", q.Period, start.Unix()),
+		End:   end,
+	}
+}
+
+// ttl é o tempo até o fim da janela, em ms inteiros (mínimo 1ms, já que
+// PEXPIRE 0 apagaria a chave na hora)
+func (w QuotaWindow) ttl(now time.Time) time.Duration {
+	return time.Duration(max(w.End.Sub(now).Milliseconds(), 1)) * time.Millisecond
+}
+
+// quotaKey monta a chave do contador de uma janela de quota
+// Ex: quotaKey("token:abc", "day:1735689600") → "rate:{token:abc}:q:day:1735689600"
+func quotaKey(key, windowID string) string {
+	return stateKey(key, "q:"+windowID)
+}
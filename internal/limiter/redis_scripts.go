@@ -0,0 +1,507 @@
+package limiter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+/*
+	Scripts Lua executados atomicamente pelo Redis.
+
+	redis.Script usa EVALSHA (script em cache no servidor) e faz fallback
+	automático para EVAL quando o Redis responde NOSCRIPT.
+
+	Cada script faz a decisão completa em um único round-trip:
+	verifica o bloqueio → verifica os quotas → aplica o algoritmo →
+	consome os quotas se permitiu / bloqueia se excedeu.
+
+	Em dry run (dry_run), o algoritmo decide sem gravar o consumo nem o
+	bloqueio por negação.
+
+	checkAllScript junta os mesmos algoritmos em um único script para
+	avaliar vários limites de forma atômica (CheckAll).
+
+	Todos os scripts retornam o mesmo formato:
+	{allowed (0/1), remaining, reset_ms, retry_ms, delay_ms, blocked (0/1),
+	 quota_exceeded (0/1), restante do quota 1, restante do quota 2, ...}
+*/
+
+// limitPrelude é comum a todos os algoritmos
+//
+// KEYS[1]          - chave de bloqueio da identidade
+// ARGV[1]          - tempo de bloqueio em ms (0 desativa)
+// ARGV[2]          - custo da requisição em unidades (variável cost)
+// ARGV[3]          - número de quotas (n)
+// ARGV[4]          - 1 = dry run: decide sem consumir (variável dry_run)
+// KEYS[2..n+1]     - contadores das janelas de quota
+// ARGV[5..2n+4]    - pares (limite, ms até o fim da janela) de cada quota
+// KEYS e ARGV restantes ficam disponíveis ao algoritmo como keys[] e args[]
+const limitPrelude = `
+local block_ttl = redis.call('PTTL', KEYS[1])
+local block_ms = tonumber(ARGV[1])
+
+-- Já bloqueada: nem consulta o algoritmo (-1 = bloqueio sem expiração)
+if block_ttl > 0 or block_ttl == -1 then
+	local retry = block_ttl
+	if retry < 0 then retry = block_ms end
+	return {0, 0, retry, retry, 0, 1, 0}
+end
+
+local cost = tonumber(ARGV[2])
+local quota_count = tonumber(ARGV[3])
+local dry_run = ARGV[4] == '1'
+local quota_limits = {}
+local quota_ttls = {}
+local quota_remaining = {}
+local quota_retry = 0
+
+for i = 1, quota_count do
+	quota_limits[i] = tonumber(ARGV[3 + 2 * i])
+	quota_ttls[i] = tonumber(ARGV[4 + 2 * i])
+	local used = tonumber(redis.call('GET', KEYS[1 + i]) or '0')
+	quota_remaining[i] = math.max(0, quota_limits[i] - used)
+
+	-- Quota esgotado: só volta no fim da janela (o mais distante, se vários)
+	if used + cost > quota_limits[i] then
+		quota_retry = math.max(quota_retry, quota_ttls[i])
+	end
+end
+
+if quota_retry > 0 then
+	return {0, 0, quota_retry, quota_retry, 0, 0, 1, unpack(quota_remaining)}
+end
+
+local keys = {unpack(KEYS, quota_count + 2)}
+local args = {unpack(ARGV, 2 * quota_count + 5)}
+`
+
+// limitEpilogue consome os quotas quando o algoritmo permitiu a requisição,
+// ou bloqueia a identidade quando ele negou
+const limitEpilogue = `
+local result = decide()
+if result[1] == 1 then
+	-- Em dry run nada é consumido
+	for i = 1, dry_run and 0 or quota_count do
+		-- A janela expira no seu fim de calendário
+		if redis.call('INCRBY', KEYS[1 + i], cost) == cost then
+			redis.call('PEXPIRE', KEYS[1 + i], quota_ttls[i])
+		end
+		quota_remaining[i] = math.max(0, quota_remaining[i] - cost)
+	end
+elseif block_ms > 0 and not dry_run then
+	redis.call('SET', KEYS[1], 'blocked', 'PX', block_ms)
+end
+table.insert(result, 0)
+table.insert(result, 0)
+for i = 1, quota_count do
+	table.insert(result, quota_remaining[i])
+end
+return result
+`
+
+// algorithmScript é o script de um algoritmo; o mesmo código, como função Lua
+// que recebe as próprias KEYS e ARGV, compõe o checkAllScript
+type algorithmScript struct {
+	*redis.Script
+	algorithm Algorithm
+	function  string
+}
+
+// newAlgorithmScript envolve o corpo de um algoritmo (função decide) com o
+// tratamento de bloqueio e de quotas
+func newAlgorithmScript(algorithm Algorithm, body string) *algorithmScript {
+	src := limitPrelude + "\nlocal function decide()\n" + body + "\nend\n" + limitEpilogue
+	return &algorithmScript{
+		Script:    redis.NewScript(src),
+		algorithm: algorithm,
+		function:  "function(KEYS, ARGV)\n" + src + "\nend",
+	}
+}
+
+// fixedWindowScript conta requisições em uma janela fixa
+//
+// keys[1] - contador da janela
+// args[1] - limite de requisições na janela
+// args[2] - tamanho da janela em milissegundos
+var fixedWindowScript = newAlgorithmScript(FixedWindow, `
+local limit = tonumber(args[1])
+local window = tonumber(args[2])
+
+local count = tonumber(redis.call('GET', keys[1]) or '0')
+local ttl = redis.call('PTTL', keys[1])
+if ttl < 0 then
+	ttl = window
+end
+
+if count + cost > limit then
+	return {0, math.max(0, limit - count), ttl, ttl, 0}
+end
+if dry_run then
+	return {1, limit - count - cost, ttl, 0, 0}
+end
+
+count = redis.call('INCRBY', keys[1], cost)
+-- O TTL é definido só na abertura da janela, para que ela realmente termine
+if count == cost then
+	redis.call('PEXPIRE', keys[1], window)
+end
+
+return {1, limit - count, ttl, 0, 0}
+`)
+
+// tokenBucketScript implementa o token bucket com reposição fracionária
+//
+// keys[1] - hash com o estado do bucket (tokens, ts)
+// args[1] - capacidade máxima do bucket
+// args[2] - taxa de reposição em tokens por milissegundo
+// args[3] - instante atual em milissegundos
+var tokenBucketScript = newAlgorithmScript(TokenBucket, `
+local capacity = tonumber(args[1])
+local rate = tonumber(args[2])
+local now = tonumber(args[3])
+
+local state = redis.call('HMGET', keys[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+	tokens = capacity
+	ts = now
+end
+
+-- Reposição proporcional ao tempo decorrido (pode ser fracionária)
+local elapsed = math.max(0, now - ts)
+tokens = math.min(capacity, tokens + elapsed * rate)
+
+local allowed = 0
+local retry = 0
+if tokens >= cost then
+	tokens = tokens - cost
+	allowed = 1
+else
+	-- Tempo até acumular tokens suficientes para o custo
+	retry = math.ceil((cost - tokens) / rate)
+end
+
+-- Tempo até o bucket estar cheio novamente
+local reset = math.ceil((capacity - tokens) / rate)
+
+if not dry_run then
+	redis.call('HSET', keys[1], 'tokens', tostring(tokens), 'ts', tostring(math.max(ts, now)))
+	redis.call('PEXPIRE', keys[1], math.max(reset, 1))
+end
+
+return {allowed, math.floor(tokens), reset, retry, 0}
+`)
+
+// slidingWindowLogScript mantém um log de timestamps em um sorted set
+//
+// keys[1] - sorted set com um membro por requisição (score = timestamp em ms)
+// args[1] - limite de requisições na janela
+// args[2] - tamanho da janela em milissegundos
+// args[3] - instante atual em milissegundos
+// args[4] - prefixo único dos membros desta requisição (um membro por unidade de custo)
+var slidingWindowLogScript = newAlgorithmScript(SlidingWindowLog, `
+local limit = tonumber(args[1])
+local window = tonumber(args[2])
+local now = tonumber(args[3])
+
+-- Descarta requisições que já saíram da janela deslizante
+redis.call('ZREMRANGEBYSCORE', keys[1], '-inf', now - window)
+local count = redis.call('ZCARD', keys[1])
+
+local allowed = 0
+local retry = 0
+if count + cost <= limit then
+	if not dry_run then
+		for i = 1, cost do
+			redis.call('ZADD', keys[1], now, args[4] .. ':' .. i)
+		end
+		redis.call('PEXPIRE', keys[1], window)
+	end
+	count = count + cost
+	allowed = 1
+else
+	-- Abrem vagas suficientes quando as (count + cost - limit) unidades
+	-- mais antigas saírem da janela
+	local index = count + cost - limit - 1
+	local oldest = redis.call('ZRANGE', keys[1], index, index, 'WITHSCORES')
+	if oldest[2] then
+		retry = tonumber(oldest[2]) + window - now
+	end
+end
+
+-- A janela está totalmente livre quando a requisição mais recente expirar
+local reset = 0
+local newest = redis.call('ZRANGE', keys[1], -1, -1, 'WITHSCORES')
+if newest[2] then
+	reset = tonumber(newest[2]) + window - now
+end
+
+return {allowed, math.max(0, limit - count), reset, retry, 0}
+`)
+
+// slidingWindowCounterScript pondera o contador da janela anterior pela fração
+// dela que ainda está dentro da janela deslizante
+//
+// keys[1] - contador da janela atual
+// keys[2] - contador da janela anterior
+// args[1] - limite de requisições na janela
+// args[2] - tamanho da janela em milissegundos
+// args[3] - instante atual em milissegundos
+var slidingWindowCounterScript = newAlgorithmScript(SlidingWindowCounter, `
+local limit = tonumber(args[1])
+local window = tonumber(args[2])
+local now = tonumber(args[3])
+
+local current = tonumber(redis.call('GET', keys[1]) or '0')
+local previous = tonumber(redis.call('GET', keys[2]) or '0')
+
+-- Quanto da janela anterior ainda se sobrepõe à janela deslizante
+local elapsed = now % window
+local weight = (window - elapsed) / window
+local estimate = previous * weight + current
+
+local allowed = 0
+local retry = 0
+if estimate + cost <= limit and dry_run then
+	current = current + cost
+	estimate = estimate + cost
+	allowed = 1
+elseif estimate + cost <= limit then
+	current = redis.call('INCRBY', keys[1], cost)
+	-- A janela atual ainda será usada como "anterior" na próxima
+	redis.call('PEXPIRE', keys[1], window * 2)
+	estimate = estimate + cost
+	allowed = 1
+elseif cost > limit then
+	-- Custo maior que o limite nunca cabe na janela; não há quando tentar de novo
+	retry = 0
+elseif current + cost <= limit then
+	-- Basta o peso da janela anterior cair o suficiente dentro desta janela
+	local target = window * (1 - (limit - current - cost) / previous)
+	retry = math.ceil(target - elapsed)
+else
+	-- Só na próxima janela, quando a atual passar a ser a anterior
+	local target = window * (1 - (limit - cost) / current)
+	retry = math.ceil(window - elapsed + target)
+end
+
+-- A estimativa zera quando a última janela com requisições sair por completo
+local reset = 0
+if current > 0 then
+	reset = 2 * window - elapsed
+elseif previous > 0 then
+	reset = window - elapsed
+end
+
+return {allowed, math.max(0, math.floor(limit - estimate)), reset, retry, 0}
+`)
+
+// gcraScript implementa o Generic Cell Rate Algorithm: o único estado é o
+// TAT (theoretical arrival time), o instante em que o limite estaria "vazio"
+//
+// keys[1] - TAT em milissegundos
+// args[1] - intervalo de emissão em ms (período / limite)
+// args[2] - rajada máxima tolerada
+// args[3] - instante atual em milissegundos
+var gcraScript = newAlgorithmScript(GCRA, `
+local interval = tonumber(args[1])
+local burst = tonumber(args[2])
+local now = tonumber(args[3])
+
+local tat = tonumber(redis.call('GET', keys[1]) or now)
+tat = math.max(tat, now)
+
+-- A requisição é aceita se o novo TAT não ultrapassar a tolerância de rajada
+-- (cada unidade de custo ocupa um intervalo de emissão)
+local new_tat = tat + interval * cost
+local allow_at = new_tat - interval * burst
+
+if now < allow_at then
+	-- Unidades que ainda cabem na rajada (podem existir se o custo for maior)
+	local remaining = math.max(0, math.floor(burst - (tat - now) / interval))
+	return {0, remaining, math.ceil(tat - now), math.ceil(allow_at - now), 0}
+end
+
+local reset = math.ceil(new_tat - now)
+if not dry_run then
+	redis.call('SET', keys[1], tostring(new_tat), 'PX', reset)
+end
+
+return {1, math.floor((now - allow_at) / interval), reset, 0, 0}
+`)
+
+// leakyBucketScript implementa o leaky bucket como fila: cada requisição aceita
+// ocupa um intervalo de saída e aguarda até as anteriores "vazarem"
+//
+// keys[1] - instante (ms) em que o balde estará vazio
+// args[1] - intervalo de saída em ms (período / limite)
+// args[2] - espera máxima na fila em ms
+// args[3] - instante atual em milissegundos
+var leakyBucketScript = newAlgorithmScript(LeakyBucket, `
+local interval = tonumber(args[1])
+local max_wait = tonumber(args[2])
+local now = tonumber(args[3])
+
+local empty_at = tonumber(redis.call('GET', keys[1]) or now)
+empty_at = math.max(empty_at, now)
+
+-- A requisição só sai depois que as que estão na fila vazarem
+local delay = empty_at - now
+if delay > max_wait then
+	return {0, 0, math.ceil(delay), math.ceil(delay - max_wait), 0}
+end
+
+-- Cada unidade de custo ocupa um intervalo de saída
+local new_empty_at = empty_at + interval * cost
+local reset = math.ceil(new_empty_at - now)
+if not dry_run then
+	redis.call('SET', keys[1], tostring(new_empty_at), 'PX', reset)
+end
+
+-- Quantas requisições ainda cabem na fila sem exceder a espera máxima
+local remaining = 0
+if new_empty_at - now <= max_wait then
+	remaining = math.floor((max_wait - (new_empty_at - now)) / interval) + 1
+end
+
+return {1, remaining, reset, 0, math.ceil(delay)}
+`)
+
+// checkAllScript avalia vários limites atomicamente: decide todos sem
+// consumir e só então consome, sem que outro cliente rode no meio
+//
+// ARGV[1]         - número de limites (n)
+// ARGV[2..3n+1]   - trios (algoritmo, nº de KEYS, nº de ARGV) de cada limite
+// KEYS e ARGV restantes: as KEYS e ARGV de cada limite em sequência, no
+// formato de limitPrelude
+//
+// Retorna {índice do limite que negou (0 = nenhum), tamanho do resultado,
+// resultado...}: só o do limite que negou ou, se todos permitiram, o de cada
+// limite em ordem
+var checkAllScript = newCheckAllScript(
+	fixedWindowScript,
+	tokenBucketScript,
+	slidingWindowLogScript,
+	slidingWindowCounterScript,
+	gcraScript,
+	leakyBucketScript,
+)
+
+const checkAllDriver = `
+local n = tonumber(ARGV[1])
+local checks = {}
+local next_key, next_arg = 1, 3 * n + 2
+for i = 1, n do
+	local base = 3 * i - 1
+	local key_count, arg_count = tonumber(ARGV[base + 1]), tonumber(ARGV[base + 2])
+	checks[i] = {
+		run = algorithms[ARGV[base]],
+		keys = {unpack(KEYS, next_key, next_key + key_count - 1)},
+		args = {unpack(ARGV, next_arg, next_arg + arg_count - 1)},
+	}
+	next_key = next_key + key_count
+	next_arg = next_arg + arg_count
+end
+
+local function run(check, dry_run)
+	check.args[4] = dry_run and '1' or '0'
+	return check.run(check.keys, check.args)
+end
+
+-- 1. Todos decidem sem consumir; a primeira negação encerra. Ela é avaliada
+-- de novo para valer, o que só aplica o bloqueio: negar não consome
+for i = 1, n do
+	if run(checks[i], true)[1] == 0 then
+		local result = run(checks[i], false)
+		return {i, #result, unpack(result)}
+	end
+end
+
+-- 2. Todos permitiram: cada limite é consumido
+local response = {0}
+for i = 1, n do
+	local result = run(checks[i], false)
+	table.insert(response, #result)
+	for _, value in ipairs(result) do
+		table.insert(response, value)
+	end
+end
+return response
+`
+
+// newCheckAllScript monta o checkAllScript com uma função por algoritmo
+func newCheckAllScript(scripts ...*algorithmScript) *redis.Script {
+	var src strings.Builder
+	src.WriteString("local algorithms = {}\n")
+	for _, script := range scripts {
+		fmt.Fprintf(&src, "algorithms['%s'] = %s\n", script.algorithm, script.function)
+	}
+	src.WriteString(checkAllDriver)
+	return redis.NewScript(src.String())
+}
+
+// acquireLeaseScript reserva uma vaga de concorrência
+//
+// KEYS[1] - sorted set de leases ativas (score = expiração em ms)
+// ARGV[1] - máximo de leases simultâneas
+// ARGV[2] - validade da lease em milissegundos
+// ARGV[3] - instante atual em milissegundos
+// ARGV[4] - identificador da lease
+//
+// Retorna {adquirida (0/1), leases ativas}
+var acquireLeaseScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local ttl = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+-- Descarta leases vencidas (ex: instância que caiu sem liberar a vaga)
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now)
+local count = redis.call('ZCARD', KEYS[1])
+if count >= limit then
+	return {0, count}
+end
+
+redis.call('ZADD', KEYS[1], now + ttl, ARGV[4])
+redis.call('PEXPIRE', KEYS[1], ttl)
+
+return {1, count + 1}
+`)
+
+// renewLeaseScript estende a validade de uma lease ainda ativa
+//
+// KEYS[1] - sorted set de leases ativas
+// ARGV[1] - validade da lease em milissegundos
+// ARGV[2] - instante atual em milissegundos
+// ARGV[3] - identificador da lease
+//
+// Retorna 1 se renovou, 0 se a lease já tinha expirado
+var renewLeaseScript = redis.NewScript(`
+local ttl = tonumber(ARGV[1])
+local now = tonumber(ARGV[2])
+
+local expires_at = tonumber(redis.call('ZSCORE', KEYS[1], ARGV[3]))
+if expires_at == nil or expires_at <= now then
+	return 0
+end
+
+redis.call('ZADD', KEYS[1], now + ttl, ARGV[3])
+redis.call('PEXPIRE', KEYS[1], ttl)
+
+return 1
+`)
+
+// algorithmScripts lista todos os scripts para pré-carregamento (SCRIPT LOAD)
+var algorithmScripts = []*redis.Script{
+	fixedWindowScript.Script,
+	tokenBucketScript.Script,
+	slidingWindowLogScript.Script,
+	slidingWindowCounterScript.Script,
+	gcraScript.Script,
+	leakyBucketScript.Script,
+	checkAllScript,
+	acquireLeaseScript,
+	renewLeaseScript,
+}
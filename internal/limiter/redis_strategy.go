@@ -3,17 +3,20 @@ package limiter
 import (
 	"context"
 	"fmt"
+	"math/rand/v2"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
+// RedisStrategy aceita qualquer redis.UniversalClient: nó único, Sentinel ou Cluster
 type RedisStrategy struct {
-	client *redis.Client
+	client redis.UniversalClient
 }
 
-func NewRedisStrategy(client *redis.Client) *RedisStrategy {
+func NewRedisStrategy(client redis.UniversalClient) *RedisStrategy {
 	return &RedisStrategy{
 		client: client,
 	}
@@ -48,17 +51,20 @@ func (r *RedisStrategy) Set(ctx context.Context, key string, tokens int, ttl tim
 
 func (r *RedisStrategy) Increment(ctx context.Context, key string, ttl time.Duration) (int, bool, error) {
 	/*
-		PIPELINE é uma funcionalidade do Redis que permite:
+		TRANSACTION PIPELINE (MULTI/EXEC) permite:
 		- Enviar múltiplos comandos de uma vez
 		- Executar atomicamente (tudo ou nada)
 		- Reduzir latência de rede
 
+		Um Pipeline comum apenas agrupa os comandos na rede; sem MULTI/EXEC
+		outro cliente pode executar comandos entre o INCR e o EXPIRE.
+
 		Isso é CRUCIAL para rate limiting porque evita race conditions:
 		- Cliente A e B fazem requisição no mesmo milissegundo
 		- Sem pipeline: ambos podem ver count=4, incrementar para 5
 		- Com pipeline: um vê 4→5, outro vê 5→6 (correto)
 	*/
-	pipe := r.client.Pipeline()
+	pipe := r.client.TxPipeline()
 
 	// INCR incrementa contador atomicamente
 	// Se a chave não existe, Redis cria com valor 1
@@ -84,19 +90,15 @@ func (r *RedisStrategy) Increment(ctx context.Context, key string, ttl time.Dura
 
 func (r *RedisStrategy) IsBlocked(ctx context.Context, key string) (bool, error) {
 	// Chaves de bloqueio têm prefixo "block:" para organização
-	blockKey := fmt.Sprintf("block:%s", key)
 	// EXISTS verifica se a chave existe (retorna 1 se existe, 0 se não)
-	exists, err := r.client.Exists(ctx, blockKey).Result()
+	exists, err := r.client.Exists(ctx, blockKey(key)).Result()
 	return exists > 0, err
 }
 
 func (r *RedisStrategy) Block(ctx context.Context, key string, blockTime time.Duration) error {
-	// Cria chave de bloqueio com TTL
-	blockKey := fmt.Sprintf("block:%s", key)
-
 	// SET com TTL - após blockTime, Redis remove automaticamente
 	// Valor "blocked" é apenas informativo, o importante é a existência da chave
-	err := r.client.Set(ctx, blockKey, "blocked", blockTime).Err()
+	err := r.client.Set(ctx, blockKey(key), "blocked", blockTime).Err()
 	if err != nil {
 		return fmt.Errorf("erro ao bloquear no Redis: %w", err)
 	}
@@ -109,9 +111,34 @@ func (r *RedisStrategy) GetStats(ctx context.Context, keyPrefix string) (map[str
 	/*
 		SCAN é mais eficiente que KEYS para produção
 		KEYS bloqueia o Redis, SCAN é não-bloqueante
+
+		No Cluster cada master guarda só parte das chaves, então o SCAN
+		precisa ser feito em todos eles
 	*/
 	pattern := fmt.Sprintf("%s*", keyPrefix)
-	keys, err := r.client.Keys(ctx, pattern).Result()
+
+	var (
+		mu   sync.Mutex
+		keys []string
+	)
+	scan := func(ctx context.Context, client redis.UniversalClient) error {
+		iter := client.Scan(ctx, 0, pattern, 100).Iterator()
+		for iter.Next(ctx) {
+			mu.Lock()
+			keys = append(keys, iter.Val())
+			mu.Unlock()
+		}
+		return iter.Err()
+	}
+
+	var err error
+	if cluster, ok := r.client.(*redis.ClusterClient); ok {
+		err = cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+			return scan(ctx, node)
+		})
+	} else {
+		err = scan(ctx, r.client)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -122,10 +149,218 @@ func (r *RedisStrategy) GetStats(ctx context.Context, keyPrefix string) (map[str
 	for _, key := range keys {
 		val, err := r.Get(ctx, key)
 		if err != nil {
-			continue // Pula chaves com erro
+			continue // Pula chaves com erro (ex: hashes e sorted sets dos algoritmos)
 		}
 		stats[key] = val
 	}
 
 	return stats, nil
 }
+
+// LoadScripts pré-carrega os scripts Lua no Redis (SCRIPT LOAD)
+// Opcional: se o cache do Redis for limpo, o EVALSHA cai para EVAL sozinho
+func (r *RedisStrategy) LoadScripts(ctx context.Context) error {
+	for _, script := range algorithmScripts {
+		if err := script.Load(ctx, r.client).Err(); err != nil {
+			return fmt.Errorf("erro ao carregar script Lua: %w", err)
+		}
+	}
+	return nil
+}
+
+func (r *RedisStrategy) Evaluate(ctx context.Context, req AlgorithmRequest) (*Decision, error) {
+	call, err := newScriptCall(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Run usa EVALSHA e cai para EVAL se o script não estiver em cache
+	vals, err := call.script.Run(ctx, r.client, call.keys, call.args...).Int64Slice()
+	if err != nil {
+		return nil, fmt.Errorf("erro ao executar script Redis: %w", err)
+	}
+	return parseDecision(vals)
+}
+
+// EvaluateAll avalia vários limites em um único script (checkAllScript)
+// No Cluster as identidades ficam em slots diferentes: ErrUnsupportedMultiKey
+func (r *RedisStrategy) EvaluateAll(ctx context.Context, reqs []AlgorithmRequest) ([]*Decision, int, error) {
+	if _, ok := r.client.(*redis.ClusterClient); ok {
+		return nil, -1, ErrUnsupportedMultiKey
+	}
+
+	header := []interface{}{len(reqs)}
+	var keys []string
+	var args []interface{}
+	for _, req := range reqs {
+		call, err := newScriptCall(req)
+		if err != nil {
+			return nil, -1, err
+		}
+		header = append(header, string(call.script.algorithm), len(call.keys), len(call.args))
+		keys = append(keys, call.keys...)
+		args = append(args, call.args...)
+	}
+
+	vals, err := checkAllScript.Run(ctx, r.client, keys, append(header, args...)...).Int64Slice()
+	if err != nil {
+		return nil, -1, fmt.Errorf("erro ao executar script Redis: %w", err)
+	}
+	if len(vals) < 1 {
+		return nil, -1, fmt.Errorf("resposta inesperada do script Redis: %v", vals)
+	}
+
+	// Negado: só vem o resultado do limite que negou
+	denied := int(vals[0]) - 1
+	evaluated := make([]int, 0, len(reqs))
+	if denied >= 0 {
+		evaluated = append(evaluated, denied)
+	} else {
+		for i := range reqs {
+			evaluated = append(evaluated, i)
+		}
+	}
+
+	decisions := make([]*Decision, len(reqs))
+	pos := 1
+	for _, i := range evaluated {
+		if i >= len(reqs) || pos >= len(vals) || pos+1+int(vals[pos]) > len(vals) {
+			return nil, -1, fmt.Errorf("resposta inesperada do script Redis: %v", vals)
+		}
+		size := int(vals[pos])
+		if decisions[i], err = parseDecision(vals[pos+1 : pos+1+size]); err != nil {
+			return nil, -1, err
+		}
+		pos += 1 + size
+	}
+	return decisions, denied, nil
+}
+
+// scriptCall é a execução do script de um algoritmo com KEYS e ARGV completos
+type scriptCall struct {
+	script *algorithmScript
+	keys   []string
+	args   []interface{}
+}
+
+// newScriptCall monta a chamada do script do algoritmo de req
+// Bloqueio, custo e quotas vão sempre nas primeiras posições (limitPrelude)
+func newScriptCall(req AlgorithmRequest) (scriptCall, error) {
+	now := req.Now.UnixMilli()
+
+	var call scriptCall
+	switch req.Algorithm {
+	case FixedWindow, "":
+		call = scriptCall{fixedWindowScript,
+			[]string{windowKey(req.Key)},
+			[]interface{}{req.Limit, req.Period.Milliseconds()}}
+	case TokenBucket:
+		// Taxa em tokens/ms permite reposição fracionária entre requisições
+		rate := float64(req.Limit) / float64(req.Period.Milliseconds())
+		call = scriptCall{tokenBucketScript,
+			[]string{stateKey(req.Key, "tb")},
+			[]interface{}{req.Burst, strconv.FormatFloat(rate, 'f', -1, 64), now}}
+	case SlidingWindowLog:
+		call = scriptCall{slidingWindowLogScript,
+			[]string{stateKey(req.Key, "log")},
+			[]interface{}{req.Limit, req.Period.Milliseconds(), now, logMember(req.Now)}}
+	case SlidingWindowCounter:
+		// Cada janela tem seu próprio contador, identificado pelo índice da janela
+		window := req.Period.Milliseconds()
+		index := now / window
+		call = scriptCall{slidingWindowCounterScript,
+			[]string{
+				stateKey(req.Key, strconv.FormatInt(index, 10)),
+				stateKey(req.Key, strconv.FormatInt(index-1, 10)),
+			},
+			[]interface{}{req.Limit, window, now}}
+	case GCRA:
+		interval := float64(req.Period.Milliseconds()) / float64(req.Limit)
+		call = scriptCall{gcraScript,
+			[]string{stateKey(req.Key, "gcra")},
+			[]interface{}{strconv.FormatFloat(interval, 'f', -1, 64), req.Burst, now}}
+	case LeakyBucket:
+		interval := float64(req.Period.Milliseconds()) / float64(req.Limit)
+		call = scriptCall{leakyBucketScript,
+			[]string{stateKey(req.Key, "leaky")},
+			[]interface{}{strconv.FormatFloat(interval, 'f', -1, 64), req.MaxWait.Milliseconds(), now}}
+	default:
+		return scriptCall{}, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, req.Algorithm)
+	}
+
+	keys := []string{blockKey(req.Key)}
+	dryRun := 0
+	if req.DryRun {
+		dryRun = 1
+	}
+	args := []interface{}{req.BlockTime.Milliseconds(), req.cost(), len(req.Quotas), dryRun}
+	for _, quota := range req.Quotas {
+		keys = append(keys, quotaKey(req.Key, quota.ID))
+		args = append(args, quota.Limit, quota.ttl(req.Now).Milliseconds())
+	}
+	call.keys = append(keys, call.keys...)
+	call.args = append(args, call.args...)
+	return call, nil
+}
+
+// parseDecision converte o retorno de um script de algoritmo em Decision
+func parseDecision(vals []int64) (*Decision, error) {
+	if len(vals) < 7 {
+		return nil, fmt.Errorf("resposta inesperada do script Redis: %v", vals)
+	}
+
+	decision := &Decision{
+		Allowed:       vals[0] == 1,
+		Remaining:     int(vals[1]),
+		ResetAfter:    time.Duration(vals[2]) * time.Millisecond,
+		RetryAfter:    time.Duration(vals[3]) * time.Millisecond,
+		Delay:         time.Duration(vals[4]) * time.Millisecond,
+		Blocked:       vals[5] == 1,
+		QuotaExceeded: vals[6] == 1,
+	}
+	for _, remaining := range vals[7:] {
+		decision.Quotas = append(decision.Quotas, int(remaining))
+	}
+
+	return decision, nil
+}
+
+func (r *RedisStrategy) AcquireLease(ctx context.Context, key, leaseID string, limit int, ttl time.Duration, now time.Time) (bool, int, error) {
+	vals, err := acquireLeaseScript.Run(ctx, r.client,
+		[]string{leaseKey(key)},
+		limit, ttl.Milliseconds(), now.UnixMilli(), leaseID).Int64Slice()
+	if err != nil {
+		return false, 0, fmt.Errorf("erro ao adquirir lease no Redis: %w", err)
+	}
+	if len(vals) < 2 {
+		return false, 0, fmt.Errorf("resposta inesperada do script Redis: %v", vals)
+	}
+
+	return vals[0] == 1, int(vals[1]), nil
+}
+
+func (r *RedisStrategy) RenewLease(ctx context.Context, key, leaseID string, ttl time.Duration, now time.Time) (bool, error) {
+	renewed, err := renewLeaseScript.Run(ctx, r.client,
+		[]string{leaseKey(key)},
+		ttl.Milliseconds(), now.UnixMilli(), leaseID).Int64()
+	if err != nil {
+		return false, fmt.Errorf("erro ao renovar lease no Redis: %w", err)
+	}
+
+	return renewed == 1, nil
+}
+
+func (r *RedisStrategy) ReleaseLease(ctx context.Context, key, leaseID string) error {
+	// ZREM é atômico por si só; a chave expira sozinha quando esvazia
+	if err := r.client.ZRem(ctx, leaseKey(key), leaseID).Err(); err != nil {
+		return fmt.Errorf("erro ao liberar lease no Redis: %w", err)
+	}
+
+	return nil
+}
+
+// logMember gera o prefixo único dos membros do sorted set do sliding window log
+// O sufixo aleatório evita colisões entre instâncias no mesmo nanossegundo
+func logMember(now time.Time) string {
+	return fmt.Sprintf("%d-%d", now.UnixNano(), rand.Uint64())
+}
@@ -23,3 +23,38 @@ type StorageStrategy interface {
 	// Block bloqueia uma chave por um período
 	Block(ctx context.Context, key string, blockTime time.Duration) error
 }
+
+// AlgorithmStorage é implementada por storages capazes de executar algoritmos
+// de rate limiting de forma atômica (ex: scripts Lua no Redis)
+type AlgorithmStorage interface {
+	// Evaluate faz a decisão completa em uma operação atômica: verifica o
+	// bloqueio, aplica o algoritmo de req (consumindo a requisição se permitida)
+	// e bloqueia a identidade por req.BlockTime se o limite foi excedido
+	Evaluate(ctx context.Context, req AlgorithmRequest) (*Decision, error)
+}
+
+// MultiAlgorithmStorage é implementada por storages capazes de avaliar vários
+// limites na mesma operação atômica (usado por CheckAll)
+type MultiAlgorithmStorage interface {
+	// EvaluateAll decide todos os pedidos sem consumir; se algum negar, só ele
+	// é avaliado para valer (aplicando o bloqueio) e o índice dele é retornado.
+	// Se todos permitirem, todos são consumidos e o índice é -1. Nenhuma outra
+	// avaliação acontece entre a decisão e o consumo
+	// Retorna ErrUnsupportedMultiKey se as chaves não puderem ser avaliadas
+	// juntas (ex: Redis Cluster, com as identidades em slots diferentes)
+	EvaluateAll(ctx context.Context, reqs []AlgorithmRequest) ([]*Decision, int, error)
+}
+
+// ConcurrencyStorage é implementada por storages capazes de controlar
+// requisições simultâneas por identidade através de leases com expiração
+type ConcurrencyStorage interface {
+	// AcquireLease reserva uma vaga para leaseID se a identidade tiver menos de
+	// limit leases ativas. Retorna se conseguiu e quantas leases estão ativas
+	AcquireLease(ctx context.Context, key, leaseID string, limit int, ttl time.Duration, now time.Time) (bool, int, error)
+
+	// RenewLease estende a validade da lease; retorna false se ela já expirou
+	RenewLease(ctx context.Context, key, leaseID string, ttl time.Duration, now time.Time) (bool, error)
+
+	// ReleaseLease libera a vaga ocupada pela lease
+	ReleaseLease(ctx context.Context, key, leaseID string) error
+}
@@ -0,0 +1,203 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+/*
+	Extração do IP do cliente atrás de proxies.
+
+	Headers como X-Forwarded-For são escritos por quem faz a requisição, então
+	só são lidos quando a conexão vem de um proxy confiável
+	(RATE_LIMIT_TRUSTED_PROXIES). Cada proxy acrescenta à direita o endereço de
+	quem o chamou, então a lista é percorrida da direita para a esquerda: o
+	primeiro endereço que não é de um proxy confiável é o cliente. O que estiver
+	mais à esquerda pode ter sido inventado pelo cliente e é ignorado.
+
+	  RemoteAddr: 10.0.0.2 (LB confiável)
+	  X-Forwarded-For: 1.2.3.4, 203.0.113.9, 10.0.0.1
+	                   ^ forjado ^ cliente    ^ proxy confiável
+*/
+
+// ClientIPKey é a chave do contexto do Gin onde o middleware deixa o IP do
+// cliente usado nos limites
+const ClientIPKey = "ratelimit.client_ip"
+
+// ProxyHeader é o header que os proxies confiáveis usam para repassar o cliente
+type ProxyHeader string
+
+const (
+	HeaderXForwardedFor ProxyHeader = "X-Forwarded-For"
+	HeaderForwarded     ProxyHeader = "Forwarded" // RFC 7239
+	HeaderXRealIP       ProxyHeader = "X-Real-IP" // Um único IP, definido pelo proxy
+)
+
+// ParseProxyHeader valida RATE_LIMIT_CLIENT_IP_HEADER (vazio = X-Forwarded-For)
+func ParseProxyHeader(s string) (ProxyHeader, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return HeaderXForwardedFor, nil
+	}
+	for _, header := range []ProxyHeader{HeaderXForwardedFor, HeaderForwarded, HeaderXRealIP} {
+		if strings.EqualFold(s, string(header)) {
+			return header, nil
+		}
+	}
+	return "", fmt.Errorf("RATE_LIMIT_CLIENT_IP_HEADER inválido: %q (use X-Forwarded-For, Forwarded ou X-Real-IP)", s)
+}
+
+// ParseTrustedProxies valida RATE_LIMIT_TRUSTED_PROXIES: CIDRs ou IPs separados
+// por vírgula (ex: "10.0.0.0/8,fd00::/8,127.0.0.1")
+func ParseTrustedProxies(s string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		if strings.Contains(part, "/") {
+			prefix, err := netip.ParsePrefix(part)
+			if err != nil {
+				return nil, fmt.Errorf("RATE_LIMIT_TRUSTED_PROXIES: CIDR inválido %q", part)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+
+		addr, err := netip.ParseAddr(part)
+		if err != nil {
+			return nil, fmt.Errorf("RATE_LIMIT_TRUSTED_PROXIES: IP inválido %q", part)
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// ClientIPResolver extrai o IP do cliente, confiando nos headers só quando
+// eles vêm de um proxy confiável
+type ClientIPResolver struct {
+	trusted []netip.Prefix
+	header  ProxyHeader
+}
+
+// NewClientIPResolver cria o resolver a partir de RATE_LIMIT_TRUSTED_PROXIES e
+// RATE_LIMIT_CLIENT_IP_HEADER; sem proxies confiáveis, vale só o RemoteAddr
+func NewClientIPResolver(trustedProxies, header string) (*ClientIPResolver, error) {
+	trusted, err := ParseTrustedProxies(trustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	proxyHeader, err := ParseProxyHeader(header)
+	if err != nil {
+		return nil, err
+	}
+	return &ClientIPResolver{trusted: trusted, header: proxyHeader}, nil
+}
+
+// ClientIP retorna o IP do cliente que fez a requisição
+func (r *ClientIPResolver) ClientIP(req *http.Request) string {
+	remote, ok := remoteAddr(req.RemoteAddr)
+	if !ok {
+		return req.RemoteAddr // Ex: testes sem RemoteAddr
+	}
+	if r == nil || !r.isTrusted(remote) {
+		return remote.String() // Conexão direta: headers podem ser forjados
+	}
+
+	var hops []string
+	switch r.header {
+	case HeaderForwarded:
+		hops = forwardedFor(req.Header.Values("Forwarded"))
+	case HeaderXRealIP:
+		hops = req.Header.Values("X-Real-IP")
+	default:
+		for _, value := range req.Header.Values("X-Forwarded-For") {
+			hops = append(hops, strings.Split(value, ",")...)
+		}
+	}
+
+	// Da direita para a esquerda, pulando os proxies confiáveis
+	client := remote
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, ok := parseHop(hops[i])
+		if !ok {
+			break // Entrada inválida: nada à esquerda dela é confiável
+		}
+		client = addr
+		if !r.isTrusted(addr) {
+			break
+		}
+	}
+	return client.String()
+}
+
+func (r *ClientIPResolver) isTrusted(addr netip.Addr) bool {
+	for _, prefix := range r.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedFor extrai os parâmetros for= do header Forwarded (RFC 7239), um
+// por proxy, na ordem em que foram acrescentados:
+//
+//	Forwarded: for=192.0.2.60;proto=http, for="[2001:db8:cafe::17]:4711"
+func forwardedFor(values []string) []string {
+	var hops []string
+	for _, value := range values {
+		for _, element := range strings.Split(value, ",") {
+			hop := "" // Elemento sem for= é inválido e interrompe a busca
+			for _, pair := range strings.Split(element, ";") {
+				key, val, found := strings.Cut(strings.TrimSpace(pair), "=")
+				if found && strings.EqualFold(key, "for") {
+					hop = val
+				}
+			}
+			hops = append(hops, hop)
+		}
+	}
+	return hops
+}
+
+// parseHop aceita IP, IP:porta, [IPv6] e [IPv6]:porta, com ou sem aspas
+// Valores como "unknown" ou identificadores ofuscados (RFC 7239) são inválidos
+func parseHop(hop string) (netip.Addr, bool) {
+	hop = strings.Trim(strings.TrimSpace(hop), `"`)
+
+	if strings.HasPrefix(hop, "[") {
+		end := strings.Index(hop, "]")
+		if end < 0 {
+			return netip.Addr{}, false
+		}
+		hop = hop[1:end]
+	}
+
+	if addr, err := netip.ParseAddr(hop); err == nil {
+		return addr.Unmap().WithZone(""), true
+	}
+	if addrPort, err := netip.ParseAddrPort(hop); err == nil {
+		return addrPort.Addr().Unmap().WithZone(""), true
+	}
+	return netip.Addr{}, false
+}
+
+// remoteAddr lê o IP da conexão (formato IP:porta)
+func remoteAddr(remote string) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(remote)
+	if err != nil {
+		host = remote // Sem porta
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap().WithZone(""), true
+}
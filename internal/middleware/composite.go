@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/config"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/limiter"
+)
+
+// CompositeLimit é um limite avaliado junto com o do token (RATE_LIMIT_COMPOSITE)
+// Sem limites compostos, o token substitui o limite por IP
+type CompositeLimit string
+
+const (
+	CompositeIP      CompositeLimit = "ip"       // O limite por IP vale também para quem tem token
+	CompositeTokenIP CompositeLimit = "token_ip" // Limite de cada par token + IP (RATE_LIMIT_TOKEN_IP_*)
+)
+
+// ParseCompositeLimits valida RATE_LIMIT_COMPOSITE (ex: "ip,token_ip")
+func ParseCompositeLimits(cfg *config.Config) ([]CompositeLimit, error) {
+	var limits []CompositeLimit
+	for _, part := range strings.Split(cfg.RateLimitComposite, ",") {
+		limit := CompositeLimit(strings.ToLower(strings.TrimSpace(part)))
+		switch limit {
+		case "":
+			continue
+		case CompositeIP:
+		case CompositeTokenIP:
+			if cfg.RateLimitTokenIPRPS <= 0 {
+				return nil, fmt.Errorf("RATE_LIMIT_COMPOSITE: token_ip requer RATE_LIMIT_TOKEN_IP_RPS maior que zero")
+			}
+		default:
+			return nil, fmt.Errorf("RATE_LIMIT_COMPOSITE: limite inválido %q (use ip ou token_ip)", part)
+		}
+		limits = append(limits, limit)
+	}
+	return limits, nil
+}
+
+// compositeChecks monta os limites avaliados junto com o da identidade
+// (identity = chave do token ou do JWT, ex: "token:<hash>")
+func compositeChecks(limits *limitSet, identity, ipKey string) []limiter.LimitCheck {
+	cfg := limits.Config
+
+	var checks []limiter.LimitCheck
+	for _, limit := range limits.composite {
+		switch limit {
+		case CompositeIP:
+			// Mesmo contador das requisições sem token
+			checks = append(checks, limiter.LimitCheck{Key: fmt.Sprintf("ip:%s", ipKey), Config: ipLimit(limits)})
+		case CompositeTokenIP:
+			checks = append(checks, limiter.LimitCheck{
+				Key: fmt.Sprintf("token_ip:%s:%s", identity, ipKey),
+				Config: limiter.LimitConfig{
+					RPS:       cfg.RateLimitTokenIPRPS,
+					BlockTime: defaultBlockTime(cfg, cfg.RateLimitTokenIPBlockTime),
+					Algorithm: limiter.Algorithm(cfg.RateLimitAlgorithm),
+					MaxWait:   cfg.RateLimitMaxWait,
+				},
+			})
+		}
+	}
+	return checks
+}
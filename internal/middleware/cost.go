@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// CostFunc calcula quantas unidades do limite uma requisição consome
+// Valores menores que 1 são tratados como 1
+type CostFunc func(c *gin.Context) int
+
+// RouteCosts define custos fixos por rota, usando o template do Gin
+// ("/users/:id"), com ou sem método: "POST /search" tem precedência sobre "/search"
+// Rotas não listadas custam 1
+func RouteCosts(costs map[string]int) CostFunc {
+	return func(c *gin.Context) int {
+		route := c.FullPath()
+		if cost, ok := costs[c.Request.Method+" "+route]; ok {
+			return cost
+		}
+		if cost, ok := costs[route]; ok {
+			return cost
+		}
+		return 1
+	}
+}
+
+// PayloadCost cobra uma unidade a cada bytesPerUnit bytes do corpo (arredondado
+// para cima), ex: uploads em lote. Corpos sem Content-Length custam 1
+func PayloadCost(bytesPerUnit int64) CostFunc {
+	return func(c *gin.Context) int {
+		size := c.Request.ContentLength
+		if size <= 0 || bytesPerUnit <= 0 {
+			return 1
+		}
+		return int((size + bytesPerUnit - 1) / bytesPerUnit)
+	}
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"fmt"
+
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/limiter"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/rules"
+)
+
+/*
+	Limites agregados: somam as requisições de todos os clientes, para
+	proteger uma dependência independentemente de quantos IPs ou tokens
+	estão chamando.
+
+	global          → RATE_LIMIT_GLOBAL_RPS, todas as rotas
+	global:<regra>  → bloco global de uma regra do arquivo de regras
+
+	A chave fica no storage compartilhado (Redis), então o limite vale para
+	todas as instâncias somadas. Com STORAGE_DRIVER=memory, cada instância
+	conta apenas as próprias requisições.
+*/
+
+// globalChecks monta os limites agregados que valem para a requisição
+// (rule = regra da rota, ou nil)
+func globalChecks(limits *limitSet, rule *rules.Rule) []limiter.LimitCheck {
+	cfg := limits.Config
+
+	var checks []limiter.LimitCheck
+	if rule != nil && rule.Global != nil {
+		checks = append(checks, limiter.LimitCheck{Key: fmt.Sprintf("global:%s", rule.Name), Config: *rule.Global})
+	}
+	if cfg.RateLimitGlobalRPS > 0 {
+		// Sem bloqueio: bloquear a chave global negaria tudo a todos
+		checks = append(checks, limiter.LimitCheck{
+			Key: "global",
+			Config: limiter.LimitConfig{
+				RPS:       cfg.RateLimitGlobalRPS,
+				Algorithm: limiter.Algorithm(cfg.RateLimitAlgorithm),
+				Burst:     cfg.RateLimitGlobalBurst,
+				MaxWait:   cfg.RateLimitMaxWait,
+			},
+		})
+	}
+	return checks
+}
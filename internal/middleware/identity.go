@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"fmt"
+
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/jwtauth"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/rules"
+	"github.com/gin-gonic/gin"
+)
+
+// ClaimsKey é a chave do contexto do Gin onde um middleware de autenticação
+// anterior deixa as claims do usuário (map[string]any), usadas pelas
+// identidades do tipo claim. Com RATE_LIMIT_JWT_JWKS_FILE, o próprio
+// middleware preenche a chave com os claims do bearer JWT
+const ClaimsKey = "ratelimit.claims"
+
+// jwtIdentity verifica o bearer JWT e retorna a chave do limiter e o plano
+// indicados nos claims; token ausente, inválido ou sem o claim da chave
+// retorna "" e o cliente é limitado por IP
+// Os claims de um token válido ficam em ClaimsKey, para as identidades claim
+func jwtIdentity(c *gin.Context, limits *limitSet) (string, string) {
+	token, ok := jwtauth.BearerToken(c.Request)
+	if !ok {
+		return "", ""
+	}
+	claims, err := limits.JWT.Verify(token)
+	if err != nil {
+		return "", "" // Token inválido conta como anônimo, sem log por requisição
+	}
+	c.Set(ClaimsKey, claims)
+
+	cfg := limits.Config
+	subject := claimString(claims, cfg.RateLimitJWTKeyClaim)
+	if subject == "" {
+		return "", ""
+	}
+	return fmt.Sprintf("jwt:%s:%s", cfg.RateLimitJWTKeyClaim, subject), claimString(claims, cfg.RateLimitJWTPlanClaim)
+}
+
+// claimString lê um claim como texto ("" se ausente)
+func claimString(claims map[string]any, name string) string {
+	if name == "" {
+		return ""
+	}
+	value, ok := claims[name]
+	if !ok || value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+// ruleLimit retorna o primeiro limite da regra cuja identidade está presente
+func ruleLimit(c *gin.Context, rule *rules.Rule) (rules.Limit, string, bool) {
+	for _, limit := range rule.Limits {
+		if value, ok := identityExtractor(limit.Identity).ExtractKey(c); ok {
+			return limit, value, true
+		}
+	}
+	return rules.Limit{}, "", false
+}
+
+// identityExtractor retorna o extrator de uma identidade das regras
+func identityExtractor(identity rules.Identity) KeyExtractor {
+	switch identity.Type {
+	case rules.IdentityIP:
+		return IPKey() // Sempre presente: é a identidade de último recurso
+	case rules.IdentityHeader:
+		return HeaderKey(identity.Header)
+	case rules.IdentityQuery:
+		return QueryKey(identity.Query)
+	case rules.IdentityCookie:
+		return CookieKey(identity.Cookie)
+	case rules.IdentityParam:
+		return PathParamKey(identity.Param)
+	case rules.IdentityClaim:
+		return ClaimKey(identity.Claim)
+	}
+	return KeyExtractorFunc(func(*gin.Context) (string, bool) { return "", false })
+}
@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"fmt"
+	"net/netip"
+)
+
+/*
+	Agregação de IPs por prefixo.
+
+	Um cliente IPv6 costuma receber uma /64 inteira e pode trocar de endereço a
+	cada requisição; limitando por endereço, cada troca ganharia um contador
+	novo. Com RATE_LIMIT_IPV6_PREFIX=64, todos os endereços da /64 dividem a
+	mesma chave:
+
+	  2001:db8:1:2:a::1, 2001:db8:1:2:b::9 → ip:2001:db8:1:2::/64
+
+	O mesmo vale para IPv4 (ex: RATE_LIMIT_IPV4_PREFIX=24). IPv4 mapeado em IPv6
+	(::ffff:1.2.3.4) é tratado como IPv4.
+*/
+
+// IPPrefixes são os tamanhos de prefixo usados nas chaves de IP
+// Zero (ou o tamanho do endereço) usa o endereço completo
+type IPPrefixes struct {
+	IPv4 int
+	IPv6 int
+}
+
+// NewIPPrefixes valida RATE_LIMIT_IPV4_PREFIX (0 a 32) e RATE_LIMIT_IPV6_PREFIX (0 a 128)
+// Zero não é a /0 (todos os clientes em um único contador): desativa a
+// agregação, como 32 / 128
+func NewIPPrefixes(ipv4, ipv6 int) (IPPrefixes, error) {
+	if ipv4 < 0 || ipv4 > 32 {
+		return IPPrefixes{}, fmt.Errorf("RATE_LIMIT_IPV4_PREFIX inválido: %d (use 0 a 32; 0 = endereço completo)", ipv4)
+	}
+	if ipv6 < 0 || ipv6 > 128 {
+		return IPPrefixes{}, fmt.Errorf("RATE_LIMIT_IPV6_PREFIX inválido: %d (use 0 a 128; 0 = endereço completo)", ipv6)
+	}
+	return IPPrefixes{IPv4: ipv4, IPv6: ipv6}, nil
+}
+
+// Key retorna o valor usado na chave de limite do IP: o endereço, ou o
+// prefixo em notação CIDR quando agregado
+func (p IPPrefixes) Key(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ip // Ex: RemoteAddr vazio em testes
+	}
+	addr = addr.Unmap().WithZone("")
+
+	bits := p.IPv6
+	if addr.Is4() {
+		bits = p.IPv4
+	}
+	if bits == 0 || bits >= addr.BitLen() {
+		return addr.String()
+	}
+
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return addr.String()
+	}
+	return prefix.String()
+}
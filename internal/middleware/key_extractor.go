@@ -0,0 +1,176 @@
+package middleware
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+/*
+	Extratores de chave: de onde vem o valor que identifica quem está sendo
+	limitado. Os mesmos extratores atendem as identidades das regras e a
+	chave padrão (WithKeyExtractor), ex: limitar por tenant + rota:
+
+	  middleware.WithKeyExtractor(middleware.CompositeKey(
+	      middleware.HeaderKey("X-Tenant-ID"),
+	      middleware.RouteKey(),
+	  ))
+
+	No servidor, a chave padrão vem de RATE_LIMIT_KEY (ver ParseKeyExtractor):
+
+	  RATE_LIMIT_KEY=header:X-Tenant-ID,route
+*/
+
+// ipKeyKey guarda no contexto o IP já agregado por prefixo (ver IPPrefixes)
+const ipKeyKey = "ratelimit.ip_key"
+
+// KeyExtractor deriva da requisição o valor de uma identidade
+// Retorna false quando a identidade não está presente na requisição
+type KeyExtractor interface {
+	ExtractKey(c *gin.Context) (string, bool)
+}
+
+// KeyExtractorFunc adapta uma função a KeyExtractor
+type KeyExtractorFunc func(c *gin.Context) (string, bool)
+
+func (f KeyExtractorFunc) ExtractKey(c *gin.Context) (string, bool) {
+	return f(c)
+}
+
+// HeaderKey usa o valor de um header
+func HeaderKey(name string) KeyExtractor {
+	return KeyExtractorFunc(func(c *gin.Context) (string, bool) {
+		value := c.GetHeader(name)
+		return value, value != ""
+	})
+}
+
+// QueryKey usa o valor de um parâmetro da query string
+func QueryKey(name string) KeyExtractor {
+	return KeyExtractorFunc(func(c *gin.Context) (string, bool) {
+		value := c.Query(name)
+		return value, value != ""
+	})
+}
+
+// CookieKey usa o valor de um cookie
+func CookieKey(name string) KeyExtractor {
+	return KeyExtractorFunc(func(c *gin.Context) (string, bool) {
+		value, err := c.Cookie(name)
+		return value, err == nil && value != ""
+	})
+}
+
+// PathParamKey usa um parâmetro da rota do Gin (ex: "id" em /users/:id)
+func PathParamKey(name string) KeyExtractor {
+	return KeyExtractorFunc(func(c *gin.Context) (string, bool) {
+		value := c.Param(name)
+		return value, value != ""
+	})
+}
+
+// ClaimKey usa um claim do usuário autenticado (ver ClaimsKey)
+func ClaimKey(name string) KeyExtractor {
+	return KeyExtractorFunc(func(c *gin.Context) (string, bool) {
+		raw, _ := c.Get(ClaimsKey)
+		claims, ok := raw.(map[string]any)
+		if !ok {
+			return "", false
+		}
+		value := claimString(claims, name)
+		return value, value != ""
+	})
+}
+
+// RouteKey usa o padrão da rota do Gin (ex: /users/:id), não o caminho
+// requisitado, para que cada rota tenha um único contador
+// Requisições sem rota (404) não têm a identidade
+func RouteKey() KeyExtractor {
+	return KeyExtractorFunc(func(c *gin.Context) (string, bool) {
+		route := c.FullPath()
+		return route, route != ""
+	})
+}
+
+// IPKey usa o IP do cliente, resolvido pelos proxies confiáveis e agregado
+// por prefixo; sempre presente depois que o middleware resolveu o IP
+func IPKey() KeyExtractor {
+	return KeyExtractorFunc(func(c *gin.Context) (string, bool) {
+		value, ok := c.Get(ipKeyKey)
+		ip, _ := value.(string)
+		return ip, ok
+	})
+}
+
+// CompositeKey junta os valores de vários extratores com ":"; falta de
+// qualquer um deles = identidade ausente
+// ":" e "%" dentro dos valores são escapados, para que partes diferentes
+// nunca formem a mesma chave
+func CompositeKey(extractors ...KeyExtractor) KeyExtractor {
+	return KeyExtractorFunc(func(c *gin.Context) (string, bool) {
+		if len(extractors) == 0 {
+			return "", false
+		}
+		parts := make([]string, 0, len(extractors))
+		for _, extractor := range extractors {
+			value, ok := extractor.ExtractKey(c)
+			if !ok {
+				return "", false
+			}
+			parts = append(parts, compositeEscaper.Replace(value))
+		}
+		return strings.Join(parts, ":"), true
+	})
+}
+
+var compositeEscaper = strings.NewReplacer("%", "%25", ":", "%3A")
+
+// ParseKeyExtractor valida RATE_LIMIT_KEY: extratores separados por vírgula,
+// combinados com CompositeKey (ex: "header:X-Tenant-ID,route")
+// Vazio = sem extrator (nil), vale a chave padrão
+func ParseKeyExtractor(spec string) (KeyExtractor, error) {
+	var extractors []KeyExtractor
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		kind, name, _ := strings.Cut(part, ":")
+		kind = strings.ToLower(kind)
+
+		var extractor KeyExtractor
+		switch kind {
+		case "ip":
+			extractor = IPKey()
+		case "route":
+			extractor = RouteKey()
+		case "header":
+			extractor = HeaderKey(name)
+		case "query":
+			extractor = QueryKey(name)
+		case "cookie":
+			extractor = CookieKey(name)
+		case "param":
+			extractor = PathParamKey(name)
+		case "claim":
+			extractor = ClaimKey(name)
+		default:
+			return nil, fmt.Errorf("RATE_LIMIT_KEY: extrator inválido %q (use ip, route, header:, query:, cookie:, param: ou claim:)", part)
+		}
+		if name == "" && kind != "ip" && kind != "route" {
+			return nil, fmt.Errorf("RATE_LIMIT_KEY: %q requer um nome (ex: %s:X-Tenant-ID)", part, kind)
+		}
+		extractors = append(extractors, extractor)
+	}
+
+	switch len(extractors) {
+	case 0:
+		return nil, nil
+	case 1:
+		return extractors[0], nil
+	default:
+		return CompositeKey(extractors...), nil
+	}
+}
@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"fmt"
+
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/access"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/config"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/jwtauth"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/rules"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/tokens"
+	"github.com/redis/go-redis/v9"
+)
+
+// LoadLimits carrega os arquivos de regras, de listas e de planos indicados na configuração
+// Usado na inicialização (WithLimits) e no reload; o hasher é o criado na
+// inicialização: trocar o segredo exige reinício
+func LoadLimits(cfg *config.Config, redisClient redis.UniversalClient, hasher *tokens.Hasher) (Limits, error) {
+	limits := Limits{Config: cfg}
+
+	var err error
+	if cfg.RateLimitRulesFile != "" {
+		if limits.Rules, err = rules.LoadFile(cfg.RateLimitRulesFile); err != nil {
+			return limits, err
+		}
+	}
+
+	if cfg.RateLimitTokensRedisHash != "" && cfg.RateLimitTokensFile == "" {
+		return limits, fmt.Errorf("RATE_LIMIT_TOKENS_REDIS_HASH requer RATE_LIMIT_TOKENS_FILE com os planos")
+	}
+	if cfg.RateLimitAccessFile != "" {
+		if limits.Access, err = access.LoadFile(cfg.RateLimitAccessFile, hasher); err != nil {
+			return limits, err
+		}
+	}
+
+	if cfg.RateLimitJWTJWKSFile != "" {
+		if cfg.RateLimitJWTKeyClaim == "" {
+			return limits, fmt.Errorf("RATE_LIMIT_JWT_KEY_CLAIM não pode ser vazio com RATE_LIMIT_JWT_JWKS_FILE")
+		}
+		keys, err := jwtauth.LoadJWKS(cfg.RateLimitJWTJWKSFile)
+		if err != nil {
+			return limits, err
+		}
+		limits.JWT = jwtauth.NewVerifier(keys,
+			jwtauth.WithIssuer(cfg.RateLimitJWTIssuer),
+			jwtauth.WithAudience(cfg.RateLimitJWTAudience),
+		)
+	}
+
+	if cfg.RateLimitTokensFile != "" {
+		opts := []tokens.Option{tokens.WithHasher(hasher)}
+		if cfg.RateLimitTokensRedisHash != "" {
+			if redisClient == nil {
+				return limits, fmt.Errorf("RATE_LIMIT_TOKENS_REDIS_HASH requer STORAGE_DRIVER=redis")
+			}
+			opts = append(opts, tokens.WithRedisHash(redisClient, cfg.RateLimitTokensRedisHash))
+		}
+		if limits.Tokens, err = tokens.LoadFile(cfg.RateLimitTokensFile, opts...); err != nil {
+			return limits, err
+		}
+	}
+
+	return limits, nil
+}
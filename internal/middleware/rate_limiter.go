@@ -1,27 +1,257 @@
 package middleware
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"net"
+	"log"
+	"math"
 	"net/http"
-	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/config"
 	"github.com/gin-gonic/gin"
 
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/access"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/jwtauth"
 	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/limiter"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/rules"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/tokens"
 )
 
 type RateLimiterMiddleware struct {
-	limiter *limiter.RateLimiter
-	config  *config.Config
+	limiter     *limiter.RateLimiter
+	concurrency *limiter.ConcurrencyLimiter
+	cost        CostFunc
+	tokenStore  *tokens.Store
+	hasher      *tokens.Hasher
+	accessStore *access.Store
+	extractor   KeyExtractor
+	breaker     *limiter.CircuitBreaker
+
+	// Proxies confiáveis da inicialização: o Gin só os aplica no startup,
+	// então o Reload mantém os mesmos (RATE_LIMIT_TRUSTED_PROXIES requer reinício)
+	trustedProxies string
+
+	// Limites em uso, trocados atomicamente pelo Reload
+	limits atomic.Pointer[limitSet]
+}
+
+// Limits são os limites que podem ser trocados sem reiniciar o servidor
+type Limits struct {
+	Config *config.Config
+	Rules  *rules.Engine     // Opcional: limites por rota
+	Tokens *tokens.Registry  // Opcional: planos por token
+	Access *access.List      // Opcional: listas allow/deny do arquivo
+	JWT    *jwtauth.Verifier // Opcional: identifica clientes por bearer JWT
+}
+
+// limitSet agrupa os limites que valem ao mesmo tempo, já convertidos
+type limitSet struct {
+	Limits
+
+	ipQuotas    []limiter.Quota
+	tokenQuotas []limiter.Quota
+	clientIP    *ClientIPResolver
+	ipPrefixes  IPPrefixes
+	composite   []CompositeLimit
+	onError     limiter.FailureMode
+}
+
+// Option configura recursos opcionais do middleware
+type Option func(*RateLimiterMiddleware)
+
+// WithConcurrencyLimiter ativa o limite de requisições simultâneas
+// (RATE_LIMIT_IP_CONCURRENCY / RATE_LIMIT_TOKEN_CONCURRENCY)
+func WithConcurrencyLimiter(cl *limiter.ConcurrencyLimiter) Option {
+	return func(rlm *RateLimiterMiddleware) {
+		rlm.concurrency = cl
+	}
+}
+
+// WithCostFunc define o custo de cada requisição (padrão: 1 para todas)
+func WithCostFunc(fn CostFunc) Option {
+	return func(rlm *RateLimiterMiddleware) {
+		rlm.cost = fn
+	}
+}
+
+// WithRules ativa limites por rota; rotas sem regra usam os limites padrão
+func WithRules(engine *rules.Engine) Option {
+	return func(rlm *RateLimiterMiddleware) {
+		// Chamado só no construtor, antes de o middleware ser usado
+		rlm.limits.Load().Rules = engine
+	}
+}
+
+// WithTokenRegistry ativa planos por token; tokens sem plano usam o limite padrão
+func WithTokenRegistry(registry *tokens.Registry) Option {
+	return func(rlm *RateLimiterMiddleware) {
+		// Chamado só no construtor, antes de o middleware ser usado
+		rlm.limits.Load().Tokens = registry
+	}
+}
+
+// WithCircuitBreaker faz o cadastro de tokens e os planos no Redis passarem
+// pelo circuit breaker do RateLimiter (RATE_LIMIT_BREAKER_*): com o Redis fora
+// do ar, falham na hora e seguem o modo de falha da rota
+func WithCircuitBreaker(breaker *limiter.CircuitBreaker) Option {
+	return func(rlm *RateLimiterMiddleware) {
+		rlm.breaker = breaker
+	}
+}
+
+// WithTokenStore confere cada API_KEY no cadastro de tokens; tokens desconhecidos,
+// revogados ou expirados seguem RATE_LIMIT_INVALID_TOKEN_POLICY
+func WithTokenStore(store *tokens.Store) Option {
+	return func(rlm *RateLimiterMiddleware) {
+		rlm.tokenStore = store
+	}
+}
+
+// WithTokenHasher usa o HMAC dos tokens nas chaves do Redis, em vez do token
+// (RATE_LIMIT_TOKEN_HASH_SECRET)
+func WithTokenHasher(hasher *tokens.Hasher) Option {
+	return func(rlm *RateLimiterMiddleware) {
+		rlm.hasher = hasher
+	}
+}
+
+// WithKeyExtractor troca a chave padrão pela do extrator (ex: tenant + rota)
+// Requisições sem a identidade usam a chave padrão; o limite aplicado continua
+// o de token (API_KEY ou JWT identificados) ou o de IP
+func WithKeyExtractor(extractor KeyExtractor) Option {
+	return func(rlm *RateLimiterMiddleware) {
+		rlm.extractor = extractor
+	}
+}
+
+// WithAccessList ativa as listas allow/deny do arquivo (RATE_LIMIT_ACCESS_FILE)
+func WithAccessList(list *access.List) Option {
+	return func(rlm *RateLimiterMiddleware) {
+		// Chamado só no construtor, antes de o middleware ser usado
+		rlm.limits.Load().Access = list
+	}
+}
+
+// WithJWTVerifier identifica requisições sem API_KEY pelo bearer JWT
+// (RATE_LIMIT_JWT_KEY_CLAIM / RATE_LIMIT_JWT_PLAN_CLAIM)
+func WithJWTVerifier(verifier *jwtauth.Verifier) Option {
+	return func(rlm *RateLimiterMiddleware) {
+		// Chamado só no construtor, antes de o middleware ser usado
+		rlm.limits.Load().JWT = verifier
+	}
+}
+
+// WithLimits aplica os limites carregados por LoadLimits (regras, planos,
+// listas e JWT), como o servidor faz na inicialização
+func WithLimits(limits Limits) Option {
+	return func(rlm *RateLimiterMiddleware) {
+		// Chamado só no construtor, antes de o middleware ser usado
+		current := rlm.limits.Load()
+		current.Rules = limits.Rules
+		current.Tokens = limits.Tokens
+		current.Access = limits.Access
+		current.JWT = limits.JWT
+	}
+}
+
+// WithAccessStore consulta também as listas allow/deny alteradas pela API
+func WithAccessStore(store *access.Store) Option {
+	return func(rlm *RateLimiterMiddleware) {
+		rlm.accessStore = store
+	}
 }
 
-func NewRateLimiterMiddleware(rateLimiter *limiter.RateLimiter, cfg *config.Config) *RateLimiterMiddleware {
-	return &RateLimiterMiddleware{
-		limiter: rateLimiter,
-		config:  cfg,
+func NewRateLimiterMiddleware(rateLimiter *limiter.RateLimiter, cfg *config.Config, opts ...Option) *RateLimiterMiddleware {
+	rlm := &RateLimiterMiddleware{
+		limiter:        rateLimiter,
+		trustedProxies: cfg.RateLimitTrustedProxies,
+	}
+
+	// Os quotas são validados na inicialização (main); aqui só são convertidos
+	limits := &limitSet{Limits: Limits{Config: cfg}}
+	var err error
+	if limits.ipQuotas, err = limiter.ParseQuotas(cfg.RateLimitIPQuotas); err != nil {
+		log.Printf("Erro nos quotas de IP, ignorando: %v", err)
+	}
+	if limits.tokenQuotas, err = limiter.ParseQuotas(cfg.RateLimitTokenQuotas); err != nil {
+		log.Printf("Erro nos quotas de token, ignorando: %v", err)
+	}
+	if limits.clientIP, err = NewClientIPResolver(cfg.RateLimitTrustedProxies, cfg.RateLimitClientIPHeader); err != nil {
+		log.Printf("Erro nos proxies confiáveis, usando só o IP da conexão: %v", err)
+	}
+	if limits.ipPrefixes, err = NewIPPrefixes(cfg.RateLimitIPv4Prefix, cfg.RateLimitIPv6Prefix); err != nil {
+		log.Printf("Erro nos prefixos de IP, usando endereços completos: %v", err)
+	}
+	if limits.composite, err = ParseCompositeLimits(cfg); err != nil {
+		log.Printf("Erro nos limites compostos, ignorando: %v", err)
+	}
+	if limits.onError, err = limiter.ParseFailureMode(cfg.RateLimitOnError); err != nil {
+		log.Printf("Erro no RATE_LIMIT_ON_ERROR, usando open: %v", err)
+		limits.onError = limiter.FailOpen
+	}
+	rlm.limits.Store(limits)
+
+	for _, opt := range opts {
+		opt(rlm)
+	}
+
+	return rlm
+}
+
+// Reload troca a configuração, as regras e os planos em uso sem reiniciar o servidor
+// Uma configuração inválida é rejeitada e os limites atuais continuam valendo
+// Retorna a descrição do que mudou
+func (rlm *RateLimiterMiddleware) Reload(updated Limits) ([]string, error) {
+	cfg := updated.Config
+	algorithm, err := limiter.ParseAlgorithm(cfg.RateLimitAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+	normalized := *cfg
+	normalized.RateLimitAlgorithm = string(algorithm)
+	updated.Config = &normalized
+
+	if _, err := ParseInvalidTokenPolicy(cfg.RateLimitInvalidTokenPolicy); err != nil {
+		return nil, err
+	}
+
+	limits := &limitSet{Limits: updated}
+	if limits.ipQuotas, err = limiter.ParseQuotas(cfg.RateLimitIPQuotas); err != nil {
+		return nil, fmt.Errorf("RATE_LIMIT_IP_QUOTAS: %w", err)
+	}
+	if limits.tokenQuotas, err = limiter.ParseQuotas(cfg.RateLimitTokenQuotas); err != nil {
+		return nil, fmt.Errorf("RATE_LIMIT_TOKEN_QUOTAS: %w", err)
 	}
+	if limits.clientIP, err = NewClientIPResolver(rlm.trustedProxies, cfg.RateLimitClientIPHeader); err != nil {
+		return nil, err
+	}
+	if limits.ipPrefixes, err = NewIPPrefixes(cfg.RateLimitIPv4Prefix, cfg.RateLimitIPv6Prefix); err != nil {
+		return nil, err
+	}
+	if limits.composite, err = ParseCompositeLimits(cfg); err != nil {
+		return nil, err
+	}
+	if limits.onError, err = limiter.ParseFailureMode(cfg.RateLimitOnError); err != nil {
+		return nil, fmt.Errorf("RATE_LIMIT_ON_ERROR: %w", err)
+	}
+	if err := ValidateRates(cfg); err != nil {
+		return nil, err
+	}
+
+	old := rlm.limits.Swap(limits)
+	changes := config.Diff(old.Config, limits.Config)
+	changes = append(changes, rules.Diff(old.Rules, limits.Rules)...)
+	changes = append(changes, tokens.Diff(old.Tokens, limits.Tokens)...)
+	changes = append(changes, access.Diff(old.Access, limits.Access)...)
+	return append(changes, jwtauth.Diff(old.JWT, limits.JWT)...), nil
+}
+
+// Limits retorna os limites em uso
+func (rlm *RateLimiterMiddleware) Limits() Limits {
+	return rlm.limits.Load().Limits
 }
 
 // Middleware retorna a função middleware do Gin
@@ -29,56 +259,213 @@ func (rlm *RateLimiterMiddleware) Middleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx := c.Request.Context()
 
-		// 1. Extrair IP do cliente
-		clientIP := getClientIP(c)
+		// Uma requisição usa o mesmo conjunto de limites do início ao fim
+		limits := rlm.limits.Load()
+		cfg := limits.Config
+
+		// 1. Extrair IP do cliente (headers só de proxies confiáveis)
+		clientIP := limits.clientIP.ClientIP(c.Request)
+		c.Set(ClientIPKey, clientIP)
+
+		// Endereços do mesmo prefixo (ex: uma /64 IPv6) dividem o limite
+		ipKey := limits.ipPrefixes.Key(clientIP)
+		c.Set(ipKeyKey, ipKey)
 
 		// 2. Verificar se existe token de API
 		apiToken := c.GetHeader("API_KEY")
 
+		// 2.0 Listas de acesso, antes de qualquer contador
+		switch rlm.accessAction(limits, clientIP, apiToken) {
+		case access.Deny:
+			c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+			c.Abort()
+			return
+		case access.Allow:
+			c.Next() // Liberado: não consome nem consulta os limites
+			return
+		}
+
+		// A regra da rota só depende do método e do caminho; o modo de falha
+		// dela já vale para o cadastro de tokens
+		rule, hasRule := limits.Rules.Match(c.Request.Method, c.Request.URL.Path)
+
+		// 2.1 Com o cadastro de tokens, só tokens válidos ganham o limite de token
+		var record *tokens.Record
+		if apiToken != "" && rlm.tokenStore != nil {
+			var ok bool
+			if record, apiToken, ok = rlm.authenticate(c, limits, rule, apiToken); !ok {
+				return
+			}
+		}
+
+		// 2.2 Sem API_KEY, um bearer JWT válido identifica o cliente pelos claims
+		var jwtKey, jwtPlan string
+		if apiToken == "" && limits.JWT != nil {
+			jwtKey, jwtPlan = jwtIdentity(c, limits)
+		}
+
 		var key string
 		var limitConfig limiter.LimitConfig
+		var identity string // Chave do token ou do JWT, para os limites compostos
 
-		// 3. Determinar qual limite usar (Token sobrepõe IP)
+		// 3. Determinar qual limite usar (Token e JWT sobrepõem IP)
 		if apiToken != "" {
 			// Usa configuração do token (mais permissiva)
-			key = fmt.Sprintf("token:%s", apiToken)
-			limitConfig = limiter.LimitConfig{
-				RPS:       rlm.config.RateLimitTokenRPS,
-				BlockTime: rlm.config.RateLimitTokenBlockTime,
+			key = fmt.Sprintf("token:%s", rlm.hasher.Hash(apiToken))
+			identity = key
+			limitConfig = tokenLimit(limits)
+
+			// 3.0 O plano do token, se houver, substitui o limite padrão de token
+			// O plano do cadastro tem precedência: o arquivo/hash de planos só é
+			// consultado para tokens sem plano no cadastro
+			var plan *tokens.Plan
+			if record != nil && record.Plan != "" {
+				var found bool
+				if plan, found = limits.Tokens.Plan(record.Plan); !found {
+					log.Printf("Plano %q do token %s desconhecido, usando o limite de token", record.Plan, record.ID)
+				}
+			} else {
+				var ok bool
+				if plan, ok = rlm.lookupPlan(c, limits, rule, apiToken); !ok {
+					return
+				}
+			}
+			if plan != nil {
+				limitConfig = plan.Limit
+				c.Header("X-RateLimit-Plan", plan.Name)
+			}
+		} else if jwtKey != "" {
+			// JWT usa o limite de token, ou o plano indicado no claim
+			key = jwtKey
+			identity = key
+			limitConfig = tokenLimit(limits)
+			if jwtPlan != "" {
+				if plan, ok := limits.Tokens.Plan(jwtPlan); ok {
+					limitConfig = plan.Limit
+					c.Header("X-RateLimit-Plan", plan.Name)
+				} else {
+					log.Printf("Plano %q do JWT desconhecido, usando o limite de token", jwtPlan)
+				}
 			}
 		} else {
 			// Usa configuração do IP
-			key = fmt.Sprintf("ip:%s", clientIP)
-			limitConfig = limiter.LimitConfig{
-				RPS:       rlm.config.RateLimitIPRPS,
-				BlockTime: rlm.config.RateLimitIPBlockTime,
+			key = fmt.Sprintf("ip:%s", ipKey)
+			limitConfig = ipLimit(limits)
+		}
+
+		// 3.1 Extrator configurado: mesmo limite, chave própria (namespace key:)
+		// O valor pode ser um segredo (ex: header de sessão): vai como HMAC
+		if rlm.extractor != nil {
+			if value, ok := rlm.extractor.ExtractKey(c); ok {
+				key = fmt.Sprintf("key:%s", rlm.hasher.Hash(value))
+			}
+		}
+
+		cost := 1
+		if rlm.cost != nil {
+			cost = max(rlm.cost(c), 1)
+		}
+
+		// 3.2 Regra da rota sobrepõe o limite padrão, com contadores próprios
+		// Sem limits, ou sem nenhuma identidade da regra na requisição (ex: o
+		// header foi omitido), vale o limite padrão com o contador da regra:
+		// omitir a identidade não livra o cliente do limite
+		if hasRule {
+			if limit, value, found := ruleLimit(c, rule); found {
+				if limit.Identity.Secret {
+					value = rlm.hasher.Hash(value)
+				}
+				key = fmt.Sprintf("%s:%s:%s", rule.Name, limit.Identity.Name, value)
+				limitConfig = limit.Config
+			} else {
+				key = fmt.Sprintf("%s:%s", rule.Name, key)
+			}
+			if rule.Cost > 0 {
+				cost = rule.Cost
+			}
+		}
+
+		// 3.3 Com fila, quem passa do limite aguarda a reposição em vez de
+		// receber 429; o excesso não aplica o bloqueio (ex: clientes em lote)
+		queued := cfg.RateLimitQueue || (hasRule && rule.Queue)
+		var maxWait time.Duration
+		if queued {
+			maxWait = limitConfig.MaxWait
+			if maxWait <= 0 {
+				maxWait = cfg.RateLimitMaxWait
+			}
+		}
+
+		// 3.4 Ocupa uma vaga de concorrência antes de consumir o limite: quem é
+		// recusado por concorrência não gasta RPS nem quotas. A vaga é liberada
+		// quando o handler terminar ou se os limites negarem a requisição
+		if rlm.concurrency != nil && limitConfig.MaxConcurrent > 0 {
+			lease, ok := rlm.acquireLease(c, key, limitConfig.MaxConcurrent, failureMode(limits, rule))
+			if !ok {
+				return
+			}
+			if lease != nil {
+				defer func() {
+					// A vaga é liberada mesmo se o cliente já desconectou
+					if err := lease.Release(context.WithoutCancel(ctx)); err != nil {
+						logStorageError("Erro no limitador de concorrência", err)
+					}
+				}()
+			}
+		}
+
+		// 4. Verificar rate limit, consumindo o custo da requisição
+		// Com limites compostos, quem tem token também passa pelos limites de
+		// RATE_LIMIT_COMPOSITE; os limites agregados (global) valem para todos.
+		// Qualquer um negando nega a requisição
+		checks := []limiter.LimitCheck{{Key: key, Config: limitConfig}}
+		if identity != "" {
+			checks = append(checks, compositeChecks(limits, identity, ipKey)...)
+		}
+		checks = append(checks, globalChecks(limits, rule)...)
+		if queued {
+			// Nenhum limite aplica o bloqueio na fila, inclusive os compostos e globais
+			for i := range checks {
+				checks[i].Config.BlockTime = 0
 			}
 		}
 
-		// 4. Verificar rate limit
-		result, err := rlm.limiter.Check(ctx, key, limitConfig)
+		result, ok, err := rlm.checkQueued(c, checks, cost, maxWait)
+		if !ok {
+			return
+		}
 		if err != nil {
-			// Em caso de erro no Redis/storage, logamos mas não bloqueamos
-			// Isso evita que problemas no Redis derrubem a aplicação
-			fmt.Printf("Erro no rate limiter: %v\n", err)
+			logStorageError("Erro no rate limiter", err)
+
+			// Fail-closed recusa a requisição; fail-open (padrão) evita que
+			// problemas no Redis derrubem a aplicação
+			if failureMode(limits, rule) == limiter.FailClosed {
+				rejectUnavailable(c)
+				return
+			}
 			c.Next() // Continua sem limitação
 			return
 		}
 
 		// 5. Adicionar headers informativos (mesmo quando permitido)
-		c.Header("X-RateLimit-Limit", fmt.Sprintf("%d", limitConfig.RPS))
+		// Com quotas, os headers refletem a janela mais restritiva
+		c.Header("X-RateLimit-Limit", fmt.Sprintf("%d", result.Limit))
 		c.Header("X-RateLimit-Remaining", fmt.Sprintf("%d", result.Remaining))
 		c.Header("X-RateLimit-Reset", fmt.Sprintf("%d", result.ResetTime.Unix()))
+		if cost > 1 {
+			c.Header("X-RateLimit-Cost", fmt.Sprintf("%d", cost))
+		}
 
 		// 6. Verificar se deve bloquear
 		if !result.Allowed {
 			// Headers adicionais para requisições bloqueadas
-			c.Header("Retry-After", fmt.Sprintf("%d", int(limitConfig.BlockTime.Seconds())))
+			retrySeconds := retryAfterSeconds(result.RetryAfter)
+			c.Header("Retry-After", fmt.Sprintf("%d", retrySeconds))
 
 			// Resposta HTTP 429 - Too Many Requests
 			c.JSON(http.StatusTooManyRequests, gin.H{
 				"error":               "you have reached the maximum number of requests or actions allowed within a certain time frame",
-				"retry_after_seconds": int(limitConfig.BlockTime.Seconds()),
+				"retry_after_seconds": retrySeconds,
 			})
 
 			// Aborta a execução - não chama os próximos handlers
@@ -86,42 +473,253 @@ func (rlm *RateLimiterMiddleware) Middleware() gin.HandlerFunc {
 			return
 		}
 
-		// 7. Se chegou aqui, está dentro do limite - continua
+		// 7. No modo leaky bucket, segura a requisição até sua vez na fila
+		if result.Delay > 0 && !waitForTurn(c, result.Delay) {
+			return
+		}
+
+		// 8. Se chegou aqui, está dentro do limite - continua
 		c.Next()
 	}
 }
 
-// getClientIP extrai o IP real do cliente considerando proxies/load balancers
-func getClientIP(c *gin.Context) string {
-	// 1. Verifica header X-Forwarded-For (comum em load balancers)
-	xForwardedFor := c.GetHeader("X-Forwarded-For")
-	if xForwardedFor != "" {
-		// Pode ter múltiplos IPs separados por vírgula
-		// O primeiro é geralmente o IP original do cliente
-		ips := strings.Split(xForwardedFor, ",")
-		if len(ips) > 0 {
-			ip := strings.TrimSpace(ips[0])
-			if net.ParseIP(ip) != nil {
-				return ip
-			}
+// checkQueued avalia os limites; com maxWait > 0, uma requisição negada pelo
+// algoritmo aguarda o Retry-After e tenta de novo, enquanto couber no maxWait
+// Bloqueios e quotas esgotados não entram na fila. Retorna false se o cliente
+// desistir durante a espera
+func (rlm *RateLimiterMiddleware) checkQueued(c *gin.Context, checks []limiter.LimitCheck, cost int, maxWait time.Duration) (*limiter.CheckResult, bool, error) {
+	deadline := time.Now().Add(maxWait)
+	for {
+		result, _, err := rlm.limiter.CheckAll(c.Request.Context(), checks, limiter.WithCost(cost))
+		if err != nil || result.Allowed || result.Blocked || result.QuotaExceeded {
+			return result, true, err
 		}
+
+		wait := max(result.RetryAfter, time.Millisecond)
+		if time.Until(deadline) < wait {
+			return result, true, nil
+		}
+		if !waitForTurn(c, wait) {
+			return nil, false, nil
+		}
+	}
+}
+
+// authenticate confere o token no cadastro e aplica a política para tokens inválidos
+// Retorna o registro do token (nil se não for válido), o token a usar no limite
+// ("" = anônimo, vale o limite por IP) e false se a requisição foi rejeitada
+// (resposta 401 ou 503 já enviada)
+func (rlm *RateLimiterMiddleware) authenticate(c *gin.Context, limits *limitSet, rule *rules.Rule, apiToken string) (*tokens.Record, string, bool) {
+	var record *tokens.Record
+	var invalid error // Token desconhecido, revogado ou expirado
+	err := rlm.breaker.Call(func() (err error) {
+		record, err = rlm.tokenStore.Authenticate(c.Request.Context(), apiToken)
+		if errors.Is(err, tokens.ErrTokenNotFound) || errors.Is(err, tokens.ErrTokenRevoked) || errors.Is(err, tokens.ErrTokenExpired) {
+			invalid = err
+			return nil
+		}
+		return err
+	})
+	switch {
+	case err != nil:
+		// Erro no Redis: mesmo tratamento do rate limiter. Fail-open não
+		// confia no token sem validá-lo e aplica o limite por IP
+		logStorageError("Erro no cadastro de tokens", err)
+		if failureMode(limits, rule) == limiter.FailClosed {
+			rejectUnavailable(c)
+			return nil, "", false
+		}
+		return nil, "", true
+	case invalid == nil:
+		return record, apiToken, true
 	}
 
-	// 2. Verifica header X-Real-IP (comum em nginx)
-	xRealIP := c.GetHeader("X-Real-IP")
-	if xRealIP != "" {
-		if net.ParseIP(xRealIP) != nil {
-			return xRealIP
+	// A política já foi validada na inicialização e no reload
+	policy, _ := ParseInvalidTokenPolicy(limits.Config.RateLimitInvalidTokenPolicy)
+	switch policy {
+	case InvalidTokenReject:
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid API token"})
+		c.Abort()
+		return nil, "", false
+	case InvalidTokenIP:
+		return nil, "", true
+	default:
+		return nil, apiToken, true
+	}
+}
+
+// lookupPlan busca o plano do token no arquivo e no hash do Redis
+// Com o Redis fora do ar, segue o modo de falha da rota: fail-open usa o
+// limite padrão de token. Retorna false se a requisição foi rejeitada
+// (resposta 503 já enviada)
+func (rlm *RateLimiterMiddleware) lookupPlan(c *gin.Context, limits *limitSet, rule *rules.Rule, apiToken string) (*tokens.Plan, bool) {
+	var plan *tokens.Plan
+	var unknown error // Associação a um plano inexistente: erro dos planos, não do Redis
+	err := rlm.breaker.Call(func() (err error) {
+		plan, _, err = limits.Tokens.Lookup(c.Request.Context(), apiToken)
+		if errors.Is(err, tokens.ErrUnknownPlan) {
+			unknown = err
+			return nil
 		}
+		return err
+	})
+	switch {
+	case err != nil:
+		logStorageError("Erro ao buscar plano do token", err)
+		if failureMode(limits, rule) == limiter.FailClosed {
+			rejectUnavailable(c)
+			return nil, false
+		}
+	case unknown != nil:
+		log.Printf("Erro ao buscar plano do token: %v", unknown)
+	}
+	return plan, true
+}
+
+// ipLimit é o limite padrão de IP (RATE_LIMIT_IP_*)
+func ipLimit(limits *limitSet) limiter.LimitConfig {
+	cfg := limits.Config
+	return limiter.LimitConfig{
+		RPS:       cfg.RateLimitIPRPS,
+		BlockTime: defaultBlockTime(cfg, cfg.RateLimitIPBlockTime),
+		Algorithm: limiter.Algorithm(cfg.RateLimitAlgorithm),
+		Burst:     cfg.RateLimitIPBurst,
+		MaxWait:   cfg.RateLimitMaxWait,
+
+		MaxConcurrent: cfg.RateLimitIPConcurrency,
+		Quotas:        limits.ipQuotas,
+	}
+}
+
+// tokenLimit é o limite padrão de token (RATE_LIMIT_TOKEN_*)
+func tokenLimit(limits *limitSet) limiter.LimitConfig {
+	cfg := limits.Config
+	return limiter.LimitConfig{
+		RPS:       cfg.RateLimitTokenRPS,
+		BlockTime: defaultBlockTime(cfg, cfg.RateLimitTokenBlockTime),
+		Algorithm: limiter.Algorithm(cfg.RateLimitAlgorithm),
+		Burst:     cfg.RateLimitTokenBurst,
+		MaxWait:   cfg.RateLimitMaxWait,
+
+		MaxConcurrent: cfg.RateLimitTokenConcurrency,
+		Quotas:        limits.tokenQuotas,
+	}
+}
+
+// ValidateRates confere os limites padrão do .env, como regras e planos já fazem
+// (GCRA e leaky bucket dividem o período por RPS)
+func ValidateRates(cfg *config.Config) error {
+	if cfg.RateLimitIPRPS <= 0 {
+		return fmt.Errorf("RATE_LIMIT_IP_RPS: rps deve ser maior que zero")
+	}
+	if cfg.RateLimitTokenRPS <= 0 {
+		return fmt.Errorf("RATE_LIMIT_TOKEN_RPS: rps deve ser maior que zero")
+	}
+	if cfg.RateLimitGlobalRPS < 0 {
+		return fmt.Errorf("RATE_LIMIT_GLOBAL_RPS: não pode ser negativo (0 desativa)")
+	}
+	return nil
+}
+
+// defaultBlockTime é o bloqueio de RATE_LIMIT_*_BLOCK_TIME nos limites padrão
+// O token bucket já absorve rajadas: quem passa da rajada só aguarda a
+// reposição (Retry-After), sem o bloqueio padrão. Um block_time explícito
+// nas regras e nos planos continua valendo
+func defaultBlockTime(cfg *config.Config, blockTime time.Duration) time.Duration {
+	if limiter.Algorithm(cfg.RateLimitAlgorithm) == limiter.TokenBucket {
+		return 0
+	}
+	return blockTime
+}
+
+// accessAction consulta as listas do arquivo e da API; deny em qualquer uma vence
+func (rlm *RateLimiterMiddleware) accessAction(limits *limitSet, clientIP, apiToken string) access.Action {
+	if limits.Access == nil && rlm.accessStore == nil {
+		return ""
+	}
+
+	var token string
+	if apiToken != "" {
+		token = rlm.hasher.Hash(apiToken)
 	}
+	return access.Combine(
+		limits.Access.Match(clientIP, token),
+		rlm.accessStore.List().Match(clientIP, token),
+	)
+}
 
-	// 3. Fallback para RemoteAddr (IP direto)
-	// Remove a porta se existir (formato IP:porta)
-	ip, _, err := net.SplitHostPort(c.Request.RemoteAddr)
+// acquireLease tenta ocupar uma vaga de concorrência para a identidade
+// Retorna false se a requisição foi rejeitada (resposta 429 já enviada)
+func (rlm *RateLimiterMiddleware) acquireLease(c *gin.Context, key string, maxConcurrent int, mode limiter.FailureMode) (*limiter.Lease, bool) {
+	result, err := rlm.concurrency.Acquire(c.Request.Context(), key, maxConcurrent)
 	if err != nil {
-		// Se não conseguir fazer split, provavelmente é só o IP
-		return c.Request.RemoteAddr
+		// Mesmo tratamento do rate limiter: só fail-closed recusa a requisição
+		logStorageError("Erro no limitador de concorrência", err)
+		if mode == limiter.FailClosed {
+			rejectUnavailable(c)
+			return nil, false
+		}
+		return nil, true
+	}
+
+	c.Header("X-Concurrency-Limit", fmt.Sprintf("%d", maxConcurrent))
+
+	if !result.Acquired {
+		// Não há como prever quando uma vaga abre; sugerimos o mínimo
+		c.Header("Retry-After", "1")
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error":               "you have reached the maximum number of concurrent requests",
+			"retry_after_seconds": 1,
+		})
+		c.Abort()
+		return nil, false
 	}
 
-	return ip
+	return result.Lease, true
+}
+
+// failureMode é o modo de falha da rota: o da regra ou RATE_LIMIT_ON_ERROR
+func failureMode(limits *limitSet, rule *rules.Rule) limiter.FailureMode {
+	if rule != nil && rule.OnError != "" {
+		return rule.OnError
+	}
+	return limits.onError
+}
+
+// logStorageError loga uma falha do storage; com o circuito aberto o erro já
+// foi logado quando ele abriu
+func logStorageError(message string, err error) {
+	if !errors.Is(err, limiter.ErrCircuitOpen) {
+		log.Printf("%s: %v", message, err)
+	}
+}
+
+// rejectUnavailable responde 503 quando o storage falha em modo fail-closed
+func rejectUnavailable(c *gin.Context) {
+	c.JSON(http.StatusServiceUnavailable, gin.H{"error": "rate limiter unavailable"})
+	c.Abort()
+}
+
+// waitForTurn aguarda o atraso calculado pelo leaky bucket
+// Retorna false se o cliente desistir (contexto cancelado) durante a espera
+func waitForTurn(c *gin.Context, delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-c.Request.Context().Done():
+		c.Abort()
+		return false
+	}
+}
+
+// retryAfterSeconds arredonda para cima, já que Retry-After só aceita segundos inteiros
+func retryAfterSeconds(d time.Duration) int {
+	seconds := int(math.Ceil(d.Seconds()))
+	if seconds < 1 {
+		return 1
+	}
+	return seconds
 }
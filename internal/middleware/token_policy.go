@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"fmt"
+	"strings"
+)
+
+// InvalidTokenPolicy define o que fazer com um API_KEY desconhecido, revogado ou
+// expirado quando o cadastro de tokens está ativo
+type InvalidTokenPolicy string
+
+const (
+	InvalidTokenAllow  InvalidTokenPolicy = "allow"  // Usa o limite de token, como sem cadastro
+	InvalidTokenIP     InvalidTokenPolicy = "ip"     // Ignora o token e usa o limite por IP
+	InvalidTokenReject InvalidTokenPolicy = "reject" // Responde 401
+)
+
+// ParseInvalidTokenPolicy valida RATE_LIMIT_INVALID_TOKEN_POLICY (vazio = allow)
+func ParseInvalidTokenPolicy(s string) (InvalidTokenPolicy, error) {
+	switch policy := InvalidTokenPolicy(strings.ToLower(strings.TrimSpace(s))); policy {
+	case "":
+		return InvalidTokenAllow, nil
+	case InvalidTokenAllow, InvalidTokenIP, InvalidTokenReject:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("RATE_LIMIT_INVALID_TOKEN_POLICY inválida: %q (use allow, ip ou reject)", s)
+	}
+}
@@ -0,0 +1,166 @@
+package reload
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+/*
+	Watcher dispara o reload da configuração quando:
+	  - algum dos arquivos observados (.env, arquivo de regras) muda
+	  - o processo recebe SIGHUP (ex: kill -HUP <pid>)
+
+	Os diretórios dos arquivos é que são observados: editores e o Kubernetes
+	(ConfigMap) substituem o arquivo em vez de reescrevê-lo, o que invalida um
+	watch feito no próprio arquivo.
+*/
+
+// DefaultDebounce agrupa as várias escritas de um mesmo salvamento
+const DefaultDebounce = 200 * time.Millisecond
+
+// Watcher observa arquivos e SIGHUP, chamando onChange em uma única goroutine
+type Watcher struct {
+	files    map[string]string // Caminho absoluto → destino do symlink na última verificação
+	watcher  *fsnotify.Watcher
+	signals  chan os.Signal
+	debounce time.Duration
+	onChange func(reason string)
+	done     chan struct{}
+}
+
+// Option configura o Watcher
+type Option func(*Watcher)
+
+// WithDebounce define quanto tempo esperar por novas escritas antes do reload
+func WithDebounce(d time.Duration) Option {
+	return func(w *Watcher) {
+		w.debounce = d
+	}
+}
+
+// NewWatcher começa a observar os arquivos; caminhos vazios são ignorados
+func NewWatcher(paths []string, onChange func(reason string), opts ...Option) (*Watcher, error) {
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("erro ao criar watcher: %w", err)
+	}
+
+	w := &Watcher{
+		files:    make(map[string]string),
+		watcher:  fsWatcher,
+		signals:  make(chan os.Signal, 1),
+		debounce: DefaultDebounce,
+		onChange: onChange,
+		done:     make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	dirs := make(map[string]bool)
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			fsWatcher.Close()
+			return nil, fmt.Errorf("erro ao observar %s: %w", path, err)
+		}
+		w.files[abs] = resolve(abs)
+
+		dir := filepath.Dir(abs)
+		if dirs[dir] {
+			continue
+		}
+		if err := fsWatcher.Add(dir); err != nil {
+			fsWatcher.Close()
+			return nil, fmt.Errorf("erro ao observar %s: %w", dir, err)
+		}
+		dirs[dir] = true
+	}
+
+	signal.Notify(w.signals, syscall.SIGHUP)
+
+	go w.run()
+	return w, nil
+}
+
+// Close para de observar arquivos e sinais
+func (w *Watcher) Close() error {
+	signal.Stop(w.signals)
+	err := w.watcher.Close()
+	<-w.done
+	return err
+}
+
+func (w *Watcher) run() {
+	defer close(w.done)
+
+	var pending string
+	var timer <-chan time.Time
+
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if path, changed := w.changed(event); changed {
+				// Reinicia a espera a cada escrita do mesmo salvamento
+				pending = fmt.Sprintf("%s alterado", filepath.Base(path))
+				timer = time.After(w.debounce)
+			}
+
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("Erro ao observar arquivos de configuração: %v", err)
+
+		case <-w.signals:
+			w.onChange("SIGHUP")
+
+		case <-timer:
+			timer = nil
+			w.onChange(pending)
+		}
+	}
+}
+
+// changed informa se o evento afeta algum dos arquivos observados
+func (w *Watcher) changed(event fsnotify.Event) (string, bool) {
+	if event.Op == fsnotify.Chmod {
+		return "", false
+	}
+
+	if _, ok := w.files[filepath.Clean(event.Name)]; ok {
+		return event.Name, true
+	}
+
+	// Troca de symlink (ConfigMap do Kubernetes) não gera evento no nome do arquivo
+	for path, target := range w.files {
+		if current := resolve(path); current != target {
+			w.files[path] = current
+			return path, true
+		}
+	}
+
+	return "", false
+}
+
+// resolve segue symlinks; arquivos inexistentes resolvem para vazio
+func resolve(path string) string {
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return ""
+	}
+	return target
+}
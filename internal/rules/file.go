@@ -0,0 +1,236 @@
+package rules
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/limiter"
+	"gopkg.in/yaml.v3"
+)
+
+/*
+	Arquivo declarativo de regras (YAML ou JSON, já que JSON é YAML válido):
+
+	  identities:
+	    tenant:  { type: header, header: X-Tenant-ID }
+	    user:    { type: claim, claim: sub }
+	    partner: { type: header, header: X-Partner-Key, secret: true }
+	    project: { type: param, param: id }        # também: query e cookie
+
+	  rules:
+	    - name: search
+	      methods: [GET]
+	      path: /search
+	      cost: 2
+	      on_error: closed             # Storage fora do ar: 503 (padrão: RATE_LIMIT_ON_ERROR)
+	      limits:                      # em ordem de precedência
+	        - identity: token          # "ip" e "token" são pré-definidas
+	          algorithm: token_bucket
+	          rps: 20
+	          burst: 40
+	          block_time: 30s
+	        - identity: ip
+	          rps: 2
+	          block_time: 5m
+	          quotas: 1000/day
+	      global:                      # Todos os clientes somados, em todas as instâncias
+	        rps: 5000
+	        algorithm: sliding_window_counter
+
+	O arquivo inteiro é validado na carga e todos os erros são reportados de
+	uma vez, com a regra e o campo de cada um.
+*/
+
+// File é o formato do arquivo de regras
+type File struct {
+	Identities map[string]IdentitySpec `yaml:"identities"`
+	Rules      []RuleSpec              `yaml:"rules"`
+}
+
+// IdentitySpec declara uma identidade nomeada
+type IdentitySpec struct {
+	Type   IdentityType `yaml:"type"`
+	Header string       `yaml:"header"`
+	Query  string       `yaml:"query"`
+	Cookie string       `yaml:"cookie"`
+	Param  string       `yaml:"param"`
+	Claim  string       `yaml:"claim"`
+	Secret bool         `yaml:"secret"` // Valor vai para o Redis como HMAC (RATE_LIMIT_TOKEN_HASH_SECRET)
+}
+
+// RuleSpec é uma regra como escrita no arquivo
+type RuleSpec struct {
+	Name    string      `yaml:"name"`
+	Methods []string    `yaml:"methods"`
+	Path    string      `yaml:"path"`
+	Cost    int         `yaml:"cost"`
+	Limits  []LimitSpec `yaml:"limits"`
+	Global  *GlobalSpec `yaml:"global"`
+	OnError string      `yaml:"on_error"`
+	Queue   bool        `yaml:"queue"`
+}
+
+// LimitSpec é o limite de uma identidade como escrito no arquivo
+type LimitSpec struct {
+	Identity      string   `yaml:"identity"`
+	Algorithm     string   `yaml:"algorithm"`
+	RPS           int      `yaml:"rps"`
+	Burst         int      `yaml:"burst"`
+	BlockTime     Duration `yaml:"block_time"`
+	MaxWait       Duration `yaml:"max_wait"`
+	MaxConcurrent int      `yaml:"max_concurrent"`
+	Quotas        string   `yaml:"quotas"` // Mesmo formato de RATE_LIMIT_IP_QUOTAS
+}
+
+// GlobalSpec é o limite agregado de uma regra como escrito no arquivo
+type GlobalSpec struct {
+	Algorithm string   `yaml:"algorithm"`
+	RPS       int      `yaml:"rps"`
+	Burst     int      `yaml:"burst"`
+	MaxWait   Duration `yaml:"max_wait"`
+	Quotas    string   `yaml:"quotas"`
+}
+
+// Duration aceita durações no formato do Go ("300ms", "5m", "1h30m")
+type Duration time.Duration
+
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	parsed, err := time.ParseDuration(node.Value)
+	if err != nil {
+		return fmt.Errorf("linha %d: duração inválida %q (use ex: 500ms, 30s, 5m)", node.Line, node.Value)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// LoadFile lê, valida e compila o arquivo de regras
+func LoadFile(path string) (*Engine, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler arquivo de regras: %w", err)
+	}
+
+	engine, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("erro no arquivo de regras %s:\n%w", path, err)
+	}
+	return engine, nil
+}
+
+// Parse valida e compila o conteúdo de um arquivo de regras
+func Parse(data []byte) (*Engine, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true) // Campo com erro de digitação não pode passar em silêncio
+
+	var file File
+	if err := decoder.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	rules, err := file.compile()
+	if err != nil {
+		return nil, err
+	}
+	return NewEngine(rules)
+}
+
+// compile converte o arquivo em regras, acumulando todos os erros de validação
+func (f File) compile() ([]Rule, error) {
+	var errs []error
+
+	identities := map[string]Identity{
+		IPIdentity.Name:    IPIdentity,
+		TokenIdentity.Name: TokenIdentity,
+	}
+	for name, spec := range f.Identities {
+		if _, reserved := identities[name]; reserved {
+			errs = append(errs, fmt.Errorf("identities.%s: nome reservado", name))
+			continue
+		}
+		identity := Identity{Name: name, Type: spec.Type, Header: spec.Header, Query: spec.Query, Cookie: spec.Cookie, Param: spec.Param, Claim: spec.Claim, Secret: spec.Secret}
+		if err := identity.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("identities.%s: %w", name, err))
+			continue
+		}
+		identities[name] = identity
+	}
+
+	var rules []Rule
+	names := make(map[string]bool)
+
+	for i, spec := range f.Rules {
+		rule, ruleErrs := spec.rule(identities)
+
+		if _, err := compile(rule); err != nil {
+			ruleErrs = append(ruleErrs, err)
+		}
+		if spec.Name != "" && names[spec.Name] {
+			ruleErrs = append(ruleErrs, fmt.Errorf("nome %q duplicado", spec.Name))
+		}
+		names[spec.Name] = true
+
+		for _, err := range ruleErrs {
+			errs = append(errs, fmt.Errorf("regra %d (%s): %w", i+1, spec.Name, err))
+		}
+		rules = append(rules, rule)
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return rules, nil
+}
+
+// rule resolve as identidades e quotas de uma regra do arquivo
+func (s RuleSpec) rule(identities map[string]Identity) (Rule, []error) {
+	var errs []error
+	rule := Rule{Name: s.Name, Methods: s.Methods, Path: s.Path, Cost: s.Cost, OnError: limiter.FailureMode(s.OnError), Queue: s.Queue}
+
+	for i, spec := range s.Limits {
+		identity, ok := identities[spec.Identity]
+		if !ok {
+			errs = append(errs, fmt.Errorf("limits[%d]: identidade %q não declarada", i, spec.Identity))
+			continue
+		}
+
+		quotas, err := limiter.ParseQuotas(spec.Quotas)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("limits[%d]: %w", i, err))
+			continue
+		}
+
+		rule.Limits = append(rule.Limits, Limit{
+			Identity: identity,
+			Config: limiter.LimitConfig{
+				RPS:           spec.RPS,
+				BlockTime:     time.Duration(spec.BlockTime),
+				Algorithm:     limiter.Algorithm(spec.Algorithm),
+				Burst:         spec.Burst,
+				MaxWait:       time.Duration(spec.MaxWait),
+				MaxConcurrent: spec.MaxConcurrent,
+				Quotas:        quotas,
+			},
+		})
+	}
+
+	if s.Global != nil {
+		quotas, err := limiter.ParseQuotas(s.Global.Quotas)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("global: %w", err))
+		} else {
+			rule.Global = &limiter.LimitConfig{
+				RPS:       s.Global.RPS,
+				Algorithm: limiter.Algorithm(s.Global.Algorithm),
+				Burst:     s.Global.Burst,
+				MaxWait:   time.Duration(s.Global.MaxWait),
+				Quotas:    quotas,
+			}
+		}
+	}
+
+	return rule, errs
+}
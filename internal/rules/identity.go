@@ -0,0 +1,73 @@
+package rules
+
+import (
+	"fmt"
+	"strings"
+)
+
+// IdentityType define de onde vem o valor que identifica quem está sendo limitado
+type IdentityType string
+
+const (
+	IdentityIP     IdentityType = "ip"     // IP do cliente
+	IdentityHeader IdentityType = "header" // Valor de um header HTTP
+	IdentityQuery  IdentityType = "query"  // Parâmetro da query string
+	IdentityCookie IdentityType = "cookie" // Valor de um cookie
+	IdentityParam  IdentityType = "param"  // Parâmetro da rota do Gin (ex: :id)
+	IdentityClaim  IdentityType = "claim"  // Claim do usuário autenticado (JWT)
+)
+
+// Identity descreve uma identidade; Name compõe a chave (ex: "tenant:acme")
+type Identity struct {
+	Name   string
+	Type   IdentityType
+	Header string // Obrigatório quando Type = header
+	Query  string // Obrigatório quando Type = query
+	Cookie string // Obrigatório quando Type = cookie
+	Param  string // Obrigatório quando Type = param
+	Claim  string // Obrigatório quando Type = claim
+	Secret bool   // O valor é um segredo: vai para o Redis como HMAC
+}
+
+// Identidades pré-definidas, equivalentes aos limites padrão de IP e token
+var (
+	IPIdentity    = Identity{Name: "ip", Type: IdentityIP}
+	TokenIdentity = Identity{Name: "token", Type: IdentityHeader, Header: "API_KEY", Secret: true}
+)
+
+func (i Identity) validate() error {
+	if i.Name == "" {
+		return fmt.Errorf("identidade sem nome")
+	}
+	if strings.ContainsAny(i.Name, "{}:") {
+		return fmt.Errorf("identidade %q: nome não pode conter {, } ou :", i.Name)
+	}
+
+	switch i.Type {
+	case IdentityIP:
+	case IdentityHeader:
+		if i.Header == "" {
+			return fmt.Errorf("identidade %q: header é obrigatório para o tipo header", i.Name)
+		}
+	case IdentityQuery:
+		if i.Query == "" {
+			return fmt.Errorf("identidade %q: query é obrigatório para o tipo query", i.Name)
+		}
+	case IdentityCookie:
+		if i.Cookie == "" {
+			return fmt.Errorf("identidade %q: cookie é obrigatório para o tipo cookie", i.Name)
+		}
+	case IdentityParam:
+		if i.Param == "" {
+			return fmt.Errorf("identidade %q: param é obrigatório para o tipo param", i.Name)
+		}
+	case IdentityClaim:
+		if i.Claim == "" {
+			return fmt.Errorf("identidade %q: claim é obrigatório para o tipo claim", i.Name)
+		}
+	default:
+		return fmt.Errorf("identidade %q: tipo %q inválido (use ip, header, query, cookie, param ou claim)", i.Name, i.Type)
+	}
+
+	return nil
+}
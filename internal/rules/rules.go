@@ -0,0 +1,302 @@
+package rules
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/limiter"
+)
+
+/*
+	Regras de rate limit por rota.
+
+	Cada regra associa método + padrão de caminho a limites próprios e a um
+	namespace de chaves próprio: o contador de "/search" não é o mesmo de
+	"/users/:id", então endpoints caros podem ter limites mais apertados.
+
+	Padrões seguem a sintaxe de rotas do Gin:
+	  /users/:id     → ":" casa exatamente um segmento
+	  /files/*path   → "*" casa o restante do caminho (só no último segmento)
+
+	As regras são avaliadas em ordem e a primeira que casar vence.
+*/
+
+// Rule descreve os limites de um conjunto de rotas
+type Rule struct {
+	Name    string   // Namespace das chaves (ex: "search" → "search:ip:1.2.3.4")
+	Methods []string // Métodos HTTP; vazio casa qualquer método
+	Path    string   // Padrão de caminho (template do Gin)
+
+	// Limites em ordem de precedência: vale o primeiro cuja identidade estiver
+	// presente na requisição. Vazio herda o limite padrão (token > IP), mas
+	// com contadores separados no namespace da regra
+	Limits []Limit
+
+	Cost int // Custo de cada requisição da rota (0 = custo padrão)
+
+	// Limite agregado da rota, somando todos os clientes e instâncias
+	// (chave "global:<Name>"); nil = sem limite agregado
+	Global *limiter.LimitConfig
+
+	// O que fazer se o storage falhar: open ou closed (vazio = RATE_LIMIT_ON_ERROR)
+	OnError limiter.FailureMode
+
+	// Acima do limite, as requisições da rota aguardam a reposição (até o
+	// max_wait do limite) em vez de receber 429, como com RATE_LIMIT_QUEUE
+	Queue bool
+}
+
+// Limit associa uma identidade ao limite aplicado a ela
+type Limit struct {
+	Identity Identity
+	Config   limiter.LimitConfig
+}
+
+// Engine encontra a regra aplicável a uma requisição
+type Engine struct {
+	rules []compiledRule
+}
+
+type compiledRule struct {
+	rule     Rule
+	methods  map[string]bool
+	segments []string
+}
+
+// NewEngine valida e compila as regras
+func NewEngine(rules []Rule) (*Engine, error) {
+	engine := &Engine{}
+	names := make(map[string]bool)
+
+	for i, rule := range rules {
+		compiled, err := compile(rule)
+		if err != nil {
+			return nil, fmt.Errorf("regra %d (%s): %w", i+1, rule.Name, err)
+		}
+
+		if names[rule.Name] {
+			return nil, fmt.Errorf("regra %d: nome %q duplicado", i+1, rule.Name)
+		}
+		names[rule.Name] = true
+
+		engine.rules = append(engine.rules, compiled)
+	}
+
+	return engine, nil
+}
+
+// Match retorna a primeira regra que casa com o método e o caminho
+func (e *Engine) Match(method, path string) (*Rule, bool) {
+	if e == nil {
+		return nil, false
+	}
+
+	segments := splitPath(path)
+	for i := range e.rules {
+		compiled := &e.rules[i]
+		if len(compiled.methods) > 0 && !compiled.methods[method] {
+			continue
+		}
+		if matchSegments(compiled.segments, segments) {
+			return &compiled.rule, true
+		}
+	}
+
+	return nil, false
+}
+
+// Diff descreve as regras adicionadas, removidas e alteradas entre dois engines
+// Engines nil equivalem a nenhuma regra
+func Diff(old, updated *Engine) []string {
+	var changes []string
+
+	before := old.byName()
+	after := updated.byName()
+
+	for _, compiled := range updated.list() {
+		name := compiled.rule.Name
+		previous, ok := before[name]
+		switch {
+		case !ok:
+			changes = append(changes, fmt.Sprintf("regra %s adicionada", name))
+		case !reflect.DeepEqual(previous.rule, compiled.rule):
+			changes = append(changes, fmt.Sprintf("regra %s alterada", name))
+		}
+	}
+	for _, compiled := range old.list() {
+		if _, ok := after[compiled.rule.Name]; !ok {
+			changes = append(changes, fmt.Sprintf("regra %s removida", compiled.rule.Name))
+		}
+	}
+
+	// A primeira regra que casar vence, então a ordem também é uma mudança
+	if len(changes) == 0 && !reflect.DeepEqual(old.names(), updated.names()) {
+		changes = append(changes, fmt.Sprintf("ordem das regras alterada: %s", strings.Join(updated.names(), ", ")))
+	}
+
+	return changes
+}
+
+func (e *Engine) list() []compiledRule {
+	if e == nil {
+		return nil
+	}
+	return e.rules
+}
+
+func (e *Engine) byName() map[string]compiledRule {
+	rules := make(map[string]compiledRule)
+	for _, compiled := range e.list() {
+		rules[compiled.rule.Name] = compiled
+	}
+	return rules
+}
+
+func (e *Engine) names() []string {
+	var names []string
+	for _, compiled := range e.list() {
+		names = append(names, compiled.rule.Name)
+	}
+	return names
+}
+
+func compile(rule Rule) (compiledRule, error) {
+	if rule.Name == "" {
+		return compiledRule{}, fmt.Errorf("name é obrigatório")
+	}
+	if strings.ContainsAny(rule.Name, "{}") {
+		// As chaves usam {identidade} como hash tag do Redis Cluster
+		return compiledRule{}, fmt.Errorf("name não pode conter { ou }")
+	}
+	if !strings.HasPrefix(rule.Path, "/") {
+		return compiledRule{}, fmt.Errorf("path deve começar com /: %q", rule.Path)
+	}
+
+	segments := splitPath(rule.Path)
+	for i, segment := range segments {
+		switch {
+		case segment == ":":
+			return compiledRule{}, fmt.Errorf("parâmetro sem nome em %q", rule.Path)
+		case strings.HasPrefix(segment, "*") && i != len(segments)-1:
+			return compiledRule{}, fmt.Errorf("curinga * só é permitido no último segmento: %q", rule.Path)
+		}
+	}
+
+	methods := make(map[string]bool)
+	for _, method := range rule.Methods {
+		method = strings.ToUpper(strings.TrimSpace(method))
+		if !validMethods[method] {
+			return compiledRule{}, fmt.Errorf("método HTTP inválido: %q", method)
+		}
+		methods[method] = true
+	}
+
+	// Copia os limites para que a regra não compartilhe estado com quem a criou
+	limits := make([]Limit, len(rule.Limits))
+	for i, limit := range rule.Limits {
+		compiled, err := compileLimit(limit)
+		if err != nil {
+			return compiledRule{}, fmt.Errorf("limits[%d]: %w", i, err)
+		}
+		limits[i] = compiled
+	}
+	rule.Limits = limits
+
+	if rule.Global != nil {
+		global, err := compileGlobal(*rule.Global)
+		if err != nil {
+			return compiledRule{}, fmt.Errorf("global: %w", err)
+		}
+		rule.Global = &global
+	}
+
+	if rule.Cost < 0 {
+		return compiledRule{}, fmt.Errorf("cost não pode ser negativo")
+	}
+
+	if rule.OnError != "" {
+		mode, err := limiter.ParseFailureMode(string(rule.OnError))
+		if err != nil {
+			return compiledRule{}, fmt.Errorf("on_error: %w", err)
+		}
+		rule.OnError = mode
+	}
+
+	return compiledRule{rule: rule, methods: methods, segments: segments}, nil
+}
+
+// compileLimit valida o limite de uma identidade e normaliza o algoritmo
+func compileLimit(limit Limit) (Limit, error) {
+	if err := limit.Identity.validate(); err != nil {
+		return Limit{}, err
+	}
+	if limit.Config.RPS <= 0 {
+		return Limit{}, fmt.Errorf("rps deve ser maior que zero")
+	}
+
+	algorithm, err := limiter.ParseAlgorithm(string(limit.Config.Algorithm))
+	if err != nil {
+		return Limit{}, err
+	}
+	limit.Config.Algorithm = algorithm
+
+	return limit, nil
+}
+
+// compileGlobal valida o limite agregado da rota e normaliza o algoritmo
+// Sem bloqueio: bloquear a chave global negaria a rota a todos os clientes
+func compileGlobal(config limiter.LimitConfig) (limiter.LimitConfig, error) {
+	if config.RPS <= 0 {
+		return limiter.LimitConfig{}, fmt.Errorf("rps deve ser maior que zero")
+	}
+	if config.BlockTime > 0 || config.MaxConcurrent > 0 {
+		return limiter.LimitConfig{}, fmt.Errorf("block_time e max_concurrent não se aplicam ao limite global")
+	}
+
+	algorithm, err := limiter.ParseAlgorithm(string(config.Algorithm))
+	if err != nil {
+		return limiter.LimitConfig{}, err
+	}
+	config.Algorithm = algorithm
+
+	return config, nil
+}
+
+var validMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodOptions: true,
+}
+
+// matchSegments compara o padrão compilado com os segmentos do caminho
+func matchSegments(pattern, path []string) bool {
+	for i, segment := range pattern {
+		if strings.HasPrefix(segment, "*") {
+			return true // Curinga casa o restante, inclusive vazio
+		}
+		if i >= len(path) {
+			return false
+		}
+		if !strings.HasPrefix(segment, ":") && segment != path[i] {
+			return false
+		}
+	}
+	return len(pattern) == len(path)
+}
+
+// splitPath divide o caminho em segmentos, ignorando barras extras
+func splitPath(path string) []string {
+	var segments []string
+	for _, segment := range strings.Split(path, "/") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	return segments
+}
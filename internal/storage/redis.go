@@ -3,28 +3,56 @@ package storage
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/config"
 	"github.com/redis/go-redis/v9"
 )
 
+// Modos de conexão suportados em REDIS_MODE
+const (
+	RedisModeSingle   = "single"
+	RedisModeSentinel = "sentinel"
+	RedisModeCluster  = "cluster"
+)
+
 // NewRedisClient cria uma nova conexão com Redis
-func NewRedisClient(cfg *config.Config) (*redis.Client, error) {
+// O tipo concreto depende de REDIS_MODE: *redis.Client (single),
+// *redis.Client com failover (sentinel) ou *redis.ClusterClient (cluster)
+func NewRedisClient(cfg *config.Config) (redis.UniversalClient, error) {
 	// Configuração do cliente Redis
-	rdb := redis.NewClient(&redis.Options{
-		Addr:     fmt.Sprintf("%s:%s", cfg.RedisHost, cfg.RedisPort), // localhost:6379
-		Password: cfg.RedisPassword,                                  // sem senha por padrão
-		DB:       cfg.RedisDB,                                        // database 0 por padrão
+	opts := &redis.UniversalOptions{
+		Addrs:      redisAddrs(cfg),     // localhost:6379 (ou lista de nós/sentinels)
+		MasterName: cfg.RedisMasterName, // nome do master monitorado pelos sentinels
+		Password:   cfg.RedisPassword,   // sem senha por padrão
+		DB:         cfg.RedisDB,         // database 0 por padrão (ignorado no cluster)
+
+		SentinelPassword: cfg.RedisSentinelPassword,
 
 		// Configurações de pool de conexões
-		PoolSize:     10,              // 10 conexões simultâneas máximo
+		PoolSize:     10,              // 10 conexões simultâneas máximo (por nó)
 		MinIdleConns: 2,               // mínimo 2 conexões idle
 		MaxRetries:   3,               // 3 tentativas em caso de erro
 		DialTimeout:  5 * time.Second, // timeout para conectar
 		ReadTimeout:  3 * time.Second, // timeout para ler
 		WriteTimeout: 3 * time.Second, // timeout para escrever
-	})
+	}
+
+	var rdb redis.UniversalClient
+	switch strings.ToLower(cfg.RedisMode) {
+	case RedisModeSingle, "":
+		rdb = redis.NewClient(opts.Simple())
+	case RedisModeSentinel:
+		if opts.MasterName == "" {
+			return nil, fmt.Errorf("REDIS_MASTER_NAME é obrigatório no modo sentinel")
+		}
+		rdb = redis.NewFailoverClient(opts.Failover())
+	case RedisModeCluster:
+		rdb = redis.NewClusterClient(opts.Cluster())
+	default:
+		return nil, fmt.Errorf("REDIS_MODE desconhecido: %q", cfg.RedisMode)
+	}
 
 	// Testa a conexão
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -33,9 +61,34 @@ func NewRedisClient(cfg *config.Config) (*redis.Client, error) {
 	// PING é o comando mais simples para testar conectividade
 	_, err := rdb.Ping(ctx).Result()
 	if err != nil {
+		rdb.Close()
 		return nil, fmt.Errorf("falha ao conectar com Redis: %w", err)
 	}
 
-	fmt.Println("✅ Conectado ao Redis com sucesso!")
+	fmt.Printf("✅ Conectado ao Redis com sucesso! (modo %s)\n", redisModeName(cfg.RedisMode))
 	return rdb, nil
 }
+
+// redisAddrs usa REDIS_ADDRS (lista separada por vírgula) quando informado,
+// senão REDIS_HOST:REDIS_PORT
+func redisAddrs(cfg *config.Config) []string {
+	var addrs []string
+	for _, addr := range strings.Split(cfg.RedisAddrs, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			addrs = append(addrs, addr)
+		}
+	}
+
+	if len(addrs) == 0 {
+		addrs = []string{fmt.Sprintf("%s:%s", cfg.RedisHost, cfg.RedisPort)}
+	}
+
+	return addrs
+}
+
+func redisModeName(mode string) string {
+	if mode == "" {
+		return RedisModeSingle
+	}
+	return strings.ToLower(mode)
+}
@@ -0,0 +1,44 @@
+package tokens
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Hasher troca tokens pelo seu HMAC-SHA256 antes de irem para o Redis, para
+// que os segredos não apareçam em nomes de chaves (KEYS, SCAN, MONITOR)
+type Hasher struct {
+	key []byte
+}
+
+// defaultKey é a chave do HMAC sem RATE_LIMIT_TOKEN_HASH_SECRET: os tokens
+// continuam fora dos nomes das chaves, mas a chave está no código e quem lê o
+// Redis pode testar tokens candidatos. Em produção, defina o segredo
+var defaultKey = sha256.Sum256([]byte("go-ratelimiter:token-hash"))
+
+// NewHasher cria o Hasher; segredo vazio usa a chave padrão, então tokens
+// nunca vão em texto puro para o Redis
+func NewHasher(secret string) *Hasher {
+	if secret == "" {
+		return &Hasher{key: defaultKey[:]}
+	}
+	return &Hasher{key: []byte(secret)}
+}
+
+// Hash retorna os primeiros 128 bits do HMAC do token, em hex
+// Com Hasher nil (uso como biblioteca, sem WithTokenHasher), o token é
+// retornado como está
+func (h *Hasher) Hash(token string) string {
+	if h == nil {
+		return token
+	}
+	mac := hmac.New(sha256.New, h.key)
+	mac.Write([]byte(token))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// Enabled informa se os tokens são protegidos por HMAC
+func (h *Hasher) Enabled() bool {
+	return h != nil
+}
@@ -0,0 +1,348 @@
+package tokens
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/limiter"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/rules"
+	"github.com/redis/go-redis/v9"
+	"gopkg.in/yaml.v3"
+)
+
+/*
+	Registro de planos por token.
+
+	Cada token (ou prefixo de token) aponta para um plano com limites próprios:
+
+	  plans:
+	    free: { rps: 10, block_time: 60s, quotas: 1000/day }
+	    pro:  { rps: 100, burst: 200, algorithm: token_bucket, quotas: 100000/day }
+	  tokens:
+	    abc123: pro
+	  prefixes:
+	    sk_test_: free
+
+	Token exato tem precedência sobre prefixo, e o prefixo mais longo vence.
+	Com WithRedisHash, as associações também são buscadas em um hash do Redis
+	(campo = token, ou prefixo terminado em "*"; valor = plano), que tem
+	precedência sobre o arquivo e pode ser alterado sem deploy:
+
+	  HSET ratelimit:tokens abc123 pro "sk_live_*" pro
+
+	Com WithHasher, os campos de token exato no hash são o HMAC do token
+	(Hasher.Hash) e os prefixos não são consultados no Redis: enviar os prefixos
+	do token no HMGET exporia o início do segredo a quem tiver MONITOR.
+*/
+
+// ErrUnknownPlan indica um token associado a um plano que não existe
+var ErrUnknownPlan = errors.New("plano desconhecido")
+
+// maxPrefixLen limita quantos prefixos de um token são consultados no Redis
+const maxPrefixLen = 32
+
+// Plan é o limite aplicado a um grupo de tokens
+type Plan struct {
+	Name  string
+	Limit limiter.LimitConfig
+}
+
+// Registry resolve o plano de cada token
+type Registry struct {
+	plans    map[string]Plan
+	tokens   map[string]string // Token exato → plano
+	prefixes []prefixPlan      // Do mais longo para o mais curto
+
+	redis  redis.UniversalClient
+	hash   string
+	hasher *Hasher
+}
+
+type prefixPlan struct {
+	prefix string
+	plan   string
+}
+
+// Option configura o Registry
+type Option func(*Registry)
+
+// WithRedisHash busca as associações token → plano também em um hash do Redis
+func WithRedisHash(client redis.UniversalClient, hash string) Option {
+	return func(r *Registry) {
+		r.redis = client
+		r.hash = hash
+	}
+}
+
+// WithHasher protege os tokens consultados no hash do Redis com HMAC
+func WithHasher(hasher *Hasher) Option {
+	return func(r *Registry) {
+		r.hasher = hasher
+	}
+}
+
+// File é o formato do arquivo de planos (YAML ou JSON)
+type File struct {
+	Plans    map[string]PlanSpec `yaml:"plans"`
+	Tokens   map[string]string   `yaml:"tokens"`
+	Prefixes map[string]string   `yaml:"prefixes"`
+}
+
+// PlanSpec é um plano como escrito no arquivo
+type PlanSpec struct {
+	Algorithm     string         `yaml:"algorithm"`
+	RPS           int            `yaml:"rps"`
+	Burst         int            `yaml:"burst"`
+	BlockTime     rules.Duration `yaml:"block_time"`
+	MaxWait       rules.Duration `yaml:"max_wait"`
+	MaxConcurrent int            `yaml:"max_concurrent"`
+	Quotas        string         `yaml:"quotas"`
+}
+
+// LoadFile lê e valida o arquivo de planos
+func LoadFile(path string, opts ...Option) (*Registry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler arquivo de tokens: %w", err)
+	}
+
+	registry, err := Parse(data, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("erro no arquivo de tokens %s:\n%w", path, err)
+	}
+	return registry, nil
+}
+
+// Parse valida o conteúdo de um arquivo de planos, acumulando todos os erros
+func Parse(data []byte, opts ...Option) (*Registry, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	var file File
+	if err := decoder.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	registry := &Registry{
+		plans:  make(map[string]Plan),
+		tokens: make(map[string]string),
+	}
+	for _, opt := range opts {
+		opt(registry)
+	}
+
+	var errs []error
+	for name, spec := range file.Plans {
+		limit, err := spec.limit()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("plans.%s: %w", name, err))
+			continue
+		}
+		registry.plans[name] = Plan{Name: name, Limit: limit}
+	}
+
+	for token, plan := range file.Tokens {
+		if _, ok := file.Plans[plan]; !ok {
+			errs = append(errs, fmt.Errorf("tokens.%s: plano %q não declarado", mask(token), plan))
+			continue
+		}
+		registry.tokens[token] = plan
+	}
+
+	for prefix, plan := range file.Prefixes {
+		if _, ok := file.Plans[plan]; !ok {
+			errs = append(errs, fmt.Errorf("prefixes.%s: plano %q não declarado", prefix, plan))
+			continue
+		}
+		registry.prefixes = append(registry.prefixes, prefixPlan{prefix: prefix, plan: plan})
+	}
+	sort.Slice(registry.prefixes, func(i, j int) bool {
+		return len(registry.prefixes[i].prefix) > len(registry.prefixes[j].prefix)
+	})
+
+	if len(errs) > 0 {
+		// Mapas não têm ordem; ordena para a mensagem ser estável
+		sort.Slice(errs, func(i, j int) bool { return errs[i].Error() < errs[j].Error() })
+		return nil, errors.Join(errs...)
+	}
+	return registry, nil
+}
+
+func (s PlanSpec) limit() (limiter.LimitConfig, error) {
+	if s.RPS <= 0 {
+		return limiter.LimitConfig{}, fmt.Errorf("rps deve ser maior que zero")
+	}
+
+	algorithm, err := limiter.ParseAlgorithm(s.Algorithm)
+	if err != nil {
+		return limiter.LimitConfig{}, err
+	}
+
+	quotas, err := limiter.ParseQuotas(s.Quotas)
+	if err != nil {
+		return limiter.LimitConfig{}, err
+	}
+
+	return limiter.LimitConfig{
+		RPS:           s.RPS,
+		BlockTime:     time.Duration(s.BlockTime),
+		Algorithm:     algorithm,
+		Burst:         s.Burst,
+		MaxWait:       time.Duration(s.MaxWait),
+		MaxConcurrent: s.MaxConcurrent,
+		Quotas:        quotas,
+	}, nil
+}
+
+// Lookup retorna o plano do token; tokens sem plano usam o limite padrão de token
+// Erros que não são ErrUnknownPlan vêm do Redis
+func (r *Registry) Lookup(ctx context.Context, token string) (*Plan, bool, error) {
+	if r == nil || token == "" {
+		return nil, false, nil
+	}
+
+	if r.redis != nil {
+		name, ok, err := r.lookupRedis(ctx, token)
+		if err != nil {
+			return nil, false, err
+		}
+		if ok {
+			return r.plan(name)
+		}
+	}
+
+	if name, ok := r.tokens[token]; ok {
+		return r.plan(name)
+	}
+	for _, entry := range r.prefixes {
+		if strings.HasPrefix(token, entry.prefix) {
+			return r.plan(entry.plan)
+		}
+	}
+
+	return nil, false, nil
+}
+
+// lookupRedis busca o token e seus prefixos em um único HMGET
+func (r *Registry) lookupRedis(ctx context.Context, token string) (string, bool, error) {
+	fields := []string{r.hasher.Hash(token)}
+	for n := min(len(token)-1, maxPrefixLen); n > 0 && !r.hasher.Enabled(); n-- {
+		fields = append(fields, token[:n]+"*")
+	}
+
+	values, err := r.redis.HMGet(ctx, r.hash, fields...).Result()
+	if err != nil {
+		return "", false, fmt.Errorf("erro ao consultar planos no Redis: %w", err)
+	}
+
+	// Os campos estão do mais específico para o menos específico
+	for _, value := range values {
+		if name, ok := value.(string); ok && name != "" {
+			return name, true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// Plan busca um plano pelo nome, ex: o plano de um token cadastrado no Store
+func (r *Registry) Plan(name string) (*Plan, bool) {
+	if r == nil {
+		return nil, false
+	}
+	plan, ok := r.plans[name]
+	if !ok {
+		return nil, false
+	}
+	return &plan, true
+}
+
+func (r *Registry) plan(name string) (*Plan, bool, error) {
+	plan, ok := r.plans[name]
+	if !ok {
+		return nil, false, fmt.Errorf("%w: %q", ErrUnknownPlan, name)
+	}
+	return &plan, true, nil
+}
+
+// Diff descreve os planos e associações que mudaram entre dois registros
+// Os tokens aparecem mascarados
+func Diff(old, updated *Registry) []string {
+	var changes []string
+
+	oldPlans, newPlans := old.planMap(), updated.planMap()
+	for _, name := range sortedKeys(newPlans) {
+		previous, ok := oldPlans[name]
+		switch {
+		case !ok:
+			changes = append(changes, fmt.Sprintf("plano %s adicionado", name))
+		case !reflect.DeepEqual(previous, newPlans[name]):
+			changes = append(changes, fmt.Sprintf("plano %s alterado", name))
+		}
+	}
+	for _, name := range sortedKeys(oldPlans) {
+		if _, ok := newPlans[name]; !ok {
+			changes = append(changes, fmt.Sprintf("plano %s removido", name))
+		}
+	}
+
+	oldTokens, newTokens := old.assignments(), updated.assignments()
+	for _, key := range sortedKeys(newTokens) {
+		if previous, ok := oldTokens[key]; !ok || previous != newTokens[key] {
+			changes = append(changes, fmt.Sprintf("%s → plano %s", key, newTokens[key]))
+		}
+	}
+	for _, key := range sortedKeys(oldTokens) {
+		if _, ok := newTokens[key]; !ok {
+			changes = append(changes, fmt.Sprintf("%s removido", key))
+		}
+	}
+
+	return changes
+}
+
+func (r *Registry) planMap() map[string]Plan {
+	if r == nil {
+		return nil
+	}
+	return r.plans
+}
+
+// assignments lista as associações do arquivo, com os tokens mascarados
+func (r *Registry) assignments() map[string]string {
+	assignments := make(map[string]string)
+	if r == nil {
+		return assignments
+	}
+	for token, plan := range r.tokens {
+		assignments["token "+mask(token)] = plan
+	}
+	for _, entry := range r.prefixes {
+		assignments["prefixo "+entry.prefix] = entry.plan
+	}
+	return assignments
+}
+
+// mask mostra só o início do token em logs e mensagens de erro
+func mask(token string) string {
+	if len(token) <= 4 {
+		return "****"
+	}
+	return token[:4] + "****"
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,355 @@
+package tokens
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+/*
+	Cadastro de tokens de API no Redis.
+
+	Cada token tem um ID público (usado pela API de administração) e um segredo,
+	o valor enviado no header API_KEY, que só é exibido na criação:
+
+	  apitokens:{registry}:<id>          → hash com plano, dono, criação, expiração e revogação
+	  apitokens:{registry}:secret:<token> → ID do token (ou HMAC do token)
+	  apitokens:{registry}:index          → set com todos os IDs
+
+	Todas as chaves compartilham a hash tag {registry}, então ficam no mesmo
+	slot do Redis Cluster e podem ser alteradas em uma única transação.
+	Tokens revogados continuam cadastrados, para auditoria.
+
+	Com WithStoreHasher, a chave do segredo usa o HMAC do token, e o registro
+	guarda esse HMAC (key_hash) para localizar o estado do token no limiter.
+	Tokens criados antes de ativar o HMAC precisam ser recriados.
+*/
+
+const storePrefix = "apitokens:{registry}:"
+
+var (
+	ErrTokenNotFound = errors.New("token não encontrado")
+	ErrTokenRevoked  = errors.New("token revogado")
+	ErrTokenExpired  = errors.New("token expirado")
+)
+
+// Record são os dados de um token cadastrado (sem o segredo)
+type Record struct {
+	ID        string     `json:"id"`
+	KeyHash   string     `json:"key_hash,omitempty"` // HMAC do segredo, usado nas chaves do limiter
+	Plan      string     `json:"plan,omitempty"`
+	Owner     string     `json:"owner"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// validate informa por que o token não pode ser usado, se for o caso
+func (r *Record) validate(now time.Time) error {
+	if r.RevokedAt != nil {
+		return ErrTokenRevoked
+	}
+	if r.ExpiresAt != nil && !now.Before(*r.ExpiresAt) {
+		return ErrTokenExpired
+	}
+	return nil
+}
+
+// NewToken são os dados para criar um token
+type NewToken struct {
+	Plan      string
+	Owner     string
+	ExpiresAt *time.Time // nil = não expira
+}
+
+// Update altera campos de um token; campos nil não mudam
+type Update struct {
+	Plan      *string
+	Owner     *string
+	ExpiresAt *time.Time
+}
+
+// Store guarda os tokens de API no Redis
+type Store struct {
+	client redis.UniversalClient
+	hasher *Hasher
+	now    func() time.Time
+}
+
+// StoreOption configura o Store
+type StoreOption func(*Store)
+
+// WithStoreClock substitui o relógio (usado nos testes)
+func WithStoreClock(now func() time.Time) StoreOption {
+	return func(s *Store) {
+		s.now = now
+	}
+}
+
+// WithStoreHasher guarda o HMAC dos segredos em vez dos segredos
+func WithStoreHasher(hasher *Hasher) StoreOption {
+	return func(s *Store) {
+		s.hasher = hasher
+	}
+}
+
+// NewStore cria o cadastro de tokens
+func NewStore(client redis.UniversalClient, opts ...StoreOption) *Store {
+	s := &Store{client: client, now: time.Now}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// lookupScript resolve segredo → ID → registro em uma única ida ao Redis
+// O registro não está em KEYS (o ID só é conhecido aqui), mas compartilha a
+// hash tag do segredo e por isso está no mesmo slot do Redis Cluster
+var lookupScript = redis.NewScript(`
+local id = redis.call('GET', KEYS[1])
+if not id then
+	return false
+end
+return redis.call('HGETALL', ARGV[1] .. id)
+`)
+
+// Create cadastra um token e retorna o segredo, que não é guardado em outro lugar
+func (s *Store) Create(ctx context.Context, token NewToken) (string, *Record, error) {
+	id, err := randomHex(8)
+	if err != nil {
+		return "", nil, err
+	}
+	secretPart, err := randomHex(24)
+	if err != nil {
+		return "", nil, err
+	}
+	secret := "rl_" + secretPart
+
+	record := &Record{
+		ID:        id,
+		Plan:      token.Plan,
+		Owner:     token.Owner,
+		CreatedAt: s.now().UTC().Truncate(time.Millisecond),
+		ExpiresAt: truncate(token.ExpiresAt),
+	}
+	if s.hasher.Enabled() {
+		record.KeyHash = s.hasher.Hash(secret)
+	}
+
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, recordKey(id), recordFields(record))
+		pipe.Set(ctx, secretKey(s.hasher.Hash(secret)), id, 0)
+		pipe.SAdd(ctx, indexKey(), id)
+		return nil
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("erro ao criar token: %w", err)
+	}
+
+	return secret, record, nil
+}
+
+// Get busca um token pelo ID
+func (s *Store) Get(ctx context.Context, id string) (*Record, error) {
+	values, err := s.client.HGetAll(ctx, recordKey(id)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar token: %w", err)
+	}
+	if len(values) == 0 {
+		return nil, ErrTokenNotFound
+	}
+	return parseRecord(id, values)
+}
+
+// List retorna todos os tokens cadastrados, inclusive revogados e expirados
+func (s *Store) List(ctx context.Context) ([]*Record, error) {
+	ids, err := s.client.SMembers(ctx, indexKey()).Result()
+	if err != nil {
+		return nil, fmt.Errorf("erro ao listar tokens: %w", err)
+	}
+
+	records := make([]*Record, 0, len(ids))
+	for _, id := range ids {
+		record, err := s.Get(ctx, id)
+		if errors.Is(err, ErrTokenNotFound) {
+			continue // Removido entre o SMEMBERS e o HGETALL
+		}
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// Update altera o plano, o dono ou a expiração de um token
+// Só os campos alterados são gravados: uma revogação simultânea não é desfeita
+func (s *Store) Update(ctx context.Context, id string, update Update) (*Record, error) {
+	if _, err := s.Get(ctx, id); err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]any)
+	if update.Plan != nil {
+		fields["plan"] = *update.Plan
+	}
+	if update.Owner != nil {
+		fields["owner"] = *update.Owner
+	}
+	if update.ExpiresAt != nil {
+		fields["expires_at"] = unixMilli(truncate(update.ExpiresAt))
+	}
+	if len(fields) == 0 {
+		return s.Get(ctx, id)
+	}
+
+	record, err := s.set(ctx, id, fields)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao atualizar token: %w", err)
+	}
+	return record, nil
+}
+
+// Revoke invalida um token imediatamente; o registro é mantido para auditoria
+func (s *Store) Revoke(ctx context.Context, id string) (*Record, error) {
+	record, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if record.RevokedAt != nil {
+		return record, ErrTokenRevoked
+	}
+
+	now := s.now().UTC().Truncate(time.Millisecond)
+	record, err = s.set(ctx, id, map[string]any{"revoked_at": unixMilli(&now)})
+	if err != nil {
+		return nil, fmt.Errorf("erro ao revogar token: %w", err)
+	}
+	return record, nil
+}
+
+// set grava alguns campos do registro e o relê na mesma transação, então o
+// registro retornado inclui alterações feitas em paralelo nos outros campos
+func (s *Store) set(ctx context.Context, id string, fields map[string]any) (*Record, error) {
+	var values *redis.MapStringStringCmd
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, recordKey(id), fields)
+		values = pipe.HGetAll(ctx, recordKey(id))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return parseRecord(id, values.Val())
+}
+
+// Authenticate busca o token pelo segredo enviado no API_KEY e confere se ele
+// pode ser usado: retorna ErrTokenNotFound, ErrTokenRevoked ou ErrTokenExpired
+func (s *Store) Authenticate(ctx context.Context, secret string) (*Record, error) {
+	result, err := lookupScript.Run(ctx, s.client, []string{secretKey(s.hasher.Hash(secret))}, storePrefix).StringSlice()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrTokenNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("erro ao consultar token: %w", err)
+	}
+
+	values := make(map[string]string, len(result)/2)
+	for i := 0; i+1 < len(result); i += 2 {
+		values[result[i]] = result[i+1]
+	}
+	if len(values) == 0 {
+		return nil, ErrTokenNotFound
+	}
+
+	record, err := parseRecord(values["id"], values)
+	if err != nil {
+		return nil, err
+	}
+	if err := record.validate(s.now()); err != nil {
+		return record, err
+	}
+	return record, nil
+}
+
+func recordFields(record *Record) map[string]any {
+	return map[string]any{
+		"id":         record.ID,
+		"key_hash":   record.KeyHash,
+		"plan":       record.Plan,
+		"owner":      record.Owner,
+		"created_at": record.CreatedAt.UnixMilli(),
+		"expires_at": unixMilli(record.ExpiresAt),
+		"revoked_at": unixMilli(record.RevokedAt),
+	}
+}
+
+func parseRecord(id string, values map[string]string) (*Record, error) {
+	createdAt, err := strconv.ParseInt(values["created_at"], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler token %s: created_at inválido", id)
+	}
+
+	return &Record{
+		ID:        id,
+		KeyHash:   values["key_hash"],
+		Plan:      values["plan"],
+		Owner:     values["owner"],
+		CreatedAt: time.UnixMilli(createdAt).UTC(),
+		ExpiresAt: parseMilli(values["expires_at"]),
+		RevokedAt: parseMilli(values["revoked_at"]),
+	}, nil
+}
+
+// unixMilli usa 0 para datas ausentes
+func unixMilli(t *time.Time) int64 {
+	if t == nil {
+		return 0
+	}
+	return t.UnixMilli()
+}
+
+func parseMilli(value string) *time.Time {
+	ms, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || ms == 0 {
+		return nil
+	}
+	t := time.UnixMilli(ms).UTC()
+	return &t
+}
+
+// truncate normaliza para a precisão guardada no Redis (milissegundos, UTC)
+func truncate(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	normalized := t.UTC().Truncate(time.Millisecond)
+	return &normalized
+}
+
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("erro ao gerar token: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func recordKey(id string) string {
+	return storePrefix + id
+}
+
+// secretKey recebe o segredo já passado pelo Hasher
+func secretKey(secret string) string {
+	return storePrefix + "secret:" + secret
+}
+
+func indexKey() string {
+	return storePrefix + "index"
+}
@@ -0,0 +1,278 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/access"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/admin"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/config"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/limiter"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/middleware"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/tokens"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const accessYAML = `
+allow:
+  ips: [10.0.0.0/8, "2001:db8:aaaa::/48", 127.0.0.1]
+  tokens: [internal-monitoring]
+deny:
+  ips: [10.6.6.0/24, 203.0.113.7]
+  tokens: [leaked-token]
+`
+
+func TestAccessList_Match(t *testing.T) {
+	list, err := access.Parse([]byte(accessYAML), nil)
+	require.NoError(t, err)
+
+	cases := []struct {
+		ip, token string
+		want      access.Action
+	}{
+		{"10.1.2.3", "", access.Allow},
+		{"10.6.6.9", "", access.Deny}, // Deny vence allow
+		{"::ffff:10.1.2.3", "", access.Allow},
+		{"2001:db8:aaaa:1::1", "", access.Allow},
+		{"2001:db8:aaab::1", "", ""},
+		{"127.0.0.1", "", access.Allow},
+		{"127.0.0.2", "", ""},
+		{"203.0.113.7", "internal-monitoring", access.Deny},
+		{"198.51.100.1", "internal-monitoring", access.Allow},
+		{"10.1.2.3", "leaked-token", access.Deny},
+		{"", "", ""},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.want, list.Match(tc.ip, tc.token), "%s %s", tc.ip, tc.token)
+	}
+
+	// Lista nil não casa nada
+	var none *access.List
+	assert.Equal(t, access.Action(""), none.Match("10.1.2.3", ""))
+	assert.Equal(t, access.Deny, access.Combine(access.Allow, access.Deny, ""))
+	assert.Equal(t, access.Allow, access.Combine("", access.Allow))
+}
+
+func TestAccessList_ManyCIDRs(t *testing.T) {
+	var entries []access.Entry
+	for i := 0; i < 256; i++ {
+		for j := 0; j < 64; j++ {
+			entries = append(entries, access.Entry{Action: access.Deny, Type: access.EntryIP, Value: fmt.Sprintf("100.%d.%d.0/24", i, j)})
+		}
+	}
+	list, err := access.NewList(entries)
+	require.NoError(t, err)
+
+	assert.Equal(t, access.Deny, list.Match("100.255.63.200", ""))
+	assert.Equal(t, access.Action(""), list.Match("100.255.64.1", ""))
+}
+
+func TestAccessList_FileValidation(t *testing.T) {
+	_, err := access.Parse([]byte(`
+allow:
+  ips: [10.0.0.0/33, localhost]
+deny:
+  tokens: [""]
+  cidrs: [1.2.3.4]
+`), nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "cidrs") // Campo desconhecido
+
+	_, err = access.Parse([]byte(`
+allow:
+  ips: [10.0.0.0/33, localhost]
+deny:
+  tokens: [""]
+`), nil)
+	require.Error(t, err)
+	for _, expected := range []string{
+		`allow.ips[0]: CIDR inválido "10.0.0.0/33"`,
+		`allow.ips[1]: IP inválido "localhost"`,
+		"deny.tokens[0]: token vazio",
+	} {
+		assert.Contains(t, err.Error(), expected)
+	}
+
+	// Com HMAC, os tokens do arquivo não ficam em memória em texto puro
+	hasher := tokens.NewHasher("s3cr3t")
+	list, err := access.Parse([]byte(accessYAML), hasher)
+	require.NoError(t, err)
+	assert.Equal(t, access.Deny, list.Match("", hasher.Hash("leaked-token")))
+	assert.Equal(t, access.Action(""), list.Match("", "leaked-token"))
+	for _, entry := range list.Entries() {
+		assert.NotEqual(t, "leaked-token", entry.Value)
+	}
+}
+
+func TestAccessList_Diff(t *testing.T) {
+	old, err := access.Parse([]byte(accessYAML), nil)
+	require.NoError(t, err)
+	updated, err := access.Parse([]byte(`
+allow:
+  ips: [10.0.0.0/8, "2001:db8:aaaa::/48", 127.0.0.1, 192.168.0.0/16]
+  tokens: [internal-monitoring]
+deny:
+  ips: [10.6.6.0/24]
+  tokens: [leaked-token]
+`), nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"allow ip 192.168.0.0/16 adicionado",
+		"deny ip 203.0.113.7/32 removido",
+	}, access.Diff(old, updated))
+}
+
+func TestRateLimiterMiddleware_AccessLists(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// Mesmo caminho da inicialização do servidor: RATE_LIMIT_ACCESS_FILE →
+	// LoadLimits → WithLimits, sem passar por Reload
+	cfg := &config.Config{
+		RateLimitIPRPS:      1,
+		RateLimitTokenRPS:   1,
+		RateLimitAlgorithm:  "fixed_window",
+		RateLimitAccessFile: writeRulesFile(t, "access.yaml", accessYAML),
+	}
+	limits, err := middleware.LoadLimits(cfg, nil, nil)
+	require.NoError(t, err)
+	require.NotNil(t, limits.Access)
+
+	strategy, rdb := newMiniRedisStrategy(t)
+	rl := limiter.NewRateLimiter(strategy)
+	rateLimiterMiddleware := middleware.NewRateLimiterMiddleware(rl, cfg, middleware.WithLimits(limits))
+
+	router := gin.New()
+	router.Use(rateLimiterMiddleware.Middleware())
+	router.GET("/", func(c *gin.Context) { c.JSON(200, gin.H{"message": "ok"}) })
+
+	request := func(remote, token string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.RemoteAddr = remote
+		if token != "" {
+			req.Header.Set("API_KEY", token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Rede interna e token de monitoramento nunca são limitados
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, http.StatusOK, request("10.1.2.3:5000", "").Code)
+		assert.Equal(t, http.StatusOK, request("198.51.100.1:5000", "internal-monitoring").Code)
+	}
+
+	// Bloqueados recebem 403, sem criar contadores
+	w := request("203.0.113.7:5000", "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Empty(t, w.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, http.StatusForbidden, request("198.51.100.1:5000", "leaked-token").Code)
+
+	keys, err := rdb.Keys(context.Background(), "*").Result()
+	require.NoError(t, err)
+	assert.Empty(t, keys, "Listas são avaliadas antes do rate limiter")
+
+	// Os demais seguem o limite normal
+	assert.Equal(t, http.StatusOK, request("198.51.100.2:5000", "").Code)
+	assert.Equal(t, http.StatusTooManyRequests, request("198.51.100.2:5000", "").Code)
+}
+
+func TestAdminAccessAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	strategy, rdb := newMiniRedisStrategy(t)
+	hasher := tokens.NewHasher("s3cr3t")
+	store := access.NewStore(rdb)
+	fileList, err := access.Parse([]byte("allow:\n  ips: [127.0.0.1]\n"), hasher)
+	require.NoError(t, err)
+
+	rl := limiter.NewRateLimiter(strategy)
+	rateLimiterMiddleware := middleware.NewRateLimiterMiddleware(rl, &config.Config{RateLimitIPRPS: 100, RateLimitTokenRPS: 100},
+		middleware.WithAccessStore(store),
+		middleware.WithTokenHasher(hasher),
+	)
+
+	router := gin.New()
+	router.Use(rateLimiterMiddleware.Middleware())
+	router.GET("/", func(c *gin.Context) { c.JSON(200, gin.H{"message": "ok"}) })
+	admin.NewAccessHandler(store, func() *access.List { return fileList }, hasher).Register(router.Group("/admin"), "admin-secret")
+
+	call := func(method, path, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.RemoteAddr = "127.0.0.1:5000"
+		req.Header.Set("X-Admin-Key", "admin-secret")
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	request := func(remote, token string) int {
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.RemoteAddr = remote
+		req.Header.Set("API_KEY", token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, request("203.0.113.50:5000", ""))
+
+	w := call("POST", "/admin/access/deny", `{"ip": "203.0.113.0/24", "reason": "scraper"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, http.StatusForbidden, request("203.0.113.50:5000", ""))
+
+	w = call("POST", "/admin/access/deny", `{"token": "abuser-token"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.NotContains(t, w.Body.String(), "abuser-token")
+	assert.Equal(t, http.StatusForbidden, request("198.51.100.1:5000", "abuser-token"))
+
+	// Validação
+	assert.Equal(t, http.StatusBadRequest, call("POST", "/admin/access/block", `{"ip": "1.2.3.4"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, call("POST", "/admin/access/deny", `{"ip": "1.2.3.4/40"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, call("POST", "/admin/access/deny", `{"ip": "1.2.3.4", "token": "x"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, call("POST", "/admin/access/deny", `{}`).Code)
+
+	// Listagem com as entradas do arquivo e da API
+	w = call("GET", "/admin/access", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var listed struct {
+		Entries []access.Entry `json:"entries"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &listed))
+	assert.Len(t, listed.Entries, 3)
+	assert.Contains(t, listed.Entries, access.Entry{Action: access.Allow, Type: access.EntryIP, Value: "127.0.0.1/32", Source: "file"})
+	assert.Contains(t, listed.Entries, access.Entry{Action: access.Deny, Type: access.EntryIP, Value: "203.0.113.0/24", Reason: "scraper", Source: "api"})
+
+	// Outra instância enxerga as entradas após o refresh
+	other := access.NewStore(rdb)
+	require.NoError(t, other.Refresh(context.Background()))
+	assert.Equal(t, access.Deny, other.List().Match("203.0.113.1", ""))
+
+	// Remoção
+	assert.Equal(t, http.StatusNoContent, call("DELETE", "/admin/access/deny?ip=203.0.113.0/24", "").Code)
+	assert.Equal(t, http.StatusNotFound, call("DELETE", "/admin/access/deny?ip=203.0.113.0/24", "").Code)
+	assert.Equal(t, http.StatusNoContent, call("DELETE", "/admin/access/deny?token=abuser-token", "").Code)
+	assert.Equal(t, http.StatusOK, request("203.0.113.50:5000", "abuser-token"))
+}
+
+func TestAccessStore_Memory(t *testing.T) {
+	store := access.NewStore(nil)
+	ctx := context.Background()
+
+	entry, err := store.Add(ctx, access.Entry{Action: access.Allow, Type: access.EntryIP, Value: "10.1.2.3/8"})
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.0/8", entry.Value)
+	assert.Equal(t, access.Allow, store.List().Match("10.9.9.9", ""))
+
+	require.NoError(t, store.Remove(ctx, access.Entry{Action: access.Allow, Type: access.EntryIP, Value: "10.0.0.0/8"}))
+	assert.Equal(t, access.Action(""), store.List().Match("10.9.9.9", ""))
+	assert.ErrorIs(t, store.Remove(ctx, entry), access.ErrEntryNotFound)
+}
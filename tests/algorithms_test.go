@@ -0,0 +1,484 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/limiter"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock é um relógio controlado manualmente pelos testes
+type fakeClock struct {
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+// newMiniRedisStrategy cria um RedisStrategy sobre um Redis em memória (miniredis)
+// Os scripts Lua dos algoritmos rodam no interpretador Lua do miniredis
+func newMiniRedisStrategy(t *testing.T) (*limiter.RedisStrategy, *redis.Client) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+
+	return limiter.NewRedisStrategy(rdb), rdb
+}
+
+func TestTokenBucket_BurstAndRefill(t *testing.T) {
+	strategy, _ := newMiniRedisStrategy(t)
+	clock := newFakeClock()
+	rl := limiter.NewRateLimiter(strategy, limiter.WithClock(clock.Now))
+
+	config := limiter.LimitConfig{
+		RPS:       2,  // Repõe 2 tokens por segundo
+		Burst:     10, // Permite rajada de até 10 requisições
+		BlockTime: 0,  // Sem bloqueio: apenas aguarda reposição
+		Algorithm: limiter.TokenBucket,
+	}
+
+	ctx := context.Background()
+	key := "tb-burst"
+
+	// Rajada inicial consome toda a capacidade do bucket
+	for i := 1; i <= 10; i++ {
+		result, err := rl.Check(ctx, key, config)
+		require.NoError(t, err)
+		assert.True(t, result.Allowed, "Requisição %d da rajada deveria passar", i)
+		assert.Equal(t, 10-i, result.Remaining)
+	}
+
+	result, err := rl.Check(ctx, key, config)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed, "Bucket vazio deveria negar")
+	assert.False(t, result.Blocked, "BlockTime zero não deveria bloquear")
+
+	// Meio segundo repõe exatamente 1 token (2 tokens/s)
+	clock.Advance(500 * time.Millisecond)
+	result, err = rl.Check(ctx, key, config)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed, "Token reposto deveria permitir")
+
+	result, err = rl.Check(ctx, key, config)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed)
+}
+
+func TestTokenBucket_FractionalRefill(t *testing.T) {
+	strategy, _ := newMiniRedisStrategy(t)
+	clock := newFakeClock()
+	rl := limiter.NewRateLimiter(strategy, limiter.WithClock(clock.Now))
+
+	config := limiter.LimitConfig{
+		RPS:       4,
+		Burst:     1,
+		Algorithm: limiter.TokenBucket,
+	}
+
+	ctx := context.Background()
+	key := "tb-fraction"
+
+	result, err := rl.Check(ctx, key, config)
+	require.NoError(t, err)
+	require.True(t, result.Allowed)
+
+	// 3 frações de 100ms somam 0.3 * 4 = 1.2 tokens: apenas a última passa
+	for i := 0; i < 2; i++ {
+		clock.Advance(100 * time.Millisecond)
+		result, err = rl.Check(ctx, key, config)
+		require.NoError(t, err)
+		assert.False(t, result.Allowed, "Token parcial não deveria permitir")
+	}
+
+	clock.Advance(100 * time.Millisecond)
+	result, err = rl.Check(ctx, key, config)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed, "Frações acumuladas deveriam formar um token")
+}
+
+func TestTokenBucket_BurstExceededWaitsForRefill(t *testing.T) {
+	strategy, _ := newMiniRedisStrategy(t)
+	clock := newFakeClock()
+	rl := limiter.NewRateLimiter(strategy, limiter.WithClock(clock.Now))
+
+	config := limiter.LimitConfig{
+		RPS:       1,
+		Burst:     3,
+		Algorithm: limiter.TokenBucket,
+	}
+
+	ctx := context.Background()
+	key := "tb-refill"
+
+	for i := 0; i < 3; i++ {
+		result, err := rl.Check(ctx, key, config)
+		require.NoError(t, err)
+		assert.True(t, result.Allowed)
+	}
+
+	// Passar da rajada só exige esperar a reposição de um token
+	result, err := rl.Check(ctx, key, config)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, time.Second, result.RetryAfter)
+
+	blocked, err := strategy.IsBlocked(ctx, key)
+	require.NoError(t, err)
+	assert.False(t, blocked, "Sem BlockTime explícito, exceder a rajada não bloqueia")
+
+	clock.Advance(time.Second)
+	result, err = rl.Check(ctx, key, config)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+
+	// BlockTime explícito no limite continua bloqueando
+	config.BlockTime = time.Minute
+	result, err = rl.Check(ctx, key, config)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, time.Minute, result.RetryAfter)
+
+	blocked, err = strategy.IsBlocked(ctx, key)
+	require.NoError(t, err)
+	assert.True(t, blocked)
+}
+
+func TestParseAlgorithm(t *testing.T) {
+	alg, err := limiter.ParseAlgorithm("")
+	require.NoError(t, err)
+	assert.Equal(t, limiter.FixedWindow, alg)
+
+	alg, err = limiter.ParseAlgorithm(" Token_Bucket ")
+	require.NoError(t, err)
+	assert.Equal(t, limiter.TokenBucket, alg)
+
+	_, err = limiter.ParseAlgorithm("random")
+	assert.Error(t, err)
+}
+
+func TestSlidingWindowLog_NoBoundaryBurst(t *testing.T) {
+	strategy, _ := newMiniRedisStrategy(t)
+	clock := newFakeClock()
+	rl := limiter.NewRateLimiter(strategy, limiter.WithClock(clock.Now))
+
+	config := limiter.LimitConfig{
+		RPS:       5,
+		Algorithm: limiter.SlidingWindowLog,
+	}
+
+	ctx := context.Background()
+	key := "swl-boundary"
+
+	// Rajada no fim de um "segundo"
+	clock.Advance(900 * time.Millisecond)
+	for i := 1; i <= 5; i++ {
+		result, err := rl.Check(ctx, key, config)
+		require.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, 5-i, result.Remaining)
+		assert.Equal(t, clock.Now().Add(time.Second), result.ResetTime)
+	}
+
+	// Logo após a virada, a janela deslizante ainda contém as 5 requisições
+	clock.Advance(200 * time.Millisecond)
+	result, err := rl.Check(ctx, key, config)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed, "Não deveria permitir 2x RPS na virada do segundo")
+	assert.Equal(t, 0, result.Remaining)
+	assert.Equal(t, clock.Now().Add(800*time.Millisecond), result.ResetTime)
+
+	// Um segundo após a rajada, todas as entradas saíram da janela
+	clock.Advance(800 * time.Millisecond)
+	result, err = rl.Check(ctx, key, config)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 4, result.Remaining)
+}
+
+func TestSlidingWindowLog_SlotsFreeGradually(t *testing.T) {
+	strategy, rdb := newMiniRedisStrategy(t)
+	clock := newFakeClock()
+	rl := limiter.NewRateLimiter(strategy, limiter.WithClock(clock.Now))
+
+	config := limiter.LimitConfig{
+		RPS:       2,
+		Algorithm: limiter.SlidingWindowLog,
+	}
+
+	ctx := context.Background()
+	key := "swl-gradual"
+
+	_, err := rl.Check(ctx, key, config)
+	require.NoError(t, err)
+	clock.Advance(400 * time.Millisecond)
+	_, err = rl.Check(ctx, key, config)
+	require.NoError(t, err)
+
+	// A primeira requisição expira em t=1000ms, a segunda em t=1400ms
+	clock.Advance(600 * time.Millisecond)
+	result, err := rl.Check(ctx, key, config)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed, "Primeira entrada já deveria ter expirado")
+
+	result, err = rl.Check(ctx, key, config)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed, "Segunda entrada ainda está na janela")
+
+	// O log só guarda as requisições aceitas
+	size, err := rdb.ZCard(ctx, "rate:{swl-gradual}:log").Result()
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), size)
+}
+
+func TestSlidingWindowCounter_WeightsPreviousWindow(t *testing.T) {
+	strategy, rdb := newMiniRedisStrategy(t)
+	clock := newFakeClock()
+	rl := limiter.NewRateLimiter(strategy, limiter.WithClock(clock.Now))
+
+	config := limiter.LimitConfig{
+		RPS:       10,
+		Algorithm: limiter.SlidingWindowCounter,
+	}
+
+	ctx := context.Background()
+	key := "swc-weight"
+
+	// Rajada completa no fim da janela anterior (t=0.9s)
+	clock.Advance(900 * time.Millisecond)
+	for i := 1; i <= 10; i++ {
+		result, err := rl.Check(ctx, key, config)
+		require.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, 10-i, result.Remaining)
+	}
+
+	// Na virada (t=1.0s) a janela anterior pesa 100%: nada passa
+	clock.Advance(100 * time.Millisecond)
+	result, err := rl.Check(ctx, key, config)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed)
+
+	// Em t=1.5s a janela anterior pesa 50% (estimativa 5): 5 requisições passam
+	clock.Advance(500 * time.Millisecond)
+	allowed := 0
+	for i := 0; i < 10; i++ {
+		result, err := rl.Check(ctx, key, config)
+		require.NoError(t, err)
+		if result.Allowed {
+			allowed++
+		}
+	}
+	assert.Equal(t, 5, allowed)
+
+	// Estado guardado em exatamente duas chaves rate: (janela atual e anterior)
+	keys, err := rdb.Keys(ctx, "rate:{swc-weight}:*").Result()
+	require.NoError(t, err)
+	assert.Len(t, keys, 2)
+}
+
+func TestSlidingWindowCounter_NoBoundaryBurst(t *testing.T) {
+	ctx := context.Background()
+
+	// Janela fixa (Increment): o contador reinicia quando o TTL de 1s expira,
+	// então uma rajada logo antes e outra logo depois passam inteiras (2x RPS)
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+	fixed := limiter.NewRateLimiter(limiter.NewRedisStrategy(rdb))
+	fixedConfig := limiter.LimitConfig{RPS: 10, Algorithm: limiter.FixedWindow}
+
+	fixedAllowed := 0
+	for burst := 0; burst < 2; burst++ {
+		for i := 0; i < 10; i++ {
+			result, err := fixed.Check(ctx, "fixed-boundary", fixedConfig)
+			require.NoError(t, err)
+			if result.Allowed {
+				fixedAllowed++
+			}
+		}
+		mr.FastForward(time.Second)
+	}
+	assert.Equal(t, 20, fixedAllowed, "Janela fixa aceita 2x RPS na virada")
+
+	// Sliding window counter com o mesmo padrão (t=0.95s e t=1.05s)
+	strategy, _ := newMiniRedisStrategy(t)
+	clock := newFakeClock()
+	sliding := limiter.NewRateLimiter(strategy, limiter.WithClock(clock.Now))
+	slidingConfig := limiter.LimitConfig{RPS: 10, Algorithm: limiter.SlidingWindowCounter}
+
+	clock.Advance(950 * time.Millisecond)
+	slidingAllowed := 0
+	for burst := 0; burst < 2; burst++ {
+		for i := 0; i < 10; i++ {
+			result, err := sliding.Check(ctx, "sliding-boundary", slidingConfig)
+			require.NoError(t, err)
+			if result.Allowed {
+				slidingAllowed++
+			}
+		}
+		clock.Advance(100 * time.Millisecond)
+	}
+	assert.Equal(t, 10, slidingAllowed, "Sliding window counter não deveria exceder RPS na virada")
+}
+
+func TestGCRA_BurstAndExactRetryAfter(t *testing.T) {
+	strategy, rdb := newMiniRedisStrategy(t)
+	clock := newFakeClock()
+	rl := limiter.NewRateLimiter(strategy, limiter.WithClock(clock.Now))
+
+	config := limiter.LimitConfig{
+		RPS:       5, // Intervalo de emissão de 200ms
+		Burst:     5,
+		Algorithm: limiter.GCRA,
+	}
+
+	ctx := context.Background()
+	key := "gcra"
+
+	for i := 1; i <= 5; i++ {
+		result, err := rl.Check(ctx, key, config)
+		require.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, 5-i, result.Remaining)
+		assert.Equal(t, clock.Now().Add(time.Duration(i)*200*time.Millisecond), result.ResetTime)
+		assert.Zero(t, result.RetryAfter)
+	}
+
+	// A 6ª requisição só é aceita quando uma célula for emitida (200ms)
+	result, err := rl.Check(ctx, key, config)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 200*time.Millisecond, result.RetryAfter)
+	assert.Equal(t, clock.Now().Add(time.Second), result.ResetTime)
+
+	clock.Advance(150 * time.Millisecond)
+	result, err = rl.Check(ctx, key, config)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 50*time.Millisecond, result.RetryAfter)
+
+	clock.Advance(50 * time.Millisecond)
+	result, err = rl.Check(ctx, key, config)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+
+	// Todo o estado do GCRA fica em uma única chave
+	keys, err := rdb.Keys(ctx, "rate:{gcra}*").Result()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"rate:{gcra}:gcra"}, keys)
+}
+
+func TestRetryAfter_PerAlgorithm(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		algorithm limiter.Algorithm
+		expected  time.Duration
+	}{
+		// 2 tokens/s: falta 1 token inteiro = 500ms
+		{"token bucket", limiter.TokenBucket, 500 * time.Millisecond},
+		// A requisição mais antiga sai da janela em 1s
+		{"sliding window log", limiter.SlidingWindowLog, time.Second},
+		// Na virada, 2 requisições na janela atual: aguarda até a anterior pesar 1.5
+		{"sliding window counter", limiter.SlidingWindowCounter, 1500 * time.Millisecond},
+		// Intervalo de emissão = 500ms
+		{"gcra", limiter.GCRA, 500 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			strategy, _ := newMiniRedisStrategy(t)
+			clock := newFakeClock()
+			rl := limiter.NewRateLimiter(strategy, limiter.WithClock(clock.Now))
+			config := limiter.LimitConfig{RPS: 2, Algorithm: tt.algorithm}
+
+			for i := 0; i < 2; i++ {
+				result, err := rl.Check(ctx, "retry", config)
+				require.NoError(t, err)
+				require.True(t, result.Allowed)
+			}
+
+			result, err := rl.Check(ctx, "retry", config)
+			require.NoError(t, err)
+			assert.False(t, result.Allowed)
+			assert.Equal(t, tt.expected, result.RetryAfter)
+		})
+	}
+}
+
+func TestRetryAfter_BlockTimePrevails(t *testing.T) {
+	strategy, _ := newMiniRedisStrategy(t)
+	clock := newFakeClock()
+	rl := limiter.NewRateLimiter(strategy, limiter.WithClock(clock.Now))
+
+	config := limiter.LimitConfig{RPS: 1, BlockTime: 30 * time.Second, Algorithm: limiter.GCRA}
+	ctx := context.Background()
+
+	_, err := rl.Check(ctx, "retry-block", config)
+	require.NoError(t, err)
+
+	result, err := rl.Check(ctx, "retry-block", config)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 30*time.Second, result.RetryAfter)
+
+	result, err = rl.Check(ctx, "retry-block", config)
+	require.NoError(t, err)
+	assert.True(t, result.Blocked)
+	assert.Equal(t, 30*time.Second, result.RetryAfter)
+	assert.Equal(t, clock.Now().Add(30*time.Second), result.ResetTime)
+}
+
+func TestLeakyBucket_QueuesUpToMaxWait(t *testing.T) {
+	strategy, _ := newMiniRedisStrategy(t)
+	clock := newFakeClock()
+	rl := limiter.NewRateLimiter(strategy, limiter.WithClock(clock.Now))
+
+	config := limiter.LimitConfig{
+		RPS:       10, // Uma requisição sai a cada 100ms
+		MaxWait:   300 * time.Millisecond,
+		BlockTime: time.Minute, // Ignorado: fila cheia não bloqueia
+		Algorithm: limiter.LeakyBucket,
+	}
+
+	ctx := context.Background()
+	key := "leaky"
+
+	// Rajada simultânea: cada requisição aguarda a anterior vazar
+	for i := 0; i < 4; i++ {
+		result, err := rl.Check(ctx, key, config)
+		require.NoError(t, err)
+		assert.True(t, result.Allowed, "Requisição %d deveria entrar na fila", i)
+		assert.Equal(t, time.Duration(i)*100*time.Millisecond, result.Delay)
+		assert.Equal(t, 3-i, result.Remaining)
+	}
+
+	// A próxima esperaria 400ms, acima do MaxWait
+	result, err := rl.Check(ctx, key, config)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.False(t, result.Blocked)
+	assert.Zero(t, result.Delay)
+	assert.Equal(t, 100*time.Millisecond, result.RetryAfter)
+
+	// Depois que uma requisição vaza, abre espaço na fila
+	clock.Advance(100 * time.Millisecond)
+	result, err = rl.Check(ctx, key, config)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 300*time.Millisecond, result.Delay)
+}
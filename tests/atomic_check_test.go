@@ -0,0 +1,141 @@
+package tests
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/limiter"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Teste de concorrência entre "instâncias": vários RateLimiters dividindo o mesmo Redis
+func TestAtomicCheck_RaceFreeAcrossInstances(t *testing.T) {
+	mr := miniredis.RunT(t)
+	ctx := context.Background()
+
+	algorithms := []limiter.Algorithm{
+		limiter.FixedWindow,
+		limiter.TokenBucket,
+		limiter.SlidingWindowLog,
+		limiter.SlidingWindowCounter,
+		limiter.GCRA,
+	}
+
+	for _, algorithm := range algorithms {
+		t.Run(string(algorithm), func(t *testing.T) {
+			mr.FlushAll()
+
+			config := limiter.LimitConfig{
+				RPS:       10,
+				BlockTime: time.Minute,
+				Algorithm: algorithm,
+			}
+
+			results := make(chan *limiter.CheckResult, 60)
+			var wg sync.WaitGroup
+
+			// Relógio parado: todas as requisições chegam no mesmo instante
+			clock := newFakeClock()
+
+			// 3 instâncias, cada uma com seu próprio cliente Redis
+			for instance := 0; instance < 3; instance++ {
+				rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+				defer rdb.Close()
+				rl := limiter.NewRateLimiter(limiter.NewRedisStrategy(rdb), limiter.WithClock(clock.Now))
+
+				for i := 0; i < 20; i++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						result, err := rl.Check(ctx, "shared-key", config)
+						if assert.NoError(t, err) {
+							results <- result
+						}
+					}()
+				}
+			}
+
+			wg.Wait()
+			close(results)
+
+			allowed := 0
+			for result := range results {
+				if result.Allowed {
+					allowed++
+				}
+			}
+
+			assert.Equal(t, 10, allowed, "Exatamente RPS requisições deveriam passar")
+		})
+	}
+}
+
+func TestAtomicCheck_BlockedResultHasExactRemainingTime(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+	rl := limiter.NewRateLimiter(limiter.NewRedisStrategy(rdb))
+
+	config := limiter.LimitConfig{RPS: 1, BlockTime: time.Minute}
+	ctx := context.Background()
+
+	_, err := rl.Check(ctx, "blocked", config)
+	require.NoError(t, err)
+
+	result, err := rl.Check(ctx, "blocked", config)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.False(t, result.Blocked, "Bloqueio aplicado nesta requisição")
+
+	// 20s depois, o bloqueio ainda dura 40s
+	mr.FastForward(20 * time.Second)
+	result, err = rl.Check(ctx, "blocked", config)
+	require.NoError(t, err)
+	assert.True(t, result.Blocked)
+	assert.Equal(t, 40*time.Second, result.RetryAfter)
+
+	mr.FastForward(40 * time.Second)
+	result, err = rl.Check(ctx, "blocked", config)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed, "Bloqueio expirado deveria liberar")
+}
+
+func TestAtomicCheck_NoScriptFallback(t *testing.T) {
+	strategy, rdb := newMiniRedisStrategy(t)
+	rl := limiter.NewRateLimiter(strategy)
+	ctx := context.Background()
+
+	require.NoError(t, strategy.LoadScripts(ctx))
+
+	// Simula um restart/flush do Redis: EVALSHA responde NOSCRIPT
+	require.NoError(t, rdb.ScriptFlush(ctx).Err())
+
+	result, err := rl.Check(ctx, "noscript", limiter.LimitConfig{RPS: 5, Algorithm: limiter.GCRA})
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 4, result.Remaining)
+}
+
+func TestAtomicCheck_FixedWindowDoesNotRenewTTL(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+	rl := limiter.NewRateLimiter(limiter.NewRedisStrategy(rdb))
+
+	config := limiter.LimitConfig{RPS: 3}
+	ctx := context.Background()
+
+	// Tráfego constante abaixo do limite: a janela precisa terminar após 1s
+	for i := 0; i < 6; i++ {
+		result, err := rl.Check(ctx, "steady", config)
+		require.NoError(t, err)
+		assert.True(t, result.Allowed, "Requisição %d deveria passar", i)
+		mr.FastForward(500 * time.Millisecond)
+	}
+}
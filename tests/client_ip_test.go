@@ -0,0 +1,286 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/config"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/limiter"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/middleware"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientIPResolver(t *testing.T) {
+	const trusted = "10.0.0.0/8, fd00::/8, 127.0.0.1"
+
+	cases := []struct {
+		name    string
+		header  string // RATE_LIMIT_CLIENT_IP_HEADER
+		remote  string
+		headers map[string][]string
+		want    string
+	}{
+		{
+			name:    "conexão direta ignora X-Forwarded-For forjado",
+			remote:  "203.0.113.9:5000",
+			headers: map[string][]string{"X-Forwarded-For": {"1.2.3.4"}},
+			want:    "203.0.113.9",
+		},
+		{
+			name:    "conexão direta ignora X-Real-IP forjado",
+			remote:  "203.0.113.9:5000",
+			headers: map[string][]string{"X-Real-IP": {"1.2.3.4"}},
+			want:    "203.0.113.9",
+		},
+		{
+			name:    "proxy confiável repassa o cliente",
+			remote:  "10.0.0.2:5000",
+			headers: map[string][]string{"X-Forwarded-For": {"203.0.113.9"}},
+			want:    "203.0.113.9",
+		},
+		{
+			name:    "entradas forjadas à esquerda são ignoradas",
+			remote:  "10.0.0.2:5000",
+			headers: map[string][]string{"X-Forwarded-For": {"1.2.3.4, 203.0.113.9, 10.0.0.1"}},
+			want:    "203.0.113.9",
+		},
+		{
+			name:    "vários headers X-Forwarded-For formam uma lista só",
+			remote:  "10.0.0.2:5000",
+			headers: map[string][]string{"X-Forwarded-For": {"1.2.3.4", "203.0.113.9, 10.0.0.1"}},
+			want:    "203.0.113.9",
+		},
+		{
+			name:    "forjar um IP confiável não ajuda",
+			remote:  "10.0.0.2:5000",
+			headers: map[string][]string{"X-Forwarded-For": {"10.9.9.9, 203.0.113.9"}},
+			want:    "203.0.113.9",
+		},
+		{
+			name:    "entrada inválida interrompe a busca",
+			remote:  "10.0.0.2:5000",
+			headers: map[string][]string{"X-Forwarded-For": {"1.2.3.4, garbage, 10.0.0.1"}},
+			want:    "10.0.0.1",
+		},
+		{
+			name:    "só proxies confiáveis: o mais à esquerda",
+			remote:  "10.0.0.2:5000",
+			headers: map[string][]string{"X-Forwarded-For": {"10.0.0.5, 10.0.0.1"}},
+			want:    "10.0.0.5",
+		},
+		{
+			name:   "proxy confiável sem header",
+			remote: "10.0.0.2:5000",
+			want:   "10.0.0.2",
+		},
+		{
+			name:    "IPv6 e IPv4 mapeado",
+			remote:  "[fd00::1]:5000",
+			headers: map[string][]string{"X-Forwarded-For": {"2001:db8::7, ::ffff:10.0.0.3"}},
+			want:    "2001:db8::7",
+		},
+		{
+			name:    "Forwarded (RFC 7239)",
+			header:  "Forwarded",
+			remote:  "10.0.0.2:5000",
+			headers: map[string][]string{"Forwarded": {`for=1.2.3.4, for="[2001:db8:cafe::17]:4711";proto=https, for=10.0.0.1;by=10.0.0.2`}},
+			want:    "2001:db8:cafe::17",
+		},
+		{
+			name:    "Forwarded com identificador ofuscado",
+			header:  "forwarded",
+			remote:  "10.0.0.2:5000",
+			headers: map[string][]string{"Forwarded": {"for=_hidden, For=10.0.0.1"}},
+			want:    "10.0.0.1",
+		},
+		{
+			name:   "Forwarded ignora X-Forwarded-For",
+			header: "Forwarded",
+			remote: "10.0.0.2:5000",
+			headers: map[string][]string{
+				"Forwarded":       {"for=203.0.113.9"},
+				"X-Forwarded-For": {"1.2.3.4"},
+			},
+			want: "203.0.113.9",
+		},
+		{
+			name:    "X-Real-IP",
+			header:  "X-Real-IP",
+			remote:  "127.0.0.1:5000",
+			headers: map[string][]string{"X-Real-IP": {"203.0.113.9"}},
+			want:    "203.0.113.9",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resolver, err := middleware.NewClientIPResolver(trusted, tc.header)
+			require.NoError(t, err)
+
+			req, _ := http.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tc.remote
+			for name, values := range tc.headers {
+				for _, value := range values {
+					req.Header.Add(name, value)
+				}
+			}
+			assert.Equal(t, tc.want, resolver.ClientIP(req))
+		})
+	}
+}
+
+func TestClientIPResolver_Validation(t *testing.T) {
+	_, err := middleware.NewClientIPResolver("10.0.0.0/33", "")
+	assert.ErrorContains(t, err, `RATE_LIMIT_TRUSTED_PROXIES: CIDR inválido "10.0.0.0/33"`)
+	_, err = middleware.NewClientIPResolver("proxy.local", "")
+	assert.ErrorContains(t, err, `RATE_LIMIT_TRUSTED_PROXIES: IP inválido "proxy.local"`)
+	_, err = middleware.NewClientIPResolver("", "True-Client-IP")
+	assert.ErrorContains(t, err, "RATE_LIMIT_CLIENT_IP_HEADER inválido")
+
+	prefixes, err := middleware.ParseTrustedProxies("10.1.2.3/8, ::1")
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.0/8", prefixes[0].String())
+	assert.Equal(t, "::1/128", prefixes[1].String())
+}
+
+func TestRateLimiterMiddleware_SpoofedForwardedFor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	strategy, _ := newMiniRedisStrategy(t)
+	rl := limiter.NewRateLimiter(strategy)
+	cfg := &config.Config{RateLimitIPRPS: 2, RateLimitTrustedProxies: "10.0.0.0/8"}
+	rateLimiterMiddleware := middleware.NewRateLimiterMiddleware(rl, cfg)
+
+	router := gin.New()
+	router.Use(rateLimiterMiddleware.Middleware())
+	router.GET("/", func(c *gin.Context) { c.String(200, c.GetString(middleware.ClientIPKey)) })
+
+	request := func(remote, forwardedFor string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.RemoteAddr = remote
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Um IP novo a cada requisição não escapa do limite da conexão
+	for i := 0; i < 2; i++ {
+		w := request("203.0.113.9:5000", "1.2.3."+strings.Repeat("1", i+1))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "203.0.113.9", w.Body.String())
+	}
+	w := request("203.0.113.9:5000", "1.2.3.4")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+
+	// Atrás do proxy confiável, cada cliente tem o próprio limite
+	w = request("10.0.0.2:5000", "198.51.100.7")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "198.51.100.7", w.Body.String())
+}
+
+func TestIPPrefixes_Key(t *testing.T) {
+	prefixes, err := middleware.NewIPPrefixes(24, 64)
+	require.NoError(t, err)
+
+	cases := map[string]string{
+		"2001:db8:1:2:a::1":    "2001:db8:1:2::/64",
+		"2001:db8:1:2:ffff::9": "2001:db8:1:2::/64",
+		"2001:db8:1:3::1":      "2001:db8:1:3::/64",
+		"203.0.113.9":          "203.0.113.0/24",
+		"::ffff:203.0.113.200": "203.0.113.0/24", // IPv4 mapeado vira IPv4
+		"fe80::1%eth0":         "fe80::/64",
+		"":                     "",
+	}
+	for ip, expected := range cases {
+		assert.Equal(t, expected, prefixes.Key(ip), ip)
+	}
+
+	// Sem agregação (zero ou tamanho do endereço), o endereço completo
+	full, err := middleware.NewIPPrefixes(32, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "203.0.113.9", full.Key("203.0.113.9"))
+	assert.Equal(t, "2001:db8::1", full.Key("2001:db8::1"))
+	assert.Equal(t, "203.0.113.9", full.Key("::ffff:203.0.113.9"))
+
+	_, err = middleware.NewIPPrefixes(33, 64)
+	assert.ErrorContains(t, err, "RATE_LIMIT_IPV4_PREFIX inválido: 33 (use 0 a 32; 0 = endereço completo)")
+	_, err = middleware.NewIPPrefixes(32, -1)
+	assert.ErrorContains(t, err, "RATE_LIMIT_IPV6_PREFIX inválido: -1")
+}
+
+func TestRateLimiterMiddleware_IPv6PrefixAggregation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	strategy, rdb := newMiniRedisStrategy(t)
+	rl := limiter.NewRateLimiter(strategy)
+	cfg := &config.Config{RateLimitIPRPS: 2, RateLimitIPv4Prefix: 32, RateLimitIPv6Prefix: 64}
+	rateLimiterMiddleware := middleware.NewRateLimiterMiddleware(rl, cfg)
+
+	router := gin.New()
+	router.Use(rateLimiterMiddleware.Middleware())
+	router.GET("/", func(c *gin.Context) { c.JSON(200, gin.H{"message": "ok"}) })
+
+	request := func(remote string) int {
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.RemoteAddr = remote
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Trocar de endereço dentro da /64 não gera um contador novo
+	assert.Equal(t, http.StatusOK, request("[2001:db8:1:2::1]:5000"))
+	assert.Equal(t, http.StatusOK, request("[2001:db8:1:2::2]:5000"))
+	assert.Equal(t, http.StatusTooManyRequests, request("[2001:db8:1:2:dead:beef::3]:5000"))
+
+	// Outra /64 tem o próprio limite
+	assert.Equal(t, http.StatusOK, request("[2001:db8:1:3::1]:5000"))
+
+	// IPv4 continua por endereço
+	assert.Equal(t, http.StatusOK, request("203.0.113.9:5000"))
+	assert.Equal(t, http.StatusOK, request("203.0.113.10:5000"))
+
+	keys, err := rdb.Keys(context.Background(), "rate:*").Result()
+	require.NoError(t, err)
+	assert.Contains(t, keys, "rate:{ip:2001:db8:1:2::/64}")
+	assert.Contains(t, keys, "rate:{ip:203.0.113.9}")
+}
+
+func TestRateLimiterMiddleware_ReloadKeepsTrustedProxies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	strategy, _ := newMiniRedisStrategy(t)
+	rl := limiter.NewRateLimiter(strategy)
+	cfg := &config.Config{RateLimitIPRPS: 5, RateLimitTokenRPS: 10, RateLimitAlgorithm: "fixed_window", RateLimitTrustedProxies: "10.0.0.0/8"}
+	rateLimiterMiddleware := middleware.NewRateLimiterMiddleware(rl, cfg)
+
+	router := gin.New()
+	router.Use(rateLimiterMiddleware.Middleware())
+	router.GET("/", func(c *gin.Context) { c.String(200, c.GetString(middleware.ClientIPKey)) })
+
+	request := func(remote string) string {
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.RemoteAddr = remote
+		req.Header.Set("X-Forwarded-For", "198.51.100.7")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Body.String()
+	}
+
+	// O Gin só aplica os proxies na inicialização: o reload aponta o reinício
+	// e o middleware continua com a lista anterior
+	updated := *cfg
+	updated.RateLimitTrustedProxies = "192.168.0.0/16"
+	changes, err := rateLimiterMiddleware.Reload(middleware.Limits{Config: &updated})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"RATE_LIMIT_TRUSTED_PROXIES: 10.0.0.0/8 → 192.168.0.0/16 (requer reinício)"}, changes)
+
+	assert.Equal(t, "198.51.100.7", request("10.0.0.2:5000"))
+	assert.Equal(t, "192.168.1.1", request("192.168.1.1:5000"))
+}
@@ -0,0 +1,343 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/config"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/limiter"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/middleware"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckAll_DeniedRequestConsumesNothing(t *testing.T) {
+	algorithms := []limiter.Algorithm{
+		limiter.FixedWindow, limiter.TokenBucket, limiter.SlidingWindowLog,
+		limiter.SlidingWindowCounter, limiter.GCRA,
+	}
+
+	for name, storage := range algorithmStorages(t) {
+		for _, algorithm := range algorithms {
+			t.Run(fmt.Sprintf("%s/%s", name, algorithm), func(t *testing.T) {
+				clock := newFakeClock()
+				rl := limiter.NewRateLimiter(storage, limiter.WithClock(clock.Now))
+				ctx := context.Background()
+
+				token := limiter.LimitCheck{Key: "token:" + name + string(algorithm), Config: limiter.LimitConfig{
+					RPS: 5, Algorithm: algorithm, Quotas: []limiter.Quota{{Limit: 100, Period: limiter.QuotaDay}},
+				}}
+				ip := limiter.LimitCheck{Key: "ip:" + name + string(algorithm), Config: limiter.LimitConfig{
+					RPS: 1, Algorithm: algorithm, BlockTime: time.Minute,
+				}}
+
+				// Os dois permitem: ambos são consumidos e vale o mais apertado
+				result, index, err := rl.CheckAll(ctx, []limiter.LimitCheck{token, ip})
+				require.NoError(t, err)
+				assert.True(t, result.Allowed)
+				assert.Equal(t, 1, index)
+				assert.Equal(t, 0, result.Remaining)
+
+				// O IP nega: o token não é consumido
+				for i := 0; i < 3; i++ {
+					result, index, err = rl.CheckAll(ctx, []limiter.LimitCheck{token, ip})
+					require.NoError(t, err)
+					assert.False(t, result.Allowed)
+					assert.Equal(t, 1, index)
+				}
+
+				// O IP ficou bloqueado, como em um Check comum
+				result, err = rl.Check(ctx, ip.Key, ip.Config)
+				require.NoError(t, err)
+				assert.True(t, result.Blocked)
+
+				// Só a primeira requisição contou no token (e no quota)
+				result, err = rl.Check(ctx, token.Key, token.Config)
+				require.NoError(t, err)
+				assert.True(t, result.Allowed)
+				assert.Equal(t, 3, result.Remaining)
+			})
+		}
+	}
+}
+
+func TestCheckAll_AtomicUnderConcurrency(t *testing.T) {
+	for name, storage := range algorithmStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			clock := newFakeClock()
+			rl := limiter.NewRateLimiter(storage, limiter.WithClock(clock.Now))
+			ctx := context.Background()
+
+			// O token vem antes: em duas fases, uma negação do IP depois de o
+			// token já ter sido consumido gastaria o orçamento dele
+			checks := []limiter.LimitCheck{
+				{Key: "token:race-" + name, Config: limiter.LimitConfig{RPS: 100}},
+				{Key: "ip:race-" + name, Config: limiter.LimitConfig{RPS: 10}},
+			}
+
+			var wg sync.WaitGroup
+			var allowed atomic.Int32
+			for i := 0; i < 60; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					result, _, err := rl.CheckAll(ctx, checks)
+					if assert.NoError(t, err) && result.Allowed {
+						allowed.Add(1)
+					}
+				}()
+			}
+			wg.Wait()
+
+			assert.Equal(t, int32(10), allowed.Load())
+
+			// Só as requisições aceitas consumiram o token
+			result, err := rl.Check(ctx, checks[0].Key, checks[0].Config)
+			require.NoError(t, err)
+			assert.Equal(t, 89, result.Remaining)
+		})
+	}
+}
+
+func TestCheckAll_SingleRoundTrip(t *testing.T) {
+	strategy, rdb := newMiniRedisStrategy(t)
+	ctx := context.Background()
+	require.NoError(t, strategy.LoadScripts(ctx))
+	rl := limiter.NewRateLimiter(strategy)
+
+	commands := &commandCounter{}
+	rdb.AddHook(commands)
+
+	checks := []limiter.LimitCheck{
+		{Key: "token:a", Config: limiter.LimitConfig{RPS: 10, Algorithm: limiter.GCRA}},
+		{Key: "ip:1.2.3.4", Config: limiter.LimitConfig{RPS: 1, BlockTime: time.Minute, Algorithm: limiter.SlidingWindowLog}},
+		{Key: "global", Config: limiter.LimitConfig{RPS: 100, Algorithm: limiter.TokenBucket}},
+	}
+
+	for _, allowed := range []bool{true, false} {
+		before := commands.count.Load()
+		result, index, err := rl.CheckAll(ctx, checks)
+		require.NoError(t, err)
+		assert.Equal(t, allowed, result.Allowed)
+		assert.Equal(t, 1, index)
+		assert.Equal(t, before+1, commands.count.Load(), "Todos os limites em um único script")
+	}
+
+	// A negação aplicou o bloqueio do IP
+	blocked, err := strategy.IsBlocked(ctx, "ip:1.2.3.4")
+	require.NoError(t, err)
+	assert.True(t, blocked)
+}
+
+func TestCheckAll_ClusterFallsBackToTwoPhases(t *testing.T) {
+	mr := miniredis.RunT(t)
+
+	// As identidades ficam em slots diferentes: não cabem no mesmo script
+	cluster := redis.NewClusterClient(&redis.ClusterOptions{Addrs: []string{mr.Addr()}})
+	t.Cleanup(func() { cluster.Close() })
+
+	strategy := limiter.NewRedisStrategy(cluster)
+	rl := limiter.NewRateLimiter(strategy)
+	ctx := context.Background()
+
+	_, _, err := strategy.EvaluateAll(ctx, nil)
+	assert.ErrorIs(t, err, limiter.ErrUnsupportedMultiKey)
+
+	token := limiter.LimitCheck{Key: "token:c", Config: limiter.LimitConfig{RPS: 5}}
+	ip := limiter.LimitCheck{Key: "ip:10.0.0.1", Config: limiter.LimitConfig{RPS: 1, BlockTime: time.Minute}}
+
+	result, _, err := rl.CheckAll(ctx, []limiter.LimitCheck{token, ip})
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+
+	result, index, err := rl.CheckAll(ctx, []limiter.LimitCheck{token, ip})
+	require.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 1, index)
+
+	// O dry run não grava o bloqueio; ele é aplicado depois da negação
+	blocked, err := strategy.IsBlocked(ctx, ip.Key)
+	require.NoError(t, err)
+	assert.True(t, blocked)
+
+	result, err = rl.Check(ctx, token.Key, token.Config)
+	require.NoError(t, err)
+	assert.Equal(t, 3, result.Remaining, "A requisição negada não consumiu o token")
+}
+
+func TestCheckAll_ClusterBlockUsesBreaker(t *testing.T) {
+	mr := miniredis.RunT(t)
+
+	cluster := redis.NewClusterClient(&redis.ClusterOptions{Addrs: []string{mr.Addr()}})
+	t.Cleanup(func() { cluster.Close() })
+	cluster.AddHook(&failCommand{name: "set"}) // Só o bloqueio (SET) falha
+
+	clock := newFakeClock()
+	breaker := limiter.NewCircuitBreaker(1, time.Minute)
+	rl := limiter.NewRateLimiter(limiter.NewRedisStrategy(cluster), limiter.WithClock(clock.Now), limiter.WithCircuitBreaker(breaker))
+	ctx := context.Background()
+
+	token := limiter.LimitCheck{Key: "token:c", Config: limiter.LimitConfig{RPS: 5}}
+	ip := limiter.LimitCheck{Key: "ip:10.0.0.1", Config: limiter.LimitConfig{RPS: 1, BlockTime: time.Minute}}
+
+	_, _, err := rl.CheckAll(ctx, []limiter.LimitCheck{token, ip})
+	require.NoError(t, err)
+
+	// A falha ao gravar o bloqueio conta no breaker, que abre
+	_, _, err = rl.CheckAll(ctx, []limiter.LimitCheck{token, ip})
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, limiter.ErrCircuitOpen)
+
+	_, _, err = rl.CheckAll(ctx, []limiter.LimitCheck{token, ip})
+	assert.ErrorIs(t, err, limiter.ErrCircuitOpen)
+}
+
+func TestCheckAll_LeakyBucketDelay(t *testing.T) {
+	for name, storage := range algorithmStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			clock := newFakeClock()
+			rl := limiter.NewRateLimiter(storage, limiter.WithClock(clock.Now))
+			ctx := context.Background()
+
+			queue := limiter.LimitConfig{RPS: 10, Algorithm: limiter.LeakyBucket, MaxWait: time.Second}
+			checks := []limiter.LimitCheck{
+				{Key: "leaky-a", Config: queue},
+				{Key: "leaky-b", Config: limiter.LimitConfig{RPS: 100}},
+			}
+
+			result, _, err := rl.CheckAll(ctx, checks)
+			require.NoError(t, err)
+			assert.Zero(t, result.Delay)
+
+			// A espera da fila vale mesmo quando outro limite é o mais apertado
+			result, _, err = rl.CheckAll(ctx, checks)
+			require.NoError(t, err)
+			assert.True(t, result.Allowed)
+			assert.Equal(t, 100*time.Millisecond, result.Delay)
+		})
+	}
+}
+
+func TestParseCompositeLimits(t *testing.T) {
+	limits, err := middleware.ParseCompositeLimits(&config.Config{RateLimitComposite: " IP , token_ip", RateLimitTokenIPRPS: 5})
+	require.NoError(t, err)
+	assert.Equal(t, []middleware.CompositeLimit{middleware.CompositeIP, middleware.CompositeTokenIP}, limits)
+
+	limits, err = middleware.ParseCompositeLimits(&config.Config{})
+	require.NoError(t, err)
+	assert.Empty(t, limits)
+
+	_, err = middleware.ParseCompositeLimits(&config.Config{RateLimitComposite: "token_ip"})
+	assert.ErrorContains(t, err, "RATE_LIMIT_TOKEN_IP_RPS")
+	_, err = middleware.ParseCompositeLimits(&config.Config{RateLimitComposite: "ip,route"})
+	assert.ErrorContains(t, err, `limite inválido "route"`)
+}
+
+func TestRateLimiterMiddleware_CompositeLimits(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	strategy, _ := newMiniRedisStrategy(t)
+	rl := limiter.NewRateLimiter(strategy)
+	cfg := &config.Config{
+		RateLimitIPRPS:      3,
+		RateLimitTokenRPS:   4,
+		RateLimitTokenIPRPS: 2,
+		RateLimitAlgorithm:  "fixed_window",
+		RateLimitComposite:  "ip,token_ip",
+	}
+	rateLimiterMiddleware := middleware.NewRateLimiterMiddleware(rl, cfg)
+
+	router := gin.New()
+	router.Use(rateLimiterMiddleware.Middleware())
+	router.GET("/", func(c *gin.Context) { c.JSON(200, gin.H{"message": "ok"}) })
+
+	request := func(remote, token string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.RemoteAddr = remote
+		if token != "" {
+			req.Header.Set("API_KEY", token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// token + IP: 2 por segundo, mesmo com folga no token e no IP
+	w := request("10.0.0.1:1000", "leaked")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get("X-RateLimit-Limit"), "Os headers descrevem o limite mais apertado")
+	assert.Equal(t, http.StatusOK, request("10.0.0.1:1000", "leaked").Code)
+	assert.Equal(t, http.StatusTooManyRequests, request("10.0.0.1:1000", "leaked").Code)
+
+	// Token vazado em outros IPs: o limite do token (4) vale para todos eles
+	assert.Equal(t, http.StatusOK, request("10.0.0.2:1000", "leaked").Code)
+	assert.Equal(t, http.StatusOK, request("10.0.0.3:1000", "leaked").Code)
+	w = request("10.0.0.4:1000", "leaked")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "4", w.Header().Get("X-RateLimit-Limit"))
+
+	// Um IP trocando de token esbarra no limite por IP (3), compartilhado com
+	// as requisições sem token; a negação pelo IP não gasta os tokens
+	assert.Equal(t, http.StatusOK, request("10.0.0.5:1000", "a").Code)
+	assert.Equal(t, http.StatusOK, request("10.0.0.5:1000", "b").Code)
+	assert.Equal(t, http.StatusOK, request("10.0.0.5:1000", "").Code)
+	w = request("10.0.0.5:1000", "c")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "3", w.Header().Get("X-RateLimit-Limit"))
+	for i := 6; i < 10; i++ {
+		assert.Equal(t, http.StatusOK, request(fmt.Sprintf("10.0.0.%d:1000", i), "c").Code, "c ainda tem as 4 requisições")
+	}
+	assert.Equal(t, http.StatusTooManyRequests, request("10.0.0.10:1000", "c").Code)
+}
+
+// commandCounter conta os comandos enviados ao Redis (idas e voltas)
+type commandCounter struct {
+	count atomic.Int32
+}
+
+func (h *commandCounter) DialHook(next redis.DialHook) redis.DialHook { return next }
+
+func (h *commandCounter) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		h.count.Add(1)
+		return next(ctx, cmd)
+	}
+}
+
+func (h *commandCounter) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		h.count.Add(1)
+		return next(ctx, cmds)
+	}
+}
+
+// failCommand faz todo comando name falhar
+type failCommand struct {
+	name string
+}
+
+func (h *failCommand) DialHook(next redis.DialHook) redis.DialHook { return next }
+
+func (h *failCommand) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if cmd.Name() == h.name {
+			cmd.SetErr(errors.New("ERR boom"))
+			return cmd.Err()
+		}
+		return next(ctx, cmd)
+	}
+}
+
+func (h *failCommand) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
@@ -0,0 +1,192 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/config"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/limiter"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/middleware"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// concurrencyStorages retorna os storages com suporte a leases
+func concurrencyStorages(t *testing.T) map[string]limiter.ConcurrencyStorage {
+	redisStrategy, _ := newMiniRedisStrategy(t)
+	memoryStrategy := limiter.NewMemoryStrategy(4, time.Hour)
+	t.Cleanup(func() { memoryStrategy.Close() })
+
+	return map[string]limiter.ConcurrencyStorage{
+		"redis":  redisStrategy,
+		"memory": memoryStrategy,
+	}
+}
+
+func TestConcurrencyLimiter_AcquireAndRelease(t *testing.T) {
+	for name, storage := range concurrencyStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			cl := limiter.NewConcurrencyLimiter(storage, time.Minute)
+			ctx := context.Background()
+
+			first, err := cl.Acquire(ctx, "token:export", 2)
+			require.NoError(t, err)
+			assert.True(t, first.Acquired)
+			assert.Equal(t, 1, first.InFlight)
+
+			second, err := cl.Acquire(ctx, "token:export", 2)
+			require.NoError(t, err)
+			assert.True(t, second.Acquired)
+			assert.Equal(t, 2, second.InFlight)
+
+			denied, err := cl.Acquire(ctx, "token:export", 2)
+			require.NoError(t, err)
+			assert.False(t, denied.Acquired)
+			assert.Nil(t, denied.Lease)
+			assert.Equal(t, 2, denied.InFlight)
+
+			// Outra identidade tem suas próprias vagas
+			other, err := cl.Acquire(ctx, "token:other", 2)
+			require.NoError(t, err)
+			assert.True(t, other.Acquired)
+
+			// Liberar uma vaga permite uma nova requisição; liberar de novo não tem efeito
+			require.NoError(t, first.Lease.Release(ctx))
+			require.NoError(t, first.Lease.Release(ctx))
+
+			third, err := cl.Acquire(ctx, "token:export", 2)
+			require.NoError(t, err)
+			assert.True(t, third.Acquired)
+			assert.Equal(t, 2, third.InFlight)
+
+			require.NoError(t, second.Lease.Release(ctx))
+			require.NoError(t, third.Lease.Release(ctx))
+			require.NoError(t, other.Lease.Release(ctx))
+		})
+	}
+}
+
+func TestConcurrencyLimiter_ExpiredLeaseFreesSlot(t *testing.T) {
+	for name, storage := range concurrencyStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			clock := newFakeClock()
+			ctx := context.Background()
+
+			// Simula uma instância que caiu: a lease nunca é liberada nem renovada
+			crashed := limiter.NewConcurrencyLimiter(storage, time.Minute, limiter.WithLeaseClock(clock.Now))
+			lost, err := crashed.Acquire(ctx, "token:export", 1)
+			require.NoError(t, err)
+			require.True(t, lost.Acquired)
+
+			cl := limiter.NewConcurrencyLimiter(storage, time.Minute, limiter.WithLeaseClock(clock.Now))
+			result, err := cl.Acquire(ctx, "token:export", 1)
+			require.NoError(t, err)
+			assert.False(t, result.Acquired, "Vaga ainda ocupada dentro do TTL")
+
+			// Após o TTL a vaga volta sozinha
+			clock.Advance(time.Minute)
+			result, err = cl.Acquire(ctx, "token:export", 1)
+			require.NoError(t, err)
+			assert.True(t, result.Acquired)
+			assert.Equal(t, 1, result.InFlight)
+		})
+	}
+}
+
+func TestConcurrencyLimiter_HeartbeatKeepsLongRequests(t *testing.T) {
+	for name, storage := range concurrencyStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			cl := limiter.NewConcurrencyLimiter(storage, 60*time.Millisecond)
+			ctx := context.Background()
+
+			long, err := cl.Acquire(ctx, "token:export", 1)
+			require.NoError(t, err)
+			require.True(t, long.Acquired)
+
+			// A requisição dura várias vezes o TTL, mas a lease continua renovada
+			time.Sleep(200 * time.Millisecond)
+
+			result, err := cl.Acquire(ctx, "token:export", 1)
+			require.NoError(t, err)
+			assert.False(t, result.Acquired)
+
+			require.NoError(t, long.Lease.Release(ctx))
+
+			result, err = cl.Acquire(ctx, "token:export", 1)
+			require.NoError(t, err)
+			assert.True(t, result.Acquired)
+			require.NoError(t, result.Lease.Release(ctx))
+		})
+	}
+}
+
+func TestRateLimiterMiddleware_ConcurrencyLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{
+		RateLimitTokenRPS:         100,
+		RateLimitTokenConcurrency: 2,
+		RateLimitTokenQuotas:      "3/day",
+	}
+
+	strategy, _ := newMiniRedisStrategy(t)
+	rl := limiter.NewRateLimiter(strategy)
+	cl := limiter.NewConcurrencyLimiter(strategy, time.Minute)
+	rateLimiterMiddleware := middleware.NewRateLimiterMiddleware(rl, cfg, middleware.WithConcurrencyLimiter(cl))
+
+	// O handler só termina quando o teste liberar
+	inHandler := make(chan struct{}, 4)
+	finish := make(chan struct{})
+
+	router := gin.New()
+	router.Use(rateLimiterMiddleware.Middleware())
+	router.GET("/export", func(c *gin.Context) {
+		inHandler <- struct{}{}
+		<-finish
+		c.JSON(200, gin.H{"message": "ok"})
+	})
+
+	request := func() *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/export", nil)
+		req.Header.Set("API_KEY", "exporter")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	var wg sync.WaitGroup
+	codes := make(chan int, 2)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- request().Code
+		}()
+	}
+	<-inHandler
+	<-inHandler
+
+	// Com 2 exportações em andamento, a terceira é rejeitada
+	w := request()
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "2", w.Header().Get("X-Concurrency-Limit"))
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+
+	close(finish)
+	wg.Wait()
+	close(codes)
+	for code := range codes {
+		assert.Equal(t, http.StatusOK, code)
+	}
+
+	// As vagas são liberadas após c.Next(), e a requisição recusada por
+	// concorrência não consumiu o quota
+	w = request()
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+}
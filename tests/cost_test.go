@@ -0,0 +1,243 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/config"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/limiter"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/middleware"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCost_ConsumesUnitsInEveryAlgorithm(t *testing.T) {
+	algorithms := []limiter.Algorithm{
+		limiter.FixedWindow,
+		limiter.TokenBucket,
+		limiter.SlidingWindowLog,
+		limiter.SlidingWindowCounter,
+		limiter.GCRA,
+	}
+
+	for name, storage := range algorithmStorages(t) {
+		for _, algorithm := range algorithms {
+			t.Run(name+"/"+string(algorithm), func(t *testing.T) {
+				clock := newFakeClock()
+				rl := limiter.NewRateLimiter(storage, limiter.WithClock(clock.Now))
+				ctx := context.Background()
+				key := "token:" + string(algorithm)
+
+				config := limiter.LimitConfig{RPS: 10, Algorithm: algorithm}
+
+				result, err := rl.Check(ctx, key, config, limiter.WithCost(4))
+				require.NoError(t, err)
+				assert.True(t, result.Allowed)
+				assert.Equal(t, 6, result.Remaining)
+
+				result, err = rl.Check(ctx, key, config, limiter.WithCost(5))
+				require.NoError(t, err)
+				assert.True(t, result.Allowed)
+				assert.Equal(t, 1, result.Remaining)
+
+				// Custo maior que o restante é negado, mas o restante continua disponível
+				result, err = rl.Check(ctx, key, config, limiter.WithCost(2))
+				require.NoError(t, err)
+				assert.False(t, result.Allowed)
+				assert.Equal(t, 1, result.Remaining)
+
+				result, err = rl.Check(ctx, key, config)
+				require.NoError(t, err)
+				assert.True(t, result.Allowed)
+				assert.Equal(t, 0, result.Remaining)
+			})
+		}
+	}
+}
+
+func TestCost_RetryAfterWaitsForEnoughUnits(t *testing.T) {
+	for name, storage := range algorithmStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			clock := newFakeClock()
+			rl := limiter.NewRateLimiter(storage, limiter.WithClock(clock.Now))
+			ctx := context.Background()
+
+			// 10 tokens/s: faltando 3 tokens, a espera é de 300ms
+			config := limiter.LimitConfig{RPS: 10, Algorithm: limiter.TokenBucket}
+			_, err := rl.Check(ctx, "token:bulk", config, limiter.WithCost(10))
+			require.NoError(t, err)
+
+			result, err := rl.Check(ctx, "token:bulk", config, limiter.WithCost(3))
+			require.NoError(t, err)
+			assert.False(t, result.Allowed)
+			assert.Equal(t, 300*time.Millisecond, result.RetryAfter)
+
+			clock.Advance(300 * time.Millisecond)
+			result, err = rl.Check(ctx, "token:bulk", config, limiter.WithCost(3))
+			require.NoError(t, err)
+			assert.True(t, result.Allowed)
+		})
+	}
+}
+
+func TestCost_ConsumesQuotaUnits(t *testing.T) {
+	for name, storage := range algorithmStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			clock := newFakeClock()
+			rl := limiter.NewRateLimiter(storage, limiter.WithClock(clock.Now))
+			ctx := context.Background()
+
+			config := limiter.LimitConfig{
+				RPS:    100,
+				Quotas: []limiter.Quota{{Limit: 10, Period: limiter.QuotaDay}},
+			}
+
+			result, err := rl.Check(ctx, "token:plan", config, limiter.WithCost(8))
+			require.NoError(t, err)
+			assert.True(t, result.Allowed)
+			assert.Equal(t, 2, result.Remaining)
+
+			result, err = rl.Check(ctx, "token:plan", config, limiter.WithCost(3))
+			require.NoError(t, err)
+			assert.False(t, result.Allowed)
+			assert.True(t, result.QuotaExceeded)
+			assert.Equal(t, 2, result.Remaining)
+		})
+	}
+}
+
+// Com custos variados, memória e Redis continuam tomando as mesmas decisões
+func TestCost_MemoryMatchesRedis(t *testing.T) {
+	algorithms := []limiter.Algorithm{
+		limiter.FixedWindow,
+		limiter.TokenBucket,
+		limiter.SlidingWindowLog,
+		limiter.SlidingWindowCounter,
+		limiter.GCRA,
+		limiter.LeakyBucket,
+	}
+
+	steps := []time.Duration{0, 0, 30, 70, 0, 120, 250, 0, 480, 10, 900, 0, 1100, 0}
+	costs := []int{1, 3, 2, 5, 1, 4, 1, 20, 2, 3, 1, 6, 2, 1}
+
+	for _, algorithm := range algorithms {
+		t.Run(string(algorithm), func(t *testing.T) {
+			ctx := context.Background()
+			clock := newFakeClock()
+
+			mr := miniredis.RunT(t)
+			rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+			defer rdb.Close()
+
+			memoryStrategy := limiter.NewMemoryStrategy(4, time.Hour)
+			defer memoryStrategy.Close()
+
+			redisLimiter := limiter.NewRateLimiter(limiter.NewRedisStrategy(rdb), limiter.WithClock(clock.Now))
+			memoryLimiter := limiter.NewRateLimiter(memoryStrategy, limiter.WithClock(clock.Now))
+
+			config := limiter.LimitConfig{
+				RPS:       6,
+				Burst:     8,
+				MaxWait:   500 * time.Millisecond,
+				BlockTime: 100 * time.Millisecond,
+				Algorithm: algorithm,
+				Quotas:    []limiter.Quota{{Limit: 30, Period: limiter.QuotaMinute}},
+			}
+
+			for i, step := range steps {
+				clock.Advance(step * time.Millisecond)
+				mr.FastForward(step * time.Millisecond)
+
+				expected, err := redisLimiter.Check(ctx, "parity", config, limiter.WithCost(costs[i]))
+				require.NoError(t, err)
+				actual, err := memoryLimiter.Check(ctx, "parity", config, limiter.WithCost(costs[i]))
+				require.NoError(t, err)
+
+				assert.Equal(t, expected, actual, "Decisão divergente na requisição %d (custo %d)", i, costs[i])
+			}
+		})
+	}
+}
+
+func TestCost_UnsupportedByLegacyStorage(t *testing.T) {
+	rl := limiter.NewRateLimiter(newMockStorage())
+
+	result, err := rl.Check(context.Background(), "ip:1.2.3.4", limiter.LimitConfig{RPS: 10}, limiter.WithCost(1))
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+
+	_, err = rl.Check(context.Background(), "ip:1.2.3.4", limiter.LimitConfig{RPS: 10}, limiter.WithCost(5))
+	assert.ErrorIs(t, err, limiter.ErrUnsupportedCost)
+}
+
+func TestRateLimiterMiddleware_RouteAndPayloadCost(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{RateLimitIPRPS: 10}
+
+	newRouter := func(costFunc middleware.CostFunc) *gin.Engine {
+		strategy, _ := newMiniRedisStrategy(t)
+		rl := limiter.NewRateLimiter(strategy)
+		rateLimiterMiddleware := middleware.NewRateLimiterMiddleware(rl, cfg, middleware.WithCostFunc(costFunc))
+
+		router := gin.New()
+		router.Use(rateLimiterMiddleware.Middleware())
+		handler := func(c *gin.Context) { c.JSON(200, gin.H{"message": "ok"}) }
+		router.GET("/search", handler)
+		router.GET("/users/:id", handler)
+		router.POST("/users/:id", handler)
+		router.POST("/bulk", handler)
+		return router
+	}
+
+	t.Run("Custo por rota", func(t *testing.T) {
+		router := newRouter(middleware.RouteCosts(map[string]int{
+			"/search":         5,
+			"POST /users/:id": 3,
+		}))
+
+		w := performRequest(router, "GET", "/search", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "5", w.Header().Get("X-RateLimit-Remaining"))
+		assert.Equal(t, "5", w.Header().Get("X-RateLimit-Cost"))
+
+		// O template /users/:id casa com qualquer id; GET não tem custo definido
+		w = performRequest(router, "GET", "/users/42", "")
+		assert.Equal(t, "4", w.Header().Get("X-RateLimit-Remaining"))
+		assert.Empty(t, w.Header().Get("X-RateLimit-Cost"))
+
+		w = performRequest(router, "POST", "/users/42", "")
+		assert.Equal(t, "1", w.Header().Get("X-RateLimit-Remaining"))
+
+		w = performRequest(router, "GET", "/search", "")
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	})
+
+	t.Run("Custo por tamanho do corpo", func(t *testing.T) {
+		router := newRouter(middleware.PayloadCost(1024))
+
+		// 2500 bytes = 3 unidades de 1KB
+		w := performRequest(router, "POST", "/bulk", strings.Repeat("x", 2500))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "7", w.Header().Get("X-RateLimit-Remaining"))
+		assert.Equal(t, "3", w.Header().Get("X-RateLimit-Cost"))
+
+		w = performRequest(router, "POST", "/bulk", "")
+		assert.Equal(t, "6", w.Header().Get("X-RateLimit-Remaining"))
+	})
+}
+
+func performRequest(router *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, strings.NewReader(body))
+	req.ContentLength = int64(len(body))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
@@ -0,0 +1,238 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/config"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/limiter"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/middleware"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/rules"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/tokens"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newFailingRedis cria um Redis em memória que pode passar a falhar (mr.SetError)
+func newFailingRedis(t *testing.T) (*miniredis.Miniredis, *limiter.RedisStrategy) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+
+	return mr, limiter.NewRedisStrategy(rdb)
+}
+
+func TestParseFailureMode(t *testing.T) {
+	mode, err := limiter.ParseFailureMode("")
+	require.NoError(t, err)
+	assert.Equal(t, limiter.FailOpen, mode)
+
+	mode, err = limiter.ParseFailureMode(" Closed ")
+	require.NoError(t, err)
+	assert.Equal(t, limiter.FailClosed, mode)
+
+	_, err = limiter.ParseFailureMode("503")
+	assert.ErrorContains(t, err, `modo de falha inválido: "503"`)
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	mr, strategy := newFailingRedis(t)
+	clock := newFakeClock()
+	rl := limiter.NewRateLimiter(strategy,
+		limiter.WithClock(clock.Now),
+		limiter.WithCircuitBreaker(limiter.NewCircuitBreaker(2, 10*time.Second)),
+	)
+	ctx := context.Background()
+	config := limiter.LimitConfig{RPS: 10, Algorithm: limiter.GCRA}
+
+	check := func() error {
+		_, err := rl.Check(ctx, "ip:1.2.3.4", config)
+		return err
+	}
+
+	// Falhas seguidas abrem o circuito
+	mr.SetError("LOADING Redis is loading the dataset in memory")
+	for i := 0; i < 2; i++ {
+		err := check()
+		require.Error(t, err)
+		assert.NotErrorIs(t, err, limiter.ErrCircuitOpen)
+	}
+
+	// Aberto: o storage não é mais consultado
+	commands := mr.CommandCount()
+	assert.ErrorIs(t, check(), limiter.ErrCircuitOpen)
+	assert.Equal(t, commands, mr.CommandCount())
+
+	// Mesmo com o Redis de volta, só tenta de novo depois do cooldown
+	mr.SetError("")
+	clock.Advance(9 * time.Second)
+	assert.ErrorIs(t, check(), limiter.ErrCircuitOpen)
+
+	clock.Advance(time.Second)
+	assert.NoError(t, check(), "A avaliação de teste fecha o circuito")
+	assert.NoError(t, check())
+
+	// Uma falha isolada não abre
+	mr.SetError("ERR boom")
+	assert.NotErrorIs(t, check(), limiter.ErrCircuitOpen)
+	mr.SetError("")
+	assert.NoError(t, check())
+
+	// Falha na avaliação de teste abre de novo na hora
+	mr.SetError("ERR boom")
+	check()
+	check()
+	clock.Advance(10 * time.Second)
+	assert.NotErrorIs(t, check(), limiter.ErrCircuitOpen)
+	assert.ErrorIs(t, check(), limiter.ErrCircuitOpen)
+
+	// Erros de configuração não contam como falha do storage
+	memory := limiter.NewRateLimiter(newMockStorage(), limiter.WithCircuitBreaker(limiter.NewCircuitBreaker(1, time.Minute)))
+	_, err := memory.Check(ctx, "ip:1.2.3.4", config)
+	assert.ErrorIs(t, err, limiter.ErrUnsupportedAlgorithm)
+	_, err = memory.Check(ctx, "ip:1.2.3.4", limiter.LimitConfig{RPS: 10})
+	assert.NoError(t, err)
+}
+
+func TestRateLimiterMiddleware_FailureMode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	engine, err := rules.LoadFile(writeRulesFile(t, "rules.yaml", `
+rules:
+  - name: payments
+    path: /payments
+    on_error: closed
+  - name: health
+    path: /health
+    on_error: open
+`))
+	require.NoError(t, err)
+
+	for _, defaultMode := range []string{"open", "closed"} {
+		t.Run(defaultMode, func(t *testing.T) {
+			mr, strategy := newFailingRedis(t)
+			cfg := &config.Config{
+				RateLimitIPRPS:     10,
+				RateLimitTokenRPS:  100,
+				RateLimitAlgorithm: "fixed_window",
+				RateLimitOnError:   defaultMode,
+			}
+			rateLimiterMiddleware := middleware.NewRateLimiterMiddleware(limiter.NewRateLimiter(strategy), cfg, middleware.WithRules(engine))
+
+			router := gin.New()
+			router.Use(rateLimiterMiddleware.Middleware())
+			for _, path := range []string{"/test", "/payments", "/health"} {
+				router.GET(path, func(c *gin.Context) { c.JSON(200, gin.H{"message": "ok"}) })
+			}
+
+			request := func(path string) *httptest.ResponseRecorder {
+				req, _ := http.NewRequest("GET", path, nil)
+				req.RemoteAddr = "10.0.0.1:1000"
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+				return w
+			}
+
+			mr.SetError("ERR boom")
+
+			expected := http.StatusOK
+			if defaultMode == "closed" {
+				expected = http.StatusServiceUnavailable
+			}
+			assert.Equal(t, expected, request("/test").Code, "Sem regra vale RATE_LIMIT_ON_ERROR")
+
+			w := request("/payments")
+			assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+			assert.JSONEq(t, `{"error": "rate limiter unavailable"}`, w.Body.String())
+
+			assert.Equal(t, http.StatusOK, request("/health").Code)
+		})
+	}
+
+	_, err = rules.Parse([]byte("rules:\n  - name: a\n    path: /\n    on_error: fail\n"))
+	assert.ErrorContains(t, err, `regra 1 (a): on_error: modo de falha inválido: "fail"`)
+
+	rateLimiterMiddleware := middleware.NewRateLimiterMiddleware(limiter.NewRateLimiter(newMockStorage()), &config.Config{})
+	_, err = rateLimiterMiddleware.Reload(middleware.Limits{Config: &config.Config{RateLimitOnError: "fail"}})
+	assert.ErrorContains(t, err, "RATE_LIMIT_ON_ERROR")
+}
+
+func TestRateLimiterMiddleware_BreakerCoversAllStorageCalls(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	commands := &commandCounter{}
+	rdb.AddHook(commands)
+
+	// Um único breaker para o rate limiter, as leases, o cadastro e os planos
+	breaker := limiter.NewCircuitBreaker(1, time.Minute)
+	strategy := limiter.NewRedisStrategy(rdb)
+	rl := limiter.NewRateLimiter(strategy, limiter.WithCircuitBreaker(breaker))
+	concurrency := limiter.NewConcurrencyLimiter(strategy, 0, limiter.WithLeaseBreaker(breaker))
+	registry, err := tokens.Parse([]byte(plansYAML), tokens.WithRedisHash(rdb, "ratelimit:tokens"))
+	require.NoError(t, err)
+
+	newRouter := func(onError string, opts ...middleware.Option) *gin.Engine {
+		cfg := &config.Config{
+			RateLimitIPRPS:         10,
+			RateLimitTokenRPS:      100,
+			RateLimitIPConcurrency: 2,
+			RateLimitAlgorithm:     "fixed_window",
+			RateLimitOnError:       onError,
+		}
+		opts = append(opts, middleware.WithCircuitBreaker(breaker))
+		rateLimiterMiddleware := middleware.NewRateLimiterMiddleware(rl, cfg, opts...)
+
+		router := gin.New()
+		router.Use(rateLimiterMiddleware.Middleware())
+		router.GET("/test", func(c *gin.Context) { c.JSON(200, gin.H{"message": "ok"}) })
+		return router
+	}
+	request := func(router *gin.Engine, token string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/test", nil)
+		if token != "" {
+			req.Header.Set("API_KEY", token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	leases := newRouter("closed", middleware.WithConcurrencyLimiter(concurrency))
+	mr.SetError("ERR boom")
+
+	// A primeira falha (a lease, antes do limite) abre o circuito
+	assert.Equal(t, http.StatusServiceUnavailable, request(leases, "").Code)
+
+	cases := []struct {
+		name   string
+		router *gin.Engine
+		token  string
+	}{
+		{"concorrência", leases, ""},
+		{"cadastro de tokens", newRouter("closed", middleware.WithTokenStore(tokens.NewStore(rdb))), "rl_abc"},
+		{"planos", newRouter("closed", middleware.WithTokenRegistry(registry)), "sk_live_abc"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			before := commands.count.Load()
+			assert.Equal(t, http.StatusServiceUnavailable, request(tc.router, tc.token).Code)
+			assert.Equal(t, before, commands.count.Load(), "Com o circuito aberto o Redis não é consultado")
+		})
+	}
+
+	// Fail-open: o plano fica no limite padrão de token e a requisição passa
+	before := commands.count.Load()
+	assert.Equal(t, http.StatusOK, request(newRouter("open", middleware.WithTokenRegistry(registry)), "sk_live_abc").Code)
+	assert.Equal(t, before, commands.count.Load())
+}
@@ -0,0 +1,159 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/config"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/limiter"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/middleware"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/rules"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const globalRulesYAML = `
+identities:
+  tenant: { type: header, header: X-Tenant-ID }
+
+rules:
+  - name: backend
+    path: /test
+    global:
+      rps: 3
+      algorithm: sliding_window_counter
+      quotas: 1000/day
+
+  - name: reports
+    path: /reports
+    limits:
+      - { identity: tenant, rps: 10 }
+    global:
+      rps: 2
+`
+
+func TestRulesFile_GlobalLimit(t *testing.T) {
+	engine, err := rules.LoadFile(writeRulesFile(t, "rules.yaml", globalRulesYAML))
+	require.NoError(t, err)
+
+	rule, ok := engine.Match("GET", "/test")
+	require.True(t, ok)
+	require.NotNil(t, rule.Global)
+	assert.Equal(t, 3, rule.Global.RPS)
+	assert.Equal(t, limiter.SlidingWindowCounter, rule.Global.Algorithm)
+	assert.Equal(t, []limiter.Quota{{Limit: 1000, Period: limiter.QuotaDay}}, rule.Global.Quotas)
+	assert.Empty(t, rule.Limits, "Sem limits, o cliente continua no limite padrão")
+
+	rule, ok = engine.Match("GET", "/reports")
+	require.True(t, ok)
+	assert.Equal(t, limiter.FixedWindow, rule.Global.Algorithm)
+
+	cases := map[string]struct {
+		content  string
+		expected string
+	}{
+		"rps":       {"global: { rps: 0 }", "regra 1 (a): global: rps deve ser maior que zero"},
+		"bloqueio":  {"global: { rps: 5, block_time: 5m }", "field block_time not found"},
+		"algoritmo": {"global: { rps: 5, algorithm: random }", "regra 1 (a): global: algoritmo de rate limit desconhecido"},
+		"quotas":    {"global: { rps: 5, quotas: 10/week }", `regra 1 (a): global: quota inválido "10/week"`},
+	}
+	for name, tc := range cases {
+		_, err := rules.LoadFile(writeRulesFile(t, "rules.yaml", "rules:\n  - name: a\n    path: /\n    "+tc.content+"\n"))
+		assert.ErrorContains(t, err, tc.expected, name)
+	}
+
+	// Regras montadas em Go também não podem bloquear a chave global
+	_, err = rules.NewEngine([]rules.Rule{{
+		Name: "a", Path: "/", Global: &limiter.LimitConfig{RPS: 5, BlockTime: 1},
+	}})
+	assert.ErrorContains(t, err, "block_time e max_concurrent não se aplicam ao limite global")
+}
+
+func TestRateLimiterMiddleware_GlobalLimits(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	engine, err := rules.LoadFile(writeRulesFile(t, "rules.yaml", globalRulesYAML))
+	require.NoError(t, err)
+
+	// Duas instâncias do servidor compartilhando o mesmo Redis
+	strategy, rdb := newMiniRedisStrategy(t)
+	cfg := &config.Config{RateLimitIPRPS: 100, RateLimitTokenRPS: 100, RateLimitAlgorithm: "fixed_window"}
+
+	var routers []*gin.Engine
+	for _, s := range []limiter.StorageStrategy{strategy, limiter.NewRedisStrategy(rdb)} {
+		rateLimiterMiddleware := middleware.NewRateLimiterMiddleware(limiter.NewRateLimiter(s), cfg, middleware.WithRules(engine))
+		router := gin.New()
+		router.Use(rateLimiterMiddleware.Middleware())
+		for _, path := range []string{"/test", "/reports", "/other"} {
+			router.GET(path, func(c *gin.Context) { c.JSON(200, gin.H{"message": "ok"}) })
+		}
+		routers = append(routers, router)
+	}
+
+	request := func(instance int, path, remote string, headers map[string]string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", path, nil)
+		req.RemoteAddr = remote
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		w := httptest.NewRecorder()
+		routers[instance].ServeHTTP(w, req)
+		return w
+	}
+
+	// Cada cliente está longe do próprio limite, mas juntos esgotam o da rota
+	for i := 1; i <= 3; i++ {
+		assert.Equal(t, http.StatusOK, request(i%2, "/test", fmt.Sprintf("10.0.0.%d:1000", i), nil).Code)
+	}
+	w := request(0, "/test", "10.0.0.4:1000", map[string]string{"API_KEY": "token123"})
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "3", w.Header().Get("X-RateLimit-Limit"))
+
+	// Rotas sem bloco global não são afetadas
+	assert.Equal(t, http.StatusOK, request(1, "/other", "10.0.0.4:1000", nil).Code)
+
+	// O limite agregado vale mesmo quando a identidade da regra não está presente
+	assert.Equal(t, http.StatusOK, request(0, "/reports", "10.0.0.5:1000", nil).Code)
+	assert.Equal(t, http.StatusOK, request(1, "/reports", "10.0.0.6:1000", map[string]string{"X-Tenant-ID": "acme"}).Code)
+	assert.Equal(t, http.StatusTooManyRequests, request(0, "/reports", "10.0.0.7:1000", map[string]string{"X-Tenant-ID": "globex"}).Code)
+
+	// A negação pelo limite global não consome o limite do tenant
+	exists, err := rdb.Exists(context.Background(), "rate:{reports:tenant:globex}").Result()
+	require.NoError(t, err)
+	assert.Zero(t, exists)
+}
+
+func TestRateLimiterMiddleware_ServerGlobalLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	strategy, _ := newMiniRedisStrategy(t)
+	cfg := &config.Config{
+		RateLimitIPRPS:     100,
+		RateLimitTokenRPS:  100,
+		RateLimitGlobalRPS: 2,
+		RateLimitAlgorithm: "fixed_window",
+	}
+	rateLimiterMiddleware := middleware.NewRateLimiterMiddleware(limiter.NewRateLimiter(strategy), cfg)
+
+	router := gin.New()
+	router.Use(rateLimiterMiddleware.Middleware())
+	router.GET("/a", func(c *gin.Context) { c.JSON(200, gin.H{"message": "ok"}) })
+	router.GET("/b", func(c *gin.Context) { c.JSON(200, gin.H{"message": "ok"}) })
+
+	request := func(path, remote string) int {
+		req, _ := http.NewRequest("GET", path, nil)
+		req.RemoteAddr = remote
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// RATE_LIMIT_GLOBAL_RPS soma todas as rotas e clientes
+	assert.Equal(t, http.StatusOK, request("/a", "10.0.0.1:1000"))
+	assert.Equal(t, http.StatusOK, request("/b", "10.0.0.2:1000"))
+	assert.Equal(t, http.StatusTooManyRequests, request("/a", "10.0.0.3:1000"))
+}
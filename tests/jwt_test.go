@@ -0,0 +1,264 @@
+package tests
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/config"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/jwtauth"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/limiter"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/middleware"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/rules"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/tokens"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var hmacSecret = []byte("0123456789abcdef0123456789abcdef")
+
+// writeJWKS grava um JWKS com a chave RSA (kid rsa-1) e o segredo HS256 (kid hs-1)
+func writeJWKS(t *testing.T, key *rsa.PublicKey) string {
+	t.Helper()
+	b64 := base64.RawURLEncoding.EncodeToString
+	data, err := json.Marshal(map[string]any{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa-1", "alg": "RS256", "use": "sig", "n": b64(key.N.Bytes()), "e": b64(big.NewInt(int64(key.E)).Bytes())},
+		{"kty": "oct", "kid": "hs-1", "alg": "HS256", "k": b64(hmacSecret)},
+		{"kty": "RSA", "kid": "enc-1", "use": "enc"}, // Ignorada: não é de assinatura
+	}})
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+func signJWT(t *testing.T, method jwt.SigningMethod, kid string, key any, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
+
+func newRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	return key
+}
+
+func TestJWTVerifier(t *testing.T) {
+	rsaKey := newRSAKey(t)
+	keys, err := jwtauth.LoadJWKS(writeJWKS(t, &rsaKey.PublicKey))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"hs-1", "rsa-1"}, keys.IDs())
+
+	verifier := jwtauth.NewVerifier(keys, jwtauth.WithIssuer("https://auth.example.com"), jwtauth.WithAudience("api"))
+	exp := time.Now().Add(time.Hour).Unix()
+	valid := jwt.MapClaims{"sub": "user-1", "iss": "https://auth.example.com", "aud": "api", "exp": exp}
+
+	claims, err := verifier.Verify(signJWT(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, valid))
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", claims["sub"])
+
+	_, err = verifier.Verify(signJWT(t, jwt.SigningMethodHS256, "hs-1", hmacSecret, valid))
+	assert.NoError(t, err)
+
+	cases := map[string]string{
+		"expirado":         signJWT(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, jwt.MapClaims{"sub": "u", "iss": "https://auth.example.com", "aud": "api", "exp": time.Now().Add(-time.Minute).Unix()}),
+		"sem exp":          signJWT(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, jwt.MapClaims{"sub": "u", "iss": "https://auth.example.com", "aud": "api"}),
+		"issuer errado":    signJWT(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, jwt.MapClaims{"sub": "u", "iss": "https://evil.example.com", "aud": "api", "exp": exp}),
+		"audience errada":  signJWT(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, jwt.MapClaims{"sub": "u", "iss": "https://auth.example.com", "aud": "other", "exp": exp}),
+		"kid desconhecido": signJWT(t, jwt.SigningMethodRS256, "rsa-2", rsaKey, valid),
+		"sem kid":          signJWT(t, jwt.SigningMethodRS256, "", rsaKey, valid),
+		"chave errada":     signJWT(t, jwt.SigningMethodRS256, "rsa-1", newRSAKey(t), valid),
+		// Confusão de algoritmo: HS256 com o kid de uma chave RSA
+		"alg trocado": signJWT(t, jwt.SigningMethodHS256, "rsa-1", hmacSecret, valid),
+		"alg none":    signJWT(t, jwt.SigningMethodNone, "rsa-1", jwt.UnsafeAllowNoneSignatureType, valid),
+		"malformado":  "not.a.jwt",
+	}
+	for name, token := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := verifier.Verify(token)
+			assert.ErrorContains(t, err, "JWT inválido")
+		})
+	}
+}
+
+func TestJWTVerifier_SingleKeyWithoutKid(t *testing.T) {
+	keys, err := jwtauth.ParseJWKS([]byte(`{"keys": [{"kty": "oct", "k": "` + base64.RawURLEncoding.EncodeToString(hmacSecret) + `"}]}`))
+	require.NoError(t, err)
+
+	claims := jwt.MapClaims{"sub": "user-1", "exp": time.Now().Add(time.Hour).Unix()}
+	_, err = jwtauth.NewVerifier(keys).Verify(signJWT(t, jwt.SigningMethodHS256, "", hmacSecret, claims))
+	assert.NoError(t, err)
+}
+
+func TestParseJWKS_Errors(t *testing.T) {
+	_, err := jwtauth.ParseJWKS([]byte(`{"keys": [
+		{"kty": "RSA", "kid": "a", "n": "", "e": "AQAB"},
+		{"kty": "oct", "kid": "b", "k": "c2hvcnQ"},
+		{"kty": "EC", "kid": "c"},
+		{"kty": "RSA", "kid": "d", "alg": "RS512", "n": "AQAB", "e": "AQAB"}
+	]}`))
+	require.Error(t, err)
+	for _, expected := range []string{
+		"keys[0] (a): n inválido",
+		"keys[1] (b): k inválido (mínimo 32 bytes)",
+		`keys[2] (c): kty "EC" não suportado`,
+		`keys[3] (d): alg "RS512" não suportado para RSA`,
+	} {
+		assert.Contains(t, err.Error(), expected)
+	}
+
+	_, err = jwtauth.ParseJWKS([]byte(`{"keys": []}`))
+	assert.ErrorContains(t, err, "nenhuma chave de assinatura")
+
+	_, err = jwtauth.LoadJWKS(filepath.Join(t.TempDir(), "missing.json"))
+	assert.ErrorContains(t, err, "erro ao ler JWKS")
+}
+
+func TestJWTDiff(t *testing.T) {
+	rsaKey := newRSAKey(t)
+	keys, err := jwtauth.LoadJWKS(writeJWKS(t, &rsaKey.PublicKey))
+	require.NoError(t, err)
+	rotated, err := jwtauth.ParseJWKS([]byte(`{"keys": [{"kty": "oct", "kid": "hs-2", "k": "` + base64.RawURLEncoding.EncodeToString(hmacSecret) + `"}]}`))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"chave JWT hs-2 adicionada",
+		"chave JWT hs-1 removida",
+		"chave JWT rsa-1 removida",
+	}, jwtauth.Diff(jwtauth.NewVerifier(keys), jwtauth.NewVerifier(rotated)))
+	assert.Equal(t, []string{"chave JWT hs-2 adicionada"}, jwtauth.Diff(nil, jwtauth.NewVerifier(rotated)))
+}
+
+func TestRateLimiterMiddleware_JWTIdentity(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	rsaKey := newRSAKey(t)
+	keys, err := jwtauth.LoadJWKS(writeJWKS(t, &rsaKey.PublicKey))
+	require.NoError(t, err)
+	registry, err := tokens.Parse([]byte(plansYAML))
+	require.NoError(t, err)
+
+	strategy, rdb := newMiniRedisStrategy(t)
+	rl := limiter.NewRateLimiter(strategy)
+	cfg := &config.Config{
+		RateLimitIPRPS:        1,
+		RateLimitTokenRPS:     3,
+		RateLimitAlgorithm:    "fixed_window",
+		RateLimitJWTKeyClaim:  "tenant",
+		RateLimitJWTPlanClaim: "plan",
+	}
+	rateLimiterMiddleware := middleware.NewRateLimiterMiddleware(rl, cfg,
+		middleware.WithJWTVerifier(jwtauth.NewVerifier(keys)),
+		middleware.WithTokenRegistry(registry),
+	)
+
+	router := gin.New()
+	router.Use(rateLimiterMiddleware.Middleware())
+	router.GET("/", func(c *gin.Context) { c.JSON(200, gin.H{"message": "ok"}) })
+
+	request := func(remote, token string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.RemoteAddr = remote
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	exp := time.Now().Add(time.Hour).Unix()
+
+	// Mesmo tenant compartilha o limite de token, de qualquer IP
+	acme := signJWT(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, jwt.MapClaims{"sub": "u1", "tenant": "acme", "exp": exp})
+	acmeOther := signJWT(t, jwt.SigningMethodHS256, "hs-1", hmacSecret, jwt.MapClaims{"sub": "u2", "tenant": "acme", "exp": exp})
+	assert.Equal(t, "3", request("10.0.0.1:1000", acme).Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, http.StatusOK, request("10.0.0.2:1000", acmeOther).Code)
+	assert.Equal(t, http.StatusOK, request("10.0.0.3:1000", acme).Code)
+	assert.Equal(t, http.StatusTooManyRequests, request("10.0.0.4:1000", acmeOther).Code)
+
+	counters, err := rdb.Keys(context.Background(), "*{jwt:tenant:acme}*").Result()
+	require.NoError(t, err)
+	assert.NotEmpty(t, counters, "O contador usa a chave jwt:<claim>:<valor>")
+
+	// O claim plan escolhe o plano do arquivo de planos
+	globex := signJWT(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, jwt.MapClaims{"tenant": "globex", "plan": "pro", "exp": exp})
+	w := request("10.0.0.1:1000", globex)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "pro", w.Header().Get("X-RateLimit-Plan"))
+	assert.Equal(t, "100", w.Header().Get("X-RateLimit-Limit"))
+
+	// Plano desconhecido mantém o limite de token
+	initech := signJWT(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, jwt.MapClaims{"tenant": "initech", "plan": "platinum", "exp": exp})
+	w = request("10.0.0.1:1000", initech)
+	assert.Empty(t, w.Header().Get("X-RateLimit-Plan"))
+	assert.Equal(t, "3", w.Header().Get("X-RateLimit-Limit"))
+
+	// Token inválido, expirado ou sem o claim da chave cai no limite por IP
+	expired := signJWT(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, jwt.MapClaims{"tenant": "acme", "exp": time.Now().Add(-time.Minute).Unix()})
+	noTenant := signJWT(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, jwt.MapClaims{"sub": "u1", "exp": exp})
+	for i, token := range []string{"", "garbage", expired, noTenant} {
+		remote := fmt.Sprintf("192.0.2.%d:1000", i+1)
+		w = request(remote, token)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "1", w.Header().Get("X-RateLimit-Limit"))
+		assert.Equal(t, http.StatusTooManyRequests, request(remote, token).Code)
+	}
+}
+
+func TestRateLimiterMiddleware_JWTClaimsFeedRules(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	keys, err := jwtauth.ParseJWKS([]byte(`{"keys": [{"kty": "oct", "kid": "hs-1", "k": "` + base64.RawURLEncoding.EncodeToString(hmacSecret) + `"}]}`))
+	require.NoError(t, err)
+	engine, err := rules.Parse([]byte(rulesYAML))
+	require.NoError(t, err)
+
+	strategy, _ := newMiniRedisStrategy(t)
+	rl := limiter.NewRateLimiter(strategy)
+	rateLimiterMiddleware := middleware.NewRateLimiterMiddleware(rl,
+		&config.Config{RateLimitIPRPS: 10, RateLimitTokenRPS: 10, RateLimitJWTKeyClaim: "sub"},
+		middleware.WithJWTVerifier(jwtauth.NewVerifier(keys)),
+		middleware.WithRules(engine),
+	)
+
+	router := gin.New()
+	router.Use(rateLimiterMiddleware.Middleware())
+	router.GET("/account/*rest", func(c *gin.Context) { c.JSON(200, gin.H{"message": "ok"}) })
+
+	request := func(user string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/account/me", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		token := signJWT(t, jwt.SigningMethodHS256, "hs-1", hmacSecret, jwt.MapClaims{"sub": user, "exp": time.Now().Add(time.Hour).Unix()})
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// A identidade claim das regras usa os claims do JWT verificado
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, request("alice").Code)
+	}
+	assert.Equal(t, http.StatusTooManyRequests, request("alice").Code)
+	assert.Equal(t, http.StatusOK, request("bob").Code)
+}
@@ -0,0 +1,189 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/config"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/limiter"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/middleware"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/rules"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/tokens"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyExtractors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	extractors := map[string]middleware.KeyExtractor{
+		"header":    middleware.HeaderKey("X-Tenant-ID"),
+		"query":     middleware.QueryKey("project"),
+		"cookie":    middleware.CookieKey("session"),
+		"param":     middleware.PathParamKey("id"),
+		"route":     middleware.RouteKey(),
+		"claim":     middleware.ClaimKey("sub"),
+		"composite": middleware.CompositeKey(middleware.HeaderKey("X-Tenant-ID"), middleware.RouteKey()),
+		"missing":   middleware.CompositeKey(middleware.HeaderKey("X-Tenant-ID"), middleware.QueryKey("missing")),
+	}
+
+	got := make(map[string]string)
+	router := gin.New()
+	router.GET("/users/:id", func(c *gin.Context) {
+		c.Set(middleware.ClaimsKey, map[string]any{"sub": "alice"})
+		for name, extractor := range extractors {
+			if value, ok := extractor.ExtractKey(c); ok {
+				got[name] = value
+			}
+		}
+	})
+
+	req, _ := http.NewRequest("GET", "/users/42?project=apollo", nil)
+	req.Header.Set("X-Tenant-ID", "acme:corp")
+	req.AddCookie(&http.Cookie{Name: "session", Value: "s-1"})
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, map[string]string{
+		"header": "acme:corp",
+		"query":  "apollo",
+		"cookie": "s-1",
+		"param":  "42",
+		"route":  "/users/:id",
+		"claim":  "alice",
+		// ":" dos valores é escapado: "a:b" + "c" ≠ "a" + "b:c"
+		"composite": "acme%3Acorp:/users/%3Aid",
+	}, got)
+}
+
+func TestParseKeyExtractor(t *testing.T) {
+	extractor, err := middleware.ParseKeyExtractor("")
+	require.NoError(t, err)
+	assert.Nil(t, extractor)
+
+	for _, spec := range []string{"ip", "Route", "header:X-Tenant-ID,route", "query:project, cookie:session, param:id, claim:sub"} {
+		extractor, err := middleware.ParseKeyExtractor(spec)
+		require.NoError(t, err, spec)
+		assert.NotNil(t, extractor, spec)
+	}
+
+	_, err = middleware.ParseKeyExtractor("header")
+	assert.EqualError(t, err, `RATE_LIMIT_KEY: "header" requer um nome (ex: header:X-Tenant-ID)`)
+	_, err = middleware.ParseKeyExtractor("route,body:tenant")
+	assert.ErrorContains(t, err, `RATE_LIMIT_KEY: extrator inválido "body:tenant"`)
+}
+
+func TestRateLimiterMiddleware_KeyExtractor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// Mesmo extrator montado pelo servidor a partir de RATE_LIMIT_KEY
+	extractor, err := middleware.ParseKeyExtractor("header:X-Tenant-ID, route")
+	require.NoError(t, err)
+
+	strategy, rdb := newMiniRedisStrategy(t)
+	rl := limiter.NewRateLimiter(strategy)
+	rateLimiterMiddleware := middleware.NewRateLimiterMiddleware(rl,
+		&config.Config{RateLimitIPRPS: 2, RateLimitTokenRPS: 10, RateLimitAlgorithm: "fixed_window"},
+		middleware.WithKeyExtractor(extractor),
+		middleware.WithTokenHasher(tokens.NewHasher("secret")),
+	)
+
+	router := gin.New()
+	router.Use(rateLimiterMiddleware.Middleware())
+	handler := func(c *gin.Context) { c.JSON(200, gin.H{"message": "ok"}) }
+	router.GET("/a", handler)
+	router.GET("/b", handler)
+
+	request := func(path, remote, tenant string) int {
+		req, _ := http.NewRequest("GET", path, nil)
+		req.RemoteAddr = remote
+		if tenant != "" {
+			req.Header.Set("X-Tenant-ID", tenant)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// O tenant divide o limite entre IPs, separado por rota
+	assert.Equal(t, http.StatusOK, request("/a", "10.0.0.1:1000", "acme"))
+	assert.Equal(t, http.StatusOK, request("/a", "10.0.0.2:1000", "acme"))
+	assert.Equal(t, http.StatusTooManyRequests, request("/a", "10.0.0.3:1000", "acme"))
+	assert.Equal(t, http.StatusOK, request("/b", "10.0.0.3:1000", "acme"))
+	assert.Equal(t, http.StatusOK, request("/a", "10.0.0.3:1000", "globex"))
+
+	// Os valores extraídos vão para o Redis como HMAC, como as identidades secret
+	keys, err := rdb.Keys(context.Background(), "*").Result()
+	require.NoError(t, err)
+	for _, key := range keys {
+		assert.NotContains(t, key, "acme")
+		assert.NotContains(t, key, "globex")
+	}
+
+	// Sem o header, vale a chave padrão (por IP)
+	assert.Equal(t, http.StatusOK, request("/a", "10.0.0.3:1000", ""))
+	assert.Equal(t, http.StatusOK, request("/a", "10.0.0.3:1000", ""))
+	assert.Equal(t, http.StatusTooManyRequests, request("/a", "10.0.0.3:1000", ""))
+}
+
+func TestRateLimiterMiddleware_RuleExtractorIdentities(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	engine, err := rules.Parse([]byte(`
+identities:
+  project: { type: param, param: id }
+  region:  { type: query, query: region }
+  session: { type: cookie, cookie: session }
+rules:
+  - name: projects
+    path: /projects/:id
+    limits:
+      - { identity: project, rps: 1 }
+  - name: reports
+    path: /reports
+    limits:
+      - { identity: region, rps: 1 }
+      - { identity: session, rps: 2 }
+`))
+	require.NoError(t, err)
+
+	strategy, _ := newMiniRedisStrategy(t)
+	rl := limiter.NewRateLimiter(strategy)
+	rateLimiterMiddleware := middleware.NewRateLimiterMiddleware(rl, &config.Config{RateLimitIPRPS: 100}, middleware.WithRules(engine))
+
+	router := gin.New()
+	router.Use(rateLimiterMiddleware.Middleware())
+	handler := func(c *gin.Context) { c.JSON(200, gin.H{"message": "ok"}) }
+	router.GET("/projects/:id", handler)
+	router.GET("/reports", handler)
+
+	request := func(path, session string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", path, nil)
+		req.RemoteAddr = "10.0.0.1:1000"
+		if session != "" {
+			req.AddCookie(&http.Cookie{Name: "session", Value: session})
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Cada projeto tem seu contador
+	assert.Equal(t, http.StatusOK, request("/projects/1", "").Code)
+	assert.Equal(t, http.StatusTooManyRequests, request("/projects/1", "").Code)
+	assert.Equal(t, http.StatusOK, request("/projects/2", "").Code)
+
+	// Query tem precedência sobre o cookie; sem nenhum, vale o limite padrão de IP
+	assert.Equal(t, http.StatusOK, request("/reports?region=eu", "s-1").Code)
+	assert.Equal(t, http.StatusTooManyRequests, request("/reports?region=eu", "s-1").Code)
+	assert.Equal(t, "2", request("/reports", "s-1").Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "100", request("/reports", "").Header().Get("X-RateLimit-Limit"))
+
+	_, err = rules.Parse([]byte(`
+identities:
+  region: { type: query }
+`))
+	assert.ErrorContains(t, err, `identidade "region": query é obrigatório para o tipo query`)
+}
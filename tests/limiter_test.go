@@ -2,6 +2,7 @@ package tests
 
 import (
 	"context"
+	"sync"
 	"testing"
 	"time"
 
@@ -13,7 +14,9 @@ import (
 )
 
 // Mock strategy para testes unitários (sem Redis)
+// O mutex permite uso em testes concorrentes (go test -race)
 type mockStorage struct {
+	mu      sync.Mutex
 	data    map[string]int
 	blocked map[string]bool
 	ttls    map[string]time.Time
@@ -28,6 +31,9 @@ func newMockStorage() *mockStorage {
 }
 
 func (m *mockStorage) Get(ctx context.Context, key string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	// Verifica se TTL expirou
 	if ttl, exists := m.ttls[key]; exists && time.Now().After(ttl) {
 		delete(m.data, key)
@@ -42,12 +48,18 @@ func (m *mockStorage) Get(ctx context.Context, key string) (int, error) {
 }
 
 func (m *mockStorage) Set(ctx context.Context, key string, tokens int, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	m.data[key] = tokens
 	m.ttls[key] = time.Now().Add(ttl)
 	return nil
 }
 
 func (m *mockStorage) Increment(ctx context.Context, key string, ttl time.Duration) (int, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	existed := false
 	if _, exists := m.data[key]; exists {
 		existed = true
@@ -60,14 +72,22 @@ func (m *mockStorage) Increment(ctx context.Context, key string, ttl time.Durati
 }
 
 func (m *mockStorage) IsBlocked(ctx context.Context, key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	return m.blocked[key], nil
 }
 
 func (m *mockStorage) Block(ctx context.Context, key string, blockTime time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	m.blocked[key] = true
 
 	// Remove bloqueio após blockTime (simulação)
 	time.AfterFunc(blockTime, func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
 		delete(m.blocked, key)
 	})
 
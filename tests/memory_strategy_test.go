@@ -0,0 +1,159 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/limiter"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// O storage em memória deve tomar exatamente as mesmas decisões que os scripts Lua
+func TestMemoryStrategy_MatchesRedisDecisions(t *testing.T) {
+	algorithms := []limiter.Algorithm{
+		limiter.FixedWindow,
+		limiter.TokenBucket,
+		limiter.SlidingWindowLog,
+		limiter.SlidingWindowCounter,
+		limiter.GCRA,
+		limiter.LeakyBucket,
+	}
+
+	// Intervalos irregulares entre requisições para cobrir reposição e viradas de janela
+	steps := []time.Duration{0, 0, 0, 0, 30, 70, 0, 120, 250, 0, 0, 480, 10, 900, 0, 0, 0, 1100}
+
+	for _, algorithm := range algorithms {
+		t.Run(string(algorithm), func(t *testing.T) {
+			ctx := context.Background()
+			clock := newFakeClock()
+
+			// O relógio do miniredis avança junto, pois a janela fixa usa o TTL do Redis
+			mr := miniredis.RunT(t)
+			rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+			defer rdb.Close()
+
+			redisStrategy := limiter.NewRedisStrategy(rdb)
+			memoryStrategy := limiter.NewMemoryStrategy(4, time.Hour)
+			defer memoryStrategy.Close()
+
+			redisLimiter := limiter.NewRateLimiter(redisStrategy, limiter.WithClock(clock.Now))
+			memoryLimiter := limiter.NewRateLimiter(memoryStrategy, limiter.WithClock(clock.Now))
+
+			config := limiter.LimitConfig{
+				RPS:       3,
+				Burst:     4,
+				MaxWait:   500 * time.Millisecond,
+				BlockTime: 200 * time.Millisecond,
+				Algorithm: algorithm,
+			}
+
+			for i, step := range steps {
+				clock.Advance(step * time.Millisecond)
+				mr.FastForward(step * time.Millisecond)
+
+				expected, err := redisLimiter.Check(ctx, "parity", config)
+				require.NoError(t, err)
+				actual, err := memoryLimiter.Check(ctx, "parity", config)
+				require.NoError(t, err)
+
+				assert.Equal(t, expected, actual, "Decisão divergente na requisição %d", i)
+			}
+		})
+	}
+}
+
+func TestMemoryStrategy_ConcurrentKeys(t *testing.T) {
+	strategy := limiter.NewMemoryStrategy(8, time.Hour)
+	defer strategy.Close()
+
+	clock := newFakeClock()
+	rl := limiter.NewRateLimiter(strategy, limiter.WithClock(clock.Now))
+
+	config := limiter.LimitConfig{RPS: 5, BlockTime: time.Minute, Algorithm: limiter.TokenBucket}
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	allowed := make(map[string]int)
+	var wg sync.WaitGroup
+
+	// 10 chaves x 20 goroutines cada
+	for k := 0; k < 10; k++ {
+		key := fmt.Sprintf("key-%d", k)
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				result, err := rl.Check(ctx, key, config)
+				if !assert.NoError(t, err) {
+					return
+				}
+				if result.Allowed {
+					mu.Lock()
+					allowed[key]++
+					mu.Unlock()
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	for k := 0; k < 10; k++ {
+		assert.Equal(t, 5, allowed[fmt.Sprintf("key-%d", k)])
+	}
+}
+
+func TestMemoryStrategy_TTLAndJanitor(t *testing.T) {
+	strategy := limiter.NewMemoryStrategy(2, 10*time.Millisecond)
+	defer strategy.Close()
+
+	ctx := context.Background()
+
+	require.NoError(t, strategy.Set(ctx, "short", 7, 30*time.Millisecond))
+	require.NoError(t, strategy.Set(ctx, "forever", 1, 0))
+	require.NoError(t, strategy.Block(ctx, "blocked", 30*time.Millisecond))
+
+	val, err := strategy.Get(ctx, "short")
+	require.NoError(t, err)
+	assert.Equal(t, 7, val)
+
+	blocked, err := strategy.IsBlocked(ctx, "blocked")
+	require.NoError(t, err)
+	assert.True(t, blocked)
+
+	// O janitor remove as entradas vencidas mesmo sem novas leituras
+	assert.Eventually(t, func() bool {
+		return strategy.Len() == 1
+	}, time.Second, 5*time.Millisecond, "Só a entrada sem TTL deveria restar")
+
+	blocked, err = strategy.IsBlocked(ctx, "blocked")
+	require.NoError(t, err)
+	assert.False(t, blocked)
+
+	val, err = strategy.Get(ctx, "forever")
+	require.NoError(t, err)
+	assert.Equal(t, 1, val)
+}
+
+func TestMemoryStrategy_Increment(t *testing.T) {
+	strategy := limiter.NewMemoryStrategy(0, 0)
+	defer strategy.Close()
+
+	ctx := context.Background()
+
+	count, existed, err := strategy.Increment(ctx, "counter", time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	assert.False(t, existed)
+
+	count, existed, err = strategy.Increment(ctx, "counter", time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.True(t, existed)
+}
@@ -3,14 +3,17 @@ package tests
 import (
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/config"
 	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/limiter"
 	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/middleware"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/rules"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestRateLimiterMiddleware(t *testing.T) {
@@ -109,3 +112,180 @@ func TestRateLimiterMiddleware(t *testing.T) {
 		assert.Equal(t, "4", w.Header().Get("X-RateLimit-Remaining"))
 	})
 }
+
+func TestRateLimiterMiddleware_LeakyBucketDelaysInsteadOf429(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{
+		RateLimitIPRPS:       20, // Libera uma requisição a cada 50ms
+		RateLimitIPBlockTime: 0,
+		RateLimitAlgorithm:   string(limiter.LeakyBucket),
+		RateLimitMaxWait:     100 * time.Millisecond,
+	}
+
+	// Relógio parado: todas as requisições chegam no mesmo instante e os atrasos
+	// são exatos, sem depender de quanto tempo o teste leva entre elas
+	clock := newFakeClock()
+	strategy, _ := newMiniRedisStrategy(t)
+	rl := limiter.NewRateLimiter(strategy, limiter.WithClock(clock.Now))
+	rateLimiterMiddleware := middleware.NewRateLimiterMiddleware(rl, cfg)
+
+	router := gin.New()
+	router.Use(rateLimiterMiddleware.Middleware())
+	router.GET("/test", func(c *gin.Context) {
+		c.JSON(200, gin.H{"message": "ok"})
+	})
+
+	// 4 requisições simultâneas: atrasos de 0, 50 e 100ms; a 4ª (150ms) excede o MaxWait
+	var wg sync.WaitGroup
+	codes := make(chan int, 4)
+	start := time.Now()
+
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, _ := http.NewRequest("GET", "/test", nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			codes <- w.Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+	elapsed := time.Since(start)
+
+	ok, tooMany := 0, 0
+	for code := range codes {
+		switch code {
+		case http.StatusOK:
+			ok++
+		case http.StatusTooManyRequests:
+			tooMany++
+		}
+	}
+
+	assert.Equal(t, 3, ok, "Requisições dentro do MaxWait deveriam aguardar e passar")
+	assert.Equal(t, 1, tooMany, "Requisição além do MaxWait deveria receber 429")
+	assert.GreaterOrEqual(t, elapsed, 90*time.Millisecond, "A fila deveria segurar as requisições")
+}
+
+func TestRateLimiterMiddleware_Queue(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	engine, err := rules.Parse([]byte(`
+rules:
+  - name: batch
+    path: /batch
+    queue: true
+`))
+	require.NoError(t, err)
+
+	newRouter := func(cfg *config.Config) *gin.Engine {
+		strategy, _ := newMiniRedisStrategy(t)
+		rateLimiterMiddleware := middleware.NewRateLimiterMiddleware(limiter.NewRateLimiter(strategy), cfg, middleware.WithRules(engine))
+
+		router := gin.New()
+		router.Use(rateLimiterMiddleware.Middleware())
+		handler := func(c *gin.Context) { c.JSON(200, gin.H{"message": "ok"}) }
+		router.GET("/test", handler)
+		router.GET("/batch", handler)
+		return router
+	}
+
+	cfg := &config.Config{
+		RateLimitIPRPS:       20, // Uma requisição a cada 50ms
+		RateLimitIPBurst:     1,
+		RateLimitIPBlockTime: 300 * time.Second,
+		RateLimitAlgorithm:   string(limiter.GCRA),
+		RateLimitMaxWait:     200 * time.Millisecond,
+	}
+
+	// Com queue na regra, o excesso aguarda a reposição em vez de receber 429
+	router := newRouter(cfg)
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, performRequest(router, "GET", "/batch", "").Code, "Requisição %d deveria aguardar a vez", i)
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond, "A fila deveria segurar as requisições")
+
+	// Sem fila, o mesmo algoritmo responde 429 com o bloqueio padrão
+	assert.Equal(t, http.StatusOK, performRequest(router, "GET", "/test", "").Code)
+	w := performRequest(router, "GET", "/test", "")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "300", w.Header().Get("Retry-After"))
+
+	// RATE_LIMIT_QUEUE ativa a fila em todas as rotas; quem passa do
+	// RATE_LIMIT_MAX_WAIT recebe 429, mas sem o bloqueio
+	queued := *cfg
+	queued.RateLimitQueue = true
+	queued.RateLimitMaxWait = 10 * time.Millisecond // Menor que a reposição (50ms)
+	router = newRouter(&queued)
+
+	assert.Equal(t, http.StatusOK, performRequest(router, "GET", "/test", "").Code)
+	w = performRequest(router, "GET", "/test", "")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+
+	time.Sleep(60 * time.Millisecond)
+	assert.Equal(t, http.StatusOK, performRequest(router, "GET", "/test", "").Code)
+
+	// Os limites compostos também não bloqueiam: o token passa pelo limite de IP
+	composite := queued
+	composite.RateLimitTokenRPS = 100
+	composite.RateLimitComposite = "ip"
+	router = newRouter(&composite)
+
+	tokenRequest := func() *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/test", nil)
+		req.Header.Set("API_KEY", "batch-token")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	assert.Equal(t, http.StatusOK, tokenRequest().Code)
+	w = tokenRequest()
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"), "Sem os 300s de RATE_LIMIT_IP_BLOCK_TIME")
+
+	time.Sleep(60 * time.Millisecond)
+	assert.Equal(t, http.StatusOK, tokenRequest().Code)
+}
+
+func TestRateLimiterMiddleware_TokenBucketIgnoresDefaultBlockTime(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{
+		RateLimitIPRPS:       10, // Um token a cada 100ms
+		RateLimitIPBurst:     2,
+		RateLimitIPBlockTime: 300 * time.Second,
+		RateLimitAlgorithm:   string(limiter.TokenBucket),
+	}
+
+	strategy, _ := newMiniRedisStrategy(t)
+	rateLimiterMiddleware := middleware.NewRateLimiterMiddleware(limiter.NewRateLimiter(strategy), cfg)
+
+	router := gin.New()
+	router.Use(rateLimiterMiddleware.Middleware())
+	router.GET("/test", func(c *gin.Context) {
+		c.JSON(200, gin.H{"message": "ok"})
+	})
+
+	request := func() *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/test", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, request().Code)
+	assert.Equal(t, http.StatusOK, request().Code)
+
+	// Além da rajada: 429 até a reposição, não os 300s de RATE_LIMIT_IP_BLOCK_TIME
+	w := request()
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+
+	time.Sleep(150 * time.Millisecond)
+	assert.Equal(t, http.StatusOK, request().Code, "A reposição libera a próxima requisição")
+}
@@ -0,0 +1,208 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/limiter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// algorithmStorages retorna os storages atômicos, que devem decidir igual
+func algorithmStorages(t *testing.T) map[string]limiter.StorageStrategy {
+	redisStrategy, _ := newMiniRedisStrategy(t)
+	memoryStrategy := limiter.NewMemoryStrategy(4, time.Hour)
+	t.Cleanup(func() { memoryStrategy.Close() })
+
+	return map[string]limiter.StorageStrategy{
+		"redis":  redisStrategy,
+		"memory": memoryStrategy,
+	}
+}
+
+func TestParseQuotas(t *testing.T) {
+	quotas, err := limiter.ParseQuotas(" 100000/day, 5000/HOUR ,")
+	require.NoError(t, err)
+	assert.Equal(t, []limiter.Quota{
+		{Limit: 100000, Period: limiter.QuotaDay},
+		{Limit: 5000, Period: limiter.QuotaHour},
+	}, quotas)
+
+	quotas, err = limiter.ParseQuotas("")
+	require.NoError(t, err)
+	assert.Empty(t, quotas)
+
+	for _, invalid := range []string{"100", "abc/day", "0/day", "10/week", "10/day,20/day"} {
+		_, err := limiter.ParseQuotas(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestQuota_ExhaustedUntilCalendarBoundary(t *testing.T) {
+	for name, storage := range algorithmStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			clock := newFakeClock()
+			clock.Advance(40 * time.Second) // 12:00:40 UTC
+			rl := limiter.NewRateLimiter(storage, limiter.WithClock(clock.Now))
+			ctx := context.Background()
+
+			config := limiter.LimitConfig{
+				RPS:       10,
+				BlockTime: time.Minute,
+				Algorithm: limiter.TokenBucket,
+				Quotas:    []limiter.Quota{{Limit: 5, Period: limiter.QuotaMinute}},
+			}
+
+			for i := 0; i < 5; i++ {
+				result, err := rl.Check(ctx, "token:plan", config)
+				require.NoError(t, err)
+				assert.True(t, result.Allowed, "Requisição %d dentro do quota", i+1)
+				clock.Advance(time.Second)
+			}
+
+			// 12:00:45: quota do minuto esgotado, RPS ainda livre
+			result, err := rl.Check(ctx, "token:plan", config)
+			require.NoError(t, err)
+			assert.False(t, result.Allowed)
+			assert.True(t, result.QuotaExceeded)
+			assert.False(t, result.Blocked)
+			assert.Equal(t, 15*time.Second, result.RetryAfter, "Quota volta na virada do minuto, não após o BlockTime")
+			assert.Equal(t, 5, result.Limit)
+			assert.Equal(t, 0, result.Remaining)
+
+			// Quota esgotado não aplica o bloqueio do RPS
+			blocked, err := storage.IsBlocked(ctx, "token:plan")
+			require.NoError(t, err)
+			assert.False(t, blocked)
+
+			// 12:01:00: nova janela
+			clock.Advance(15 * time.Second)
+			result, err = rl.Check(ctx, "token:plan", config)
+			require.NoError(t, err)
+			assert.True(t, result.Allowed)
+		})
+	}
+}
+
+func TestQuota_HeadersReportMostRestrictiveWindow(t *testing.T) {
+	for name, storage := range algorithmStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			clock := newFakeClock()
+			rl := limiter.NewRateLimiter(storage, limiter.WithClock(clock.Now))
+			ctx := context.Background()
+
+			config := limiter.LimitConfig{
+				RPS: 10,
+				Quotas: []limiter.Quota{
+					{Limit: 1000, Period: limiter.QuotaDay},
+					{Limit: 3, Period: limiter.QuotaHour},
+				},
+			}
+
+			// O quota por hora (3) é mais restritivo que o RPS (10) e o diário
+			result, err := rl.Check(ctx, "token:plan", config)
+			require.NoError(t, err)
+			assert.Equal(t, 3, result.Limit)
+			assert.Equal(t, 2, result.Remaining)
+			assert.Equal(t, time.Date(2025, 1, 1, 13, 0, 0, 0, time.UTC), result.ResetTime)
+
+			// Sem quotas apertados, o RPS é o mais restritivo
+			config.Quotas = []limiter.Quota{{Limit: 1000, Period: limiter.QuotaDay}}
+			result, err = rl.Check(ctx, "token:other", config)
+			require.NoError(t, err)
+			assert.Equal(t, 10, result.Limit)
+			assert.Equal(t, 9, result.Remaining)
+		})
+	}
+}
+
+func TestQuota_AlignedToConfiguredTimezone(t *testing.T) {
+	saoPaulo, err := time.LoadLocation("America/Sao_Paulo")
+	require.NoError(t, err)
+
+	for name, storage := range algorithmStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			clock := newFakeClock() // 2025-01-01 12:00 UTC = 09:00 em São Paulo
+			rl := limiter.NewRateLimiter(storage,
+				limiter.WithClock(clock.Now),
+				limiter.WithQuotaLocation(saoPaulo))
+			ctx := context.Background()
+
+			config := limiter.LimitConfig{
+				RPS:    100,
+				Quotas: []limiter.Quota{{Limit: 1, Period: limiter.QuotaDay}},
+			}
+
+			result, err := rl.Check(ctx, "token:br", config)
+			require.NoError(t, err)
+			assert.True(t, result.Allowed)
+			assert.True(t, result.ResetTime.Equal(time.Date(2025, 1, 2, 0, 0, 0, 0, saoPaulo)),
+				"Quota diário deveria virar à meia-noite de São Paulo, não UTC: %s", result.ResetTime)
+
+			// 23:59 em São Paulo (02:59 UTC do dia seguinte): mesmo dia local
+			clock.Advance(14*time.Hour + 59*time.Minute)
+			result, err = rl.Check(ctx, "token:br", config)
+			require.NoError(t, err)
+			assert.False(t, result.Allowed)
+			assert.Equal(t, time.Minute, result.RetryAfter)
+
+			clock.Advance(time.Minute)
+			result, err = rl.Check(ctx, "token:br", config)
+			require.NoError(t, err)
+			assert.True(t, result.Allowed)
+
+			// Quota mensal vira no primeiro dia do mês seguinte
+			config.Quotas = []limiter.Quota{{Limit: 10, Period: limiter.QuotaMonth}}
+			result, err = rl.Check(ctx, "token:monthly", config)
+			require.NoError(t, err)
+			assert.True(t, result.ResetTime.Equal(time.Date(2025, 2, 1, 0, 0, 0, 0, saoPaulo)))
+		})
+	}
+}
+
+func TestQuota_NotConsumedWhenRateLimitDenies(t *testing.T) {
+	for name, storage := range algorithmStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			clock := newFakeClock()
+			rl := limiter.NewRateLimiter(storage, limiter.WithClock(clock.Now))
+			ctx := context.Background()
+
+			config := limiter.LimitConfig{
+				RPS:       2,
+				Algorithm: limiter.TokenBucket,
+				Quotas:    []limiter.Quota{{Limit: 3, Period: limiter.QuotaMinute}},
+			}
+
+			// 3ª requisição no mesmo segundo é negada pelo RPS, sem gastar quota
+			for i, expected := range []bool{true, true, false} {
+				result, err := rl.Check(ctx, "token:plan", config)
+				require.NoError(t, err)
+				assert.Equal(t, expected, result.Allowed, "Requisição %d", i+1)
+				assert.False(t, result.QuotaExceeded)
+			}
+
+			clock.Advance(time.Second)
+			result, err := rl.Check(ctx, "token:plan", config)
+			require.NoError(t, err)
+			assert.True(t, result.Allowed, "A requisição negada pelo RPS não deveria ter consumido quota")
+			assert.Equal(t, 0, result.Remaining)
+
+			result, err = rl.Check(ctx, "token:plan", config)
+			require.NoError(t, err)
+			assert.False(t, result.Allowed)
+			assert.True(t, result.QuotaExceeded)
+		})
+	}
+}
+
+func TestQuota_UnsupportedByLegacyStorage(t *testing.T) {
+	rl := limiter.NewRateLimiter(newMockStorage())
+
+	_, err := rl.Check(context.Background(), "token:plan", limiter.LimitConfig{
+		RPS:    10,
+		Quotas: []limiter.Quota{{Limit: 100, Period: limiter.QuotaDay}},
+	})
+	assert.ErrorIs(t, err, limiter.ErrUnsupportedQuotas)
+}
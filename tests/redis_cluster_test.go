@@ -0,0 +1,157 @@
+package tests
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/config"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/limiter"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/storage"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// clusterSlot calcula o slot do Redis Cluster (CRC16 XMODEM mod 16384),
+// respeitando hash tags {...}
+func clusterSlot(key string) uint16 {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+
+	var crc uint16
+	for i := 0; i < len(key); i++ {
+		crc ^= uint16(key[i]) << 8
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc % 16384
+}
+
+func TestRedisStrategy_KeysOfSameIdentityShareSlot(t *testing.T) {
+	strategy, rdb := newMiniRedisStrategy(t)
+	clock := newFakeClock()
+	rl := limiter.NewRateLimiter(strategy, limiter.WithClock(clock.Now))
+	ctx := context.Background()
+
+	algorithms := []limiter.Algorithm{
+		limiter.FixedWindow,
+		limiter.TokenBucket,
+		limiter.SlidingWindowLog,
+		limiter.SlidingWindowCounter,
+		limiter.GCRA,
+		limiter.LeakyBucket,
+	}
+
+	// Excede o limite para que a chave block: também seja criada
+	for _, algorithm := range algorithms {
+		config := limiter.LimitConfig{RPS: 1, BlockTime: time.Minute, Algorithm: algorithm}
+		for i := 0; i < 3; i++ {
+			_, err := rl.Check(ctx, "token:abc-"+string(algorithm), config)
+			require.NoError(t, err)
+		}
+	}
+
+	keys, err := rdb.Keys(ctx, "*").Result()
+	require.NoError(t, err)
+
+	slots := make(map[string]map[uint16]bool)
+	for _, key := range keys {
+		start, end := strings.IndexByte(key, '{'), strings.IndexByte(key, '}')
+		require.True(t, start >= 0 && end > start, "Chave sem hash tag: %s", key)
+
+		identity := key[start+1 : end]
+		if slots[identity] == nil {
+			slots[identity] = make(map[uint16]bool)
+		}
+		slots[identity][clusterSlot(key)] = true
+	}
+
+	require.Len(t, slots, len(algorithms))
+	for identity, identitySlots := range slots {
+		assert.Len(t, identitySlots, 1, "Chaves de %s espalhadas em slots diferentes", identity)
+	}
+}
+
+func TestRedisStrategy_WorksWithClusterClient(t *testing.T) {
+	mr := miniredis.RunT(t)
+
+	// miniredis responde CLUSTER SLOTS como um cluster de um único nó
+	cluster := redis.NewClusterClient(&redis.ClusterOptions{Addrs: []string{mr.Addr()}})
+	defer cluster.Close()
+
+	strategy := limiter.NewRedisStrategy(cluster)
+	rl := limiter.NewRateLimiter(strategy)
+	ctx := context.Background()
+
+	require.NoError(t, strategy.LoadScripts(ctx))
+
+	config := limiter.LimitConfig{RPS: 2, BlockTime: time.Minute, Algorithm: limiter.TokenBucket}
+	for i := 0; i < 2; i++ {
+		result, err := rl.Check(ctx, "ip:10.0.0.1", config)
+		require.NoError(t, err)
+		assert.True(t, result.Allowed)
+	}
+
+	result, err := rl.Check(ctx, "ip:10.0.0.1", config)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed)
+
+	blocked, err := strategy.IsBlocked(ctx, "ip:10.0.0.1")
+	require.NoError(t, err)
+	assert.True(t, blocked)
+
+	// GetStats usa SCAN em todos os masters
+	_, err = rl.Check(ctx, "ip:10.0.0.2", limiter.LimitConfig{RPS: 5})
+	require.NoError(t, err)
+
+	stats, err := strategy.GetStats(ctx, "rate:{ip:10.0.0.2}")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"rate:{ip:10.0.0.2}": 1}, stats)
+}
+
+func TestNewRedisClient_Modes(t *testing.T) {
+	mr := miniredis.RunT(t)
+	host, port, err := net.SplitHostPort(mr.Addr())
+	require.NoError(t, err)
+
+	t.Run("single", func(t *testing.T) {
+		client, err := storage.NewRedisClient(&config.Config{RedisHost: host, RedisPort: port})
+		require.NoError(t, err)
+		defer client.Close()
+
+		_, ok := client.(*redis.Client)
+		assert.True(t, ok)
+	})
+
+	t.Run("cluster", func(t *testing.T) {
+		client, err := storage.NewRedisClient(&config.Config{RedisMode: "cluster", RedisAddrs: mr.Addr()})
+		require.NoError(t, err)
+		defer client.Close()
+
+		_, ok := client.(*redis.ClusterClient)
+		assert.True(t, ok)
+	})
+
+	t.Run("sentinel sem master", func(t *testing.T) {
+		_, err := storage.NewRedisClient(&config.Config{RedisMode: "sentinel", RedisAddrs: mr.Addr()})
+		assert.ErrorContains(t, err, "REDIS_MASTER_NAME")
+	})
+
+	t.Run("modo inválido", func(t *testing.T) {
+		_, err := storage.NewRedisClient(&config.Config{RedisMode: "mesh"})
+		assert.ErrorContains(t, err, "REDIS_MODE")
+	})
+}
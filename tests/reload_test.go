@@ -0,0 +1,212 @@
+package tests
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/config"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/limiter"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/middleware"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/reload"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/rules"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiterMiddleware_Reload(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{RateLimitIPRPS: 5, RateLimitTokenRPS: 10, RateLimitAlgorithm: "fixed_window"}
+
+	strategy, _ := newMiniRedisStrategy(t)
+	rl := limiter.NewRateLimiter(strategy)
+	rateLimiterMiddleware := middleware.NewRateLimiterMiddleware(rl, cfg)
+
+	router := gin.New()
+	router.Use(rateLimiterMiddleware.Middleware())
+	handler := func(c *gin.Context) { c.JSON(200, gin.H{"message": "ok"}) }
+	router.GET("/", handler)
+	router.GET("/search", handler)
+
+	w := performRequest(router, "GET", "/search", "")
+	assert.Equal(t, "5", w.Header().Get("X-RateLimit-Limit"))
+
+	engine, err := rules.Parse([]byte(`
+rules:
+  - name: search
+    path: /search
+    limits:
+      - { identity: ip, rps: 1 }
+`))
+	require.NoError(t, err)
+
+	updated := *cfg
+	updated.RateLimitIPRPS = 3
+	updated.RateLimitAlgorithm = " Token_Bucket "
+	updated.RedisHost = "redis-2"
+
+	changes, err := rateLimiterMiddleware.Reload(middleware.Limits{Config: &updated, Rules: engine})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{
+		"RATE_LIMIT_IP_RPS: 5 → 3",
+		"RATE_LIMIT_ALGORITHM: fixed_window → token_bucket",
+		"REDIS_HOST:  → redis-2 (requer reinício)",
+		"regra search adicionada",
+	}, changes)
+
+	// Os novos limites valem já na próxima requisição
+	w = performRequest(router, "GET", "/", "")
+	assert.Equal(t, "3", w.Header().Get("X-RateLimit-Limit"))
+	w = performRequest(router, "GET", "/search", "")
+	assert.Equal(t, "1", w.Header().Get("X-RateLimit-Limit"))
+	w = performRequest(router, "GET", "/search", "")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+
+	// Reaplicar a mesma configuração não muda nada
+	changes, err = rateLimiterMiddleware.Reload(middleware.Limits{Config: &updated, Rules: engine})
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+
+	// Configurações inválidas são rejeitadas e os limites atuais continuam valendo
+	invalid := updated
+	invalid.RateLimitAlgorithm = "magic"
+	_, err = rateLimiterMiddleware.Reload(middleware.Limits{Config: &invalid})
+	assert.ErrorContains(t, err, "algoritmo de rate limit desconhecido")
+
+	invalid = updated
+	invalid.RateLimitIPQuotas = "10/week"
+	_, err = rateLimiterMiddleware.Reload(middleware.Limits{Config: &invalid})
+	assert.ErrorContains(t, err, "RATE_LIMIT_IP_QUOTAS")
+
+	invalid = updated
+	invalid.RateLimitTokenRPS = 0
+	_, err = rateLimiterMiddleware.Reload(middleware.Limits{Config: &invalid})
+	assert.ErrorContains(t, err, "RATE_LIMIT_TOKEN_RPS: rps deve ser maior que zero")
+
+	w = performRequest(router, "GET", "/search", "")
+	assert.Equal(t, "1", w.Header().Get("X-RateLimit-Limit"), "A regra anterior deve continuar ativa")
+
+	// Remover o arquivo de regras também é uma mudança
+	changes, err = rateLimiterMiddleware.Reload(middleware.Limits{Config: &updated})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"regra search removida"}, changes)
+}
+
+func TestValidateRates(t *testing.T) {
+	assert.NoError(t, middleware.ValidateRates(&config.Config{RateLimitIPRPS: 1, RateLimitTokenRPS: 100}))
+
+	err := middleware.ValidateRates(&config.Config{RateLimitIPRPS: -1, RateLimitTokenRPS: 100})
+	assert.EqualError(t, err, "RATE_LIMIT_IP_RPS: rps deve ser maior que zero")
+
+	err = middleware.ValidateRates(&config.Config{RateLimitIPRPS: 10})
+	assert.EqualError(t, err, "RATE_LIMIT_TOKEN_RPS: rps deve ser maior que zero")
+
+	err = middleware.ValidateRates(&config.Config{RateLimitIPRPS: 10, RateLimitTokenRPS: 100, RateLimitGlobalRPS: -5})
+	assert.ErrorContains(t, err, "RATE_LIMIT_GLOBAL_RPS")
+}
+
+func TestConfigDiff(t *testing.T) {
+	old := &config.Config{RateLimitIPRPS: 10, RedisPassword: "a", RateLimitQuotaTimezone: "UTC"}
+	updated := &config.Config{RateLimitIPRPS: 10, RedisPassword: "b", RateLimitQuotaTimezone: "America/Sao_Paulo"}
+
+	assert.Equal(t, []string{
+		"RATE_LIMIT_QUOTA_TIMEZONE: UTC → America/Sao_Paulo (requer reinício)",
+		"REDIS_PASSWORD: alterada (requer reinício)", // Senhas não aparecem no log
+	}, config.Diff(old, updated))
+}
+
+func TestRulesDiff(t *testing.T) {
+	parse := func(content string) *rules.Engine {
+		engine, err := rules.Parse([]byte(content))
+		require.NoError(t, err)
+		return engine
+	}
+
+	old := parse(`
+rules:
+  - { name: a, path: /a, cost: 1 }
+  - { name: b, path: /b }
+  - { name: c, path: /c }
+`)
+
+	assert.Equal(t, []string{
+		"regra a alterada",
+		"regra d adicionada",
+		"regra c removida",
+	}, rules.Diff(old, parse(`
+rules:
+  - { name: a, path: /a, cost: 2 }
+  - { name: b, path: /b }
+  - { name: d, path: /d }
+`)))
+
+	assert.Equal(t, []string{"ordem das regras alterada: c, a, b"}, rules.Diff(old, parse(`
+rules:
+  - { name: c, path: /c }
+  - { name: a, path: /a, cost: 1 }
+  - { name: b, path: /b }
+`)))
+
+	assert.Empty(t, rules.Diff(nil, nil))
+	assert.Len(t, rules.Diff(nil, old), 3)
+}
+
+func TestReloadWatcher(t *testing.T) {
+	dir := t.TempDir()
+	rulesFile := filepath.Join(dir, "rules.yaml")
+	require.NoError(t, os.WriteFile(rulesFile, []byte("rules: []\n"), 0o600))
+
+	reasons := make(chan string, 10)
+	watcher, err := reload.NewWatcher([]string{rulesFile, ""}, func(reason string) {
+		reasons <- reason
+	}, reload.WithDebounce(20*time.Millisecond))
+	require.NoError(t, err)
+	defer watcher.Close()
+
+	// Outros arquivos do diretório não disparam reload
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "other.yaml"), []byte("x"), 0o600))
+	assertNoReload(t, reasons)
+
+	// Várias escritas seguidas geram um único reload
+	for i := 0; i < 3; i++ {
+		require.NoError(t, os.WriteFile(rulesFile, []byte("rules: []\n# edit\n"), 0o600))
+	}
+	assert.Equal(t, "rules.yaml alterado", waitReload(t, reasons))
+	assertNoReload(t, reasons)
+
+	// Salvamento atômico (arquivo temporário + rename), como fazem os editores
+	tmp := filepath.Join(dir, ".rules.yaml.tmp")
+	require.NoError(t, os.WriteFile(tmp, []byte("rules: []\n"), 0o600))
+	require.NoError(t, os.Rename(tmp, rulesFile))
+	assert.Equal(t, "rules.yaml alterado", waitReload(t, reasons))
+
+	process, err := os.FindProcess(os.Getpid())
+	require.NoError(t, err)
+	require.NoError(t, process.Signal(syscall.SIGHUP))
+	assert.Equal(t, "SIGHUP", waitReload(t, reasons))
+}
+
+func waitReload(t *testing.T, reasons <-chan string) string {
+	t.Helper()
+	select {
+	case reason := <-reasons:
+		return reason
+	case <-time.After(2 * time.Second):
+		t.Fatal("Reload não foi disparado")
+		return ""
+	}
+}
+
+func assertNoReload(t *testing.T, reasons <-chan string) {
+	t.Helper()
+	select {
+	case reason := <-reasons:
+		t.Fatalf("Reload inesperado: %s", reason)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,241 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/config"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/limiter"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/middleware"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/rules"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const rulesYAML = `
+identities:
+  tenant: { type: header, header: X-Tenant-ID }
+  user:   { type: claim, claim: sub }
+
+rules:
+  - name: search
+    methods: [GET]
+    path: /search
+    cost: 2
+    limits:
+      - identity: tenant
+        algorithm: token_bucket
+        rps: 20
+        burst: 40
+        block_time: 30s
+        quotas: 1000/day
+      - identity: ip
+        rps: 4
+        block_time: 5m
+
+  - name: account
+    path: /account/*rest
+    limits:
+      - identity: user
+        rps: 3
+        max_concurrent: 2
+`
+
+func TestRulesFile_LoadYAML(t *testing.T) {
+	path := writeRulesFile(t, "rules.yaml", rulesYAML)
+
+	engine, err := rules.LoadFile(path)
+	require.NoError(t, err)
+
+	rule, ok := engine.Match("GET", "/search")
+	require.True(t, ok)
+	assert.Equal(t, 2, rule.Cost)
+	require.Len(t, rule.Limits, 2)
+
+	tenant := rule.Limits[0]
+	assert.Equal(t, rules.Identity{Name: "tenant", Type: rules.IdentityHeader, Header: "X-Tenant-ID"}, tenant.Identity)
+	assert.Equal(t, limiter.LimitConfig{
+		RPS:       20,
+		Burst:     40,
+		BlockTime: 30 * time.Second,
+		Algorithm: limiter.TokenBucket,
+		Quotas:    []limiter.Quota{{Limit: 1000, Period: limiter.QuotaDay}},
+	}, tenant.Config)
+
+	// Algoritmo omitido vira o padrão
+	assert.Equal(t, rules.IPIdentity, rule.Limits[1].Identity)
+	assert.Equal(t, limiter.FixedWindow, rule.Limits[1].Config.Algorithm)
+	assert.Equal(t, 5*time.Minute, rule.Limits[1].Config.BlockTime)
+
+	rule, ok = engine.Match("POST", "/account/settings")
+	require.True(t, ok)
+	assert.Equal(t, rules.IdentityClaim, rule.Limits[0].Identity.Type)
+	assert.Equal(t, 2, rule.Limits[0].Config.MaxConcurrent)
+}
+
+func TestRulesFile_LoadJSON(t *testing.T) {
+	path := writeRulesFile(t, "rules.json", `{
+		"rules": [{
+			"name": "login",
+			"methods": ["POST"],
+			"path": "/login",
+			"limits": [{"identity": "ip", "rps": 1, "block_time": "10m"}]
+		}]
+	}`)
+
+	engine, err := rules.LoadFile(path)
+	require.NoError(t, err)
+
+	rule, ok := engine.Match("POST", "/login")
+	require.True(t, ok)
+	assert.Equal(t, 10*time.Minute, rule.Limits[0].Config.BlockTime)
+}
+
+func TestRulesFile_ValidationErrors(t *testing.T) {
+	cases := map[string]struct {
+		content  string
+		expected []string
+	}{
+		"campo desconhecido": {
+			content:  "rules:\n  - name: a\n    path: /\n    limit: []\n",
+			expected: []string{"field limit not found"},
+		},
+		"duração inválida": {
+			content:  "rules:\n  - name: a\n    path: /\n    limits:\n      - { identity: ip, rps: 1, block_time: 5 minutos }\n",
+			expected: []string{`linha 5: duração inválida "5 minutos"`},
+		},
+		"identidades inválidas": {
+			content: `
+identities:
+  ip: { type: header, header: X-IP }
+  tenant: { type: header }
+  user: { type: session }
+`,
+			expected: []string{
+				"identities.ip: nome reservado",
+				"identities.tenant: identidade \"tenant\": header é obrigatório",
+				"identities.user: identidade \"user\": tipo \"session\" inválido",
+			},
+		},
+		// Todos os erros são reportados de uma vez, com a regra e o campo
+		"vários erros": {
+			content: `
+rules:
+  - name: search
+    path: search
+    limits:
+      - { identity: tenant, rps: 5 }
+      - { identity: ip, rps: 5, quotas: 10/week }
+  - name: export
+    path: /export
+    limits:
+      - { identity: token, rps: 0 }
+  - name: export
+    path: /export2
+`,
+			expected: []string{
+				`regra 1 (search): limits[0]: identidade "tenant" não declarada`,
+				`regra 1 (search): limits[1]: quota inválido "10/week"`,
+				`regra 1 (search): path deve começar com /`,
+				`regra 2 (export): limits[0]: rps deve ser maior que zero`,
+				`regra 3 (export): nome "export" duplicado`,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			path := writeRulesFile(t, "rules.yaml", tc.content)
+
+			_, err := rules.LoadFile(path)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), path, "A mensagem deve apontar o arquivo")
+			for _, expected := range tc.expected {
+				assert.Contains(t, err.Error(), expected)
+			}
+		})
+	}
+
+	_, err := rules.LoadFile(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.ErrorContains(t, err, "erro ao ler arquivo de regras")
+}
+
+func TestRateLimiterMiddleware_RulesFileIdentities(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	engine, err := rules.Parse([]byte(rulesYAML))
+	require.NoError(t, err)
+
+	strategy, _ := newMiniRedisStrategy(t)
+	rl := limiter.NewRateLimiter(strategy)
+	rateLimiterMiddleware := middleware.NewRateLimiterMiddleware(rl, &config.Config{RateLimitIPRPS: 10}, middleware.WithRules(engine))
+
+	router := gin.New()
+	// Simula um middleware de autenticação que publica as claims do usuário
+	router.Use(func(c *gin.Context) {
+		if user := c.GetHeader("X-Test-User"); user != "" {
+			c.Set(middleware.ClaimsKey, map[string]any{"sub": user})
+		}
+	})
+	router.Use(rateLimiterMiddleware.Middleware())
+	handler := func(c *gin.Context) { c.JSON(200, gin.H{"message": "ok"}) }
+	router.GET("/search", handler)
+	router.GET("/account/*rest", handler)
+
+	request := func(path string, headers map[string]string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", path, nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Header do tenant tem precedência sobre o IP
+	w := request("/search", map[string]string{"X-Tenant-ID": "acme"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "20", w.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "38", w.Header().Get("X-RateLimit-Remaining"))
+
+	// Sem tenant, cai no limite por IP da regra (custo 2 em 4 rps)
+	w = request("/search", nil)
+	assert.Equal(t, "4", w.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "2", w.Header().Get("X-RateLimit-Remaining"))
+	w = request("/search", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = request("/search", nil)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+
+	// Outro tenant tem contador próprio
+	w = request("/search", map[string]string{"X-Tenant-ID": "globex"})
+	assert.Equal(t, "38", w.Header().Get("X-RateLimit-Remaining"))
+
+	// Identidade por claim: cada usuário tem seu limite
+	for i := 0; i < 3; i++ {
+		w = request("/account/me", map[string]string{"X-Test-User": "alice"})
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+	w = request("/account/me", map[string]string{"X-Test-User": "alice"})
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	w = request("/account/me", map[string]string{"X-Test-User": "bob"})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Sem a claim, vale o limite padrão de IP: omitir a identidade não livra do limite
+	w = request("/account/me", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "10", w.Header().Get("X-RateLimit-Limit"))
+}
+
+func writeRulesFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
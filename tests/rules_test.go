@@ -0,0 +1,143 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/config"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/limiter"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/middleware"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/rules"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRulesEngine_Match(t *testing.T) {
+	engine, err := rules.NewEngine([]rules.Rule{
+		{Name: "user-write", Methods: []string{"post", "PUT"}, Path: "/users/:id", Limits: ipLimit(limiter.LimitConfig{RPS: 1})},
+		{Name: "user-read", Path: "/users/:id", Limits: ipLimit(limiter.LimitConfig{RPS: 5})},
+		{Name: "user-posts", Path: "/users/:id/posts", Limits: ipLimit(limiter.LimitConfig{RPS: 3})},
+		{Name: "files", Path: "/files/*path", Limits: ipLimit(limiter.LimitConfig{RPS: 2})},
+		{Name: "root", Path: "/", Limits: ipLimit(limiter.LimitConfig{RPS: 50})},
+	})
+	require.NoError(t, err)
+
+	cases := []struct {
+		method, path, expected string
+	}{
+		{"POST", "/users/42", "user-write"},
+		{"GET", "/users/42", "user-read"},
+		{"GET", "/users/42/", "user-read"},
+		{"GET", "/users/42/posts", "user-posts"},
+		{"GET", "/users/:id", "user-read"}, // Template do Gin (c.FullPath) também casa
+		{"GET", "/files/a/b/c.txt", "files"},
+		{"GET", "/files", "files"},
+		{"GET", "/", "root"},
+		{"GET", "/users", ""},
+		{"GET", "/other", ""},
+	}
+
+	for _, tc := range cases {
+		rule, ok := engine.Match(tc.method, tc.path)
+		if tc.expected == "" {
+			assert.False(t, ok, "%s %s não deveria casar", tc.method, tc.path)
+			continue
+		}
+		if assert.True(t, ok, "%s %s deveria casar", tc.method, tc.path) {
+			assert.Equal(t, tc.expected, rule.Name, "%s %s", tc.method, tc.path)
+		}
+	}
+
+	// Engine nil (sem regras) nunca casa
+	var none *rules.Engine
+	_, ok := none.Match("GET", "/")
+	assert.False(t, ok)
+}
+
+func TestRulesEngine_Validation(t *testing.T) {
+	cases := map[string][]rules.Rule{
+		"nome vazio":          {{Path: "/"}},
+		"nome duplicado":      {{Name: "a", Path: "/a"}, {Name: "a", Path: "/b"}},
+		"nome com hash tag":   {{Name: "a{b}", Path: "/"}},
+		"path relativo":       {{Name: "a", Path: "users"}},
+		"curinga no meio":     {{Name: "a", Path: "/files/*path/x"}},
+		"parâmetro sem nome":  {{Name: "a", Path: "/users/:"}},
+		"método inválido":     {{Name: "a", Path: "/", Methods: []string{"FETCH"}}},
+		"rps zero":            {{Name: "a", Path: "/", Limits: []rules.Limit{{Identity: rules.TokenIdentity}}}},
+		"algoritmo inválido":  {{Name: "a", Path: "/", Limits: ipLimit(limiter.LimitConfig{RPS: 1, Algorithm: "magic"})}},
+		"custo negativo":      {{Name: "a", Path: "/", Cost: -1}},
+		"identidade sem tipo": {{Name: "a", Path: "/", Limits: []rules.Limit{{Identity: rules.Identity{Name: "x"}, Config: limiter.LimitConfig{RPS: 1}}}}},
+		"header sem nome":     {{Name: "a", Path: "/", Limits: []rules.Limit{{Identity: rules.Identity{Name: "x", Type: rules.IdentityHeader}, Config: limiter.LimitConfig{RPS: 1}}}}},
+	}
+
+	for name, ruleSet := range cases {
+		_, err := rules.NewEngine(ruleSet)
+		assert.Error(t, err, name)
+	}
+
+	// Algoritmo é normalizado na compilação
+	engine, err := rules.NewEngine([]rules.Rule{{Name: "a", Path: "/", Limits: ipLimit(limiter.LimitConfig{RPS: 1, Algorithm: " GCRA "})}})
+	require.NoError(t, err)
+	rule, _ := engine.Match("GET", "/")
+	assert.Equal(t, limiter.GCRA, rule.Limits[0].Config.Algorithm)
+}
+
+func TestRateLimiterMiddleware_PerRouteRules(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{
+		RateLimitIPRPS:    5,
+		RateLimitTokenRPS: 10,
+	}
+
+	engine, err := rules.NewEngine([]rules.Rule{
+		{Name: "search", Path: "/search", Limits: ipLimit(limiter.LimitConfig{RPS: 2})},
+		{Name: "users", Methods: []string{"GET"}, Path: "/users/:id"}, // Herda o limite padrão
+		{Name: "export", Path: "/export", Cost: 4},
+	})
+	require.NoError(t, err)
+
+	strategy, _ := newMiniRedisStrategy(t)
+	rl := limiter.NewRateLimiter(strategy)
+	rateLimiterMiddleware := middleware.NewRateLimiterMiddleware(rl, cfg, middleware.WithRules(engine))
+
+	router := gin.New()
+	router.Use(rateLimiterMiddleware.Middleware())
+	handler := func(c *gin.Context) { c.JSON(200, gin.H{"message": "ok"}) }
+	router.GET("/", handler)
+	router.GET("/search", handler)
+	router.GET("/users/:id", handler)
+	router.GET("/export", handler)
+
+	// /search tem limite próprio, mais apertado
+	for i := 0; i < 2; i++ {
+		w := performRequest(router, "GET", "/search", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "2", w.Header().Get("X-RateLimit-Limit"))
+	}
+	w := performRequest(router, "GET", "/search", "")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+
+	// A rota / continua com o limite padrão e contador separado
+	w = performRequest(router, "GET", "/", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "5", w.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "4", w.Header().Get("X-RateLimit-Remaining"))
+
+	// /users/:id herda o limite padrão, mas não compartilha contador com /
+	for _, id := range []string{"1", "2"} {
+		w = performRequest(router, "GET", "/users/"+id, "")
+		assert.Equal(t, "5", w.Header().Get("X-RateLimit-Limit"))
+	}
+	assert.Equal(t, "3", w.Header().Get("X-RateLimit-Remaining"), "Todas as URLs do template dividem o contador da regra")
+
+	// Custo da regra
+	w = performRequest(router, "GET", "/export", "")
+	assert.Equal(t, "1", w.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, "4", w.Header().Get("X-RateLimit-Cost"))
+}
+
+func ipLimit(config limiter.LimitConfig) []rules.Limit {
+	return []rules.Limit{{Identity: rules.IPIdentity, Config: config}}
+}
@@ -0,0 +1,236 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/admin"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/config"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/limiter"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/middleware"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/rules"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/tokens"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenHasher(t *testing.T) {
+	hasher := tokens.NewHasher("s3cr3t")
+	assert.True(t, hasher.Enabled())
+	assert.Len(t, hasher.Hash("abc123"), 32)
+	assert.Equal(t, hasher.Hash("abc123"), hasher.Hash("abc123"))
+	assert.NotEqual(t, hasher.Hash("abc123"), tokens.NewHasher("other").Hash("abc123"))
+
+	// Sem segredo, a chave padrão: o token nunca vai em texto puro
+	fallback := tokens.NewHasher("")
+	assert.True(t, fallback.Enabled())
+	assert.Len(t, fallback.Hash("abc123"), 32)
+	assert.Equal(t, fallback.Hash("abc123"), tokens.NewHasher("").Hash("abc123"), "Mesma chave em todas as instâncias")
+	assert.NotEqual(t, hasher.Hash("abc123"), fallback.Hash("abc123"))
+
+	// Hasher nil (biblioteca, sem WithTokenHasher): o token é usado como está
+	var none *tokens.Hasher
+	assert.False(t, none.Enabled())
+	assert.Equal(t, "abc123", none.Hash("abc123"))
+}
+
+func TestRateLimiterMiddleware_HashedTokenKeys(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+
+	engine, err := rules.Parse([]byte(`
+identities:
+  partner: { type: header, header: X-Partner-Key, secret: true }
+  tenant:  { type: header, header: X-Tenant-ID }
+rules:
+  - name: partners
+    path: /partners
+    limits:
+      - { identity: partner, rps: 5 }
+      - { identity: tenant, rps: 5 }
+`))
+	require.NoError(t, err)
+
+	hasher := tokens.NewHasher("s3cr3t")
+	rl := limiter.NewRateLimiter(limiter.NewRedisStrategy(rdb))
+	rateLimiterMiddleware := middleware.NewRateLimiterMiddleware(rl, &config.Config{RateLimitIPRPS: 5, RateLimitTokenRPS: 10},
+		middleware.WithRules(engine),
+		middleware.WithTokenHasher(hasher),
+	)
+
+	router := gin.New()
+	router.Use(rateLimiterMiddleware.Middleware())
+	handler := func(c *gin.Context) { c.JSON(200, gin.H{"message": "ok"}) }
+	router.GET("/", handler)
+	router.GET("/partners", handler)
+
+	request := func(path string, headers map[string]string) {
+		req, _ := http.NewRequest("GET", path, nil)
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+
+	request("/", map[string]string{"API_KEY": "sk_live_plaintext"})
+	request("/partners", map[string]string{"X-Partner-Key": "partner_plaintext"})
+	request("/partners", map[string]string{"X-Tenant-ID": "acme"})
+
+	keys := strings.Join(mr.Keys(), " ")
+	assert.NotContains(t, keys, "plaintext", "Tokens não aparecem nas chaves")
+	assert.Contains(t, keys, "rate:{token:"+hasher.Hash("sk_live_plaintext")+"}")
+	assert.Contains(t, keys, "rate:{partners:partner:"+hasher.Hash("partner_plaintext")+"}")
+	assert.Contains(t, keys, "rate:{partners:tenant:acme}", "Identidades sem secret continuam legíveis")
+}
+
+func TestTokenStore_Hashed(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+	ctx := context.Background()
+
+	hasher := tokens.NewHasher("s3cr3t")
+	store := tokens.NewStore(rdb, tokens.WithStoreHasher(hasher))
+
+	secret, record, err := store.Create(ctx, tokens.NewToken{Plan: "pro", Owner: "acme"})
+	require.NoError(t, err)
+	assert.Equal(t, hasher.Hash(secret), record.KeyHash)
+	assert.NotContains(t, strings.Join(mr.Keys(), " "), secret)
+
+	authenticated, err := store.Authenticate(ctx, secret)
+	require.NoError(t, err)
+	assert.Equal(t, record, authenticated)
+
+	// Com outro segredo (ou sem HMAC) o token não é encontrado
+	_, err = tokens.NewStore(rdb, tokens.WithStoreHasher(tokens.NewHasher("other"))).Authenticate(ctx, secret)
+	assert.ErrorIs(t, err, tokens.ErrTokenNotFound)
+	_, err = tokens.NewStore(rdb).Authenticate(ctx, secret)
+	assert.ErrorIs(t, err, tokens.ErrTokenNotFound)
+}
+
+func TestTokenRegistry_HashedRedisHash(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+	ctx := context.Background()
+
+	hasher := tokens.NewHasher("s3cr3t")
+	registry, err := tokens.Parse([]byte(plansYAML),
+		tokens.WithRedisHash(rdb, "ratelimit:tokens"),
+		tokens.WithHasher(hasher),
+	)
+	require.NoError(t, err)
+
+	mr.HSet("ratelimit:tokens", hasher.Hash("sk_test_upgraded"), "pro")
+	mr.HSet("ratelimit:tokens", "sk_test_plain", "enterprise") // Token sem HMAC não casa
+	mr.HSet("ratelimit:tokens", "acme_*", "enterprise")        // Prefixos não são consultados
+
+	cases := map[string]string{
+		"sk_test_upgraded": "pro",
+		"sk_test_plain":    "free", // Prefixo do arquivo
+		"acme_prod_1":      "",
+	}
+	for token, expected := range cases {
+		plan, ok, err := registry.Lookup(ctx, token)
+		require.NoError(t, err)
+		if expected == "" {
+			assert.False(t, ok, token)
+			continue
+		}
+		if assert.True(t, ok, token) {
+			assert.Equal(t, expected, plan.Name, token)
+		}
+	}
+}
+
+func TestAdminTokenAPI_Resolve(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	_, rdb := newMiniRedisStrategy(t)
+	hasher := tokens.NewHasher("s3cr3t")
+	store := tokens.NewStore(rdb, tokens.WithStoreHasher(hasher))
+
+	router := gin.New()
+	admin.NewTokenHandler(store, nil, hasher).Register(router.Group("/admin"), "admin-secret")
+
+	resolve := func(body string) (*httptest.ResponseRecorder, map[string]any) {
+		req, _ := http.NewRequest("POST", "/admin/tokens/resolve", strings.NewReader(body))
+		req.Header.Set("X-Admin-Key", "admin-secret")
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var response map[string]any
+		_ = json.Unmarshal(w.Body.Bytes(), &response)
+		return w, response
+	}
+
+	secret, record, err := store.Create(context.Background(), tokens.NewToken{Owner: "acme"})
+	require.NoError(t, err)
+
+	w, resolved := resolve(`{"token": "` + secret + `"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "token:"+record.KeyHash, resolved["key"])
+	assert.Equal(t, "*token:"+record.KeyHash+"[}:]*", resolved["pattern"])
+	assert.Equal(t, true, resolved["hashed"])
+	assert.Equal(t, record.ID, resolved["record"].(map[string]any)["id"])
+	assert.NotContains(t, w.Body.String(), secret)
+
+	// Tokens fora do cadastro (ex: planos por arquivo) também são resolvidos
+	w, resolved = resolve(`{"token": "sk_live_1"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "token:"+hasher.Hash("sk_live_1"), resolved["key"])
+	assert.Nil(t, resolved["record"])
+
+	w, _ = resolve(`{}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// O padrão encontra as chaves dos limites compostos e das regras, e só as do token
+	engine, err := rules.Parse([]byte("rules:\n  - name: payments\n    path: /payments\n"))
+	require.NoError(t, err)
+	cfg := &config.Config{
+		RateLimitIPRPS:      5,
+		RateLimitTokenRPS:   10,
+		RateLimitTokenIPRPS: 10,
+		RateLimitComposite:  "token_ip",
+		RateLimitAlgorithm:  "fixed_window",
+	}
+	rateLimiterMiddleware := middleware.NewRateLimiterMiddleware(limiter.NewRateLimiter(limiter.NewRedisStrategy(rdb)), cfg,
+		middleware.WithRules(engine),
+		middleware.WithTokenHasher(hasher),
+	)
+	limited := gin.New()
+	limited.Use(rateLimiterMiddleware.Middleware())
+	for _, path := range []string{"/test", "/payments"} {
+		limited.GET(path, func(c *gin.Context) { c.JSON(200, gin.H{"message": "ok"}) })
+	}
+	for _, token := range []string{secret, "sk_live_1"} {
+		for _, path := range []string{"/test", "/payments"} {
+			req, _ := http.NewRequest("GET", path, nil)
+			req.RemoteAddr = "10.0.0.1:1000"
+			req.Header.Set("API_KEY", token)
+			limited.ServeHTTP(httptest.NewRecorder(), req)
+		}
+	}
+
+	_, resolved = resolve(`{"token": "` + secret + `"}`)
+	keys, _, err := rdb.Scan(context.Background(), 0, resolved["pattern"].(string), 1000).Result()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{
+		"rate:{token:" + record.KeyHash + "}",
+		"rate:{token_ip:token:" + record.KeyHash + ":10.0.0.1}",
+		"rate:{payments:token:" + record.KeyHash + "}",
+	}, keys)
+}
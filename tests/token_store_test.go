@@ -0,0 +1,385 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/admin"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/config"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/limiter"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/middleware"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/rules"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/tokens"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenStore_Lifecycle(t *testing.T) {
+	_, rdb := newMiniRedisStrategy(t)
+	clock := newFakeClock()
+	store := tokens.NewStore(rdb, tokens.WithStoreClock(clock.Now))
+	ctx := context.Background()
+
+	expiresAt := clock.Now().Add(time.Hour)
+	secret, record, err := store.Create(ctx, tokens.NewToken{Plan: "pro", Owner: "acme", ExpiresAt: &expiresAt})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(secret, "rl_"))
+	assert.NotEmpty(t, record.ID)
+
+	authenticated, err := store.Authenticate(ctx, secret)
+	require.NoError(t, err)
+	assert.Equal(t, record, authenticated)
+
+	_, err = store.Authenticate(ctx, "rl_unknown")
+	assert.ErrorIs(t, err, tokens.ErrTokenNotFound)
+
+	plan := "enterprise"
+	updated, err := store.Update(ctx, record.ID, tokens.Update{Plan: &plan})
+	require.NoError(t, err)
+	assert.Equal(t, "enterprise", updated.Plan)
+	assert.Equal(t, "acme", updated.Owner)
+
+	// Expiração
+	clock.Advance(time.Hour)
+	_, err = store.Authenticate(ctx, secret)
+	assert.ErrorIs(t, err, tokens.ErrTokenExpired)
+
+	later := clock.Now().Add(24 * time.Hour)
+	_, err = store.Update(ctx, record.ID, tokens.Update{ExpiresAt: &later})
+	require.NoError(t, err)
+	_, err = store.Authenticate(ctx, secret)
+	require.NoError(t, err)
+
+	// Revogação vale na hora e o registro continua listado
+	revoked, err := store.Revoke(ctx, record.ID)
+	require.NoError(t, err)
+	assert.NotNil(t, revoked.RevokedAt)
+
+	_, err = store.Authenticate(ctx, secret)
+	assert.ErrorIs(t, err, tokens.ErrTokenRevoked)
+	_, err = store.Revoke(ctx, record.ID)
+	assert.ErrorIs(t, err, tokens.ErrTokenRevoked)
+
+	records, err := store.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, revoked, records[0])
+
+	_, err = store.Get(ctx, "missing")
+	assert.ErrorIs(t, err, tokens.ErrTokenNotFound)
+}
+
+func TestRateLimiterMiddleware_TokenStorePlanSkipsLookup(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	strategy, rdb := newMiniRedisStrategy(t)
+	store := tokens.NewStore(rdb)
+	ctx := context.Background()
+	withPlan, _, err := store.Create(ctx, tokens.NewToken{Plan: "pro", Owner: "acme"})
+	require.NoError(t, err)
+	withoutPlan, _, err := store.Create(ctx, tokens.NewToken{Owner: "acme"})
+	require.NoError(t, err)
+
+	// Só o hash de planos fica fora do ar
+	broken := miniredis.RunT(t)
+	broken.SetError("ERR boom")
+	plansClient := redis.NewClient(&redis.Options{Addr: broken.Addr()})
+	t.Cleanup(func() { plansClient.Close() })
+	lookups := &commandCounter{}
+	plansClient.AddHook(lookups)
+
+	registry, err := tokens.Parse([]byte(plansYAML), tokens.WithRedisHash(plansClient, "ratelimit:tokens"))
+	require.NoError(t, err)
+
+	cfg := &config.Config{RateLimitIPRPS: 5, RateLimitTokenRPS: 10, RateLimitAlgorithm: "fixed_window"}
+	rateLimiterMiddleware := middleware.NewRateLimiterMiddleware(limiter.NewRateLimiter(strategy), cfg,
+		middleware.WithTokenStore(store),
+		middleware.WithTokenRegistry(registry),
+	)
+
+	router := gin.New()
+	router.Use(rateLimiterMiddleware.Middleware())
+	router.GET("/test", func(c *gin.Context) { c.JSON(200, gin.H{"message": "ok"}) })
+
+	request := func(token string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/test", nil)
+		req.Header.Set("API_KEY", token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// O plano do cadastro vale sem consultar o hash de planos
+	w := request(withPlan)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "pro", w.Header().Get("X-RateLimit-Plan"))
+	assert.Equal(t, "100", w.Header().Get("X-RateLimit-Limit"))
+	assert.Zero(t, lookups.count.Load())
+
+	// Sem plano no cadastro, o hash é consultado; a falha (fail-open) deixa o limite padrão de token
+	w = request(withoutPlan)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("X-RateLimit-Plan"))
+	assert.Equal(t, "10", w.Header().Get("X-RateLimit-Limit"))
+	assert.NotZero(t, lookups.count.Load())
+}
+
+func TestTokenStore_UpdateKeepsConcurrentRevoke(t *testing.T) {
+	mr := miniredis.RunT(t)
+	revoker := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { revoker.Close() })
+	updater := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { updater.Close() })
+	ctx := context.Background()
+
+	secret, record, err := tokens.NewStore(revoker).Create(ctx, tokens.NewToken{Plan: "free", Owner: "acme"})
+	require.NoError(t, err)
+
+	// Outra instância revoga o token entre a leitura e a escrita do Update
+	revoke := &afterCommand{name: "hgetall", fn: func() {
+		_, err := tokens.NewStore(revoker).Revoke(ctx, record.ID)
+		require.NoError(t, err)
+	}}
+	updater.AddHook(revoke)
+
+	plan := "pro"
+	updated, err := tokens.NewStore(updater).Update(ctx, record.ID, tokens.Update{Plan: &plan})
+	require.NoError(t, err)
+	assert.True(t, revoke.done)
+	assert.Equal(t, "pro", updated.Plan)
+	assert.NotNil(t, updated.RevokedAt, "O registro retornado já inclui a revogação")
+
+	_, err = tokens.NewStore(revoker).Authenticate(ctx, secret)
+	assert.ErrorIs(t, err, tokens.ErrTokenRevoked, "O Update não desfaz a revogação")
+}
+
+// afterCommand chama fn uma vez, logo depois do primeiro comando name
+type afterCommand struct {
+	name string
+	fn   func()
+	done bool
+}
+
+func (h *afterCommand) DialHook(next redis.DialHook) redis.DialHook { return next }
+
+func (h *afterCommand) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		err := next(ctx, cmd)
+		if !h.done && cmd.Name() == h.name {
+			h.done = true
+			h.fn()
+		}
+		return err
+	}
+}
+
+func (h *afterCommand) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
+
+func TestAdminTokenAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	_, rdb := newMiniRedisStrategy(t)
+	store := tokens.NewStore(rdb)
+
+	router := gin.New()
+	handler := admin.NewTokenHandler(store, func(plan string) bool { return plan == "pro" }, nil)
+	handler.Register(router.Group("/admin"), "admin-secret")
+
+	call := func(method, path, body string) (*httptest.ResponseRecorder, map[string]any) {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-Admin-Key", "admin-secret")
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var response map[string]any
+		_ = json.Unmarshal(w.Body.Bytes(), &response)
+		return w, response
+	}
+
+	// Sem a chave de administração
+	req, _ := http.NewRequest("GET", "/admin/tokens", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w, _ = call("POST", "/admin/tokens", `{"owner": "acme", "plan": "gold"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code, "Plano inexistente")
+	w, _ = call("POST", "/admin/tokens", `{"plan": "pro"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code, "owner é obrigatório")
+	w, _ = call("POST", "/admin/tokens", `{"owner": "acme", "expires_in": "nunca"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w, created := call("POST", "/admin/tokens", `{"owner": "acme", "plan": "pro", "expires_in": "720h"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	secret := created["token"].(string)
+	id := created["id"].(string)
+	assert.Equal(t, "pro", created["plan"])
+	assert.NotEmpty(t, created["expires_at"])
+
+	_, err := store.Authenticate(context.Background(), secret)
+	require.NoError(t, err)
+
+	// O segredo não aparece em nenhuma outra resposta
+	w, _ = call("GET", "/admin/tokens", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), id)
+	assert.NotContains(t, w.Body.String(), secret)
+
+	w, fetched := call("GET", "/admin/tokens/"+id, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "acme", fetched["owner"])
+	assert.NotContains(t, w.Body.String(), secret)
+
+	w, patched := call("PATCH", "/admin/tokens/"+id, `{"owner": "acme-corp"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "acme-corp", patched["owner"])
+	assert.Equal(t, "pro", patched["plan"])
+
+	w, _ = call("PATCH", "/admin/tokens/"+id, `{"plan": "gold"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w, revoked := call("DELETE", "/admin/tokens/"+id, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEmpty(t, revoked["revoked_at"])
+
+	w, _ = call("DELETE", "/admin/tokens/"+id, "")
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w, _ = call("GET", "/admin/tokens/missing", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestRateLimiterMiddleware_InvalidTokenPolicy(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	registry, err := tokens.Parse([]byte("plans:\n  pro: { rps: 50 }\n"))
+	require.NoError(t, err)
+
+	newRouter := func(t *testing.T, policy string) (*gin.Engine, *tokens.Store) {
+		strategy, rdb := newMiniRedisStrategy(t)
+		store := tokens.NewStore(rdb)
+
+		cfg := &config.Config{RateLimitIPRPS: 5, RateLimitTokenRPS: 10, RateLimitInvalidTokenPolicy: policy}
+		rl := limiter.NewRateLimiter(strategy)
+		rateLimiterMiddleware := middleware.NewRateLimiterMiddleware(rl, cfg,
+			middleware.WithTokenStore(store),
+			middleware.WithTokenRegistry(registry),
+		)
+
+		router := gin.New()
+		router.Use(rateLimiterMiddleware.Middleware())
+		router.GET("/", func(c *gin.Context) { c.JSON(200, gin.H{"message": "ok"}) })
+		return router, store
+	}
+
+	request := func(router *gin.Engine, token string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("API_KEY", token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	cases := map[string]struct {
+		code  int
+		limit string
+	}{
+		"allow":  {http.StatusOK, "10"}, // Comportamento sem cadastro: limite de token
+		"ip":     {http.StatusOK, "5"},
+		"reject": {http.StatusUnauthorized, ""},
+	}
+
+	for policy, expected := range cases {
+		t.Run(policy, func(t *testing.T) {
+			router, store := newRouter(t, policy)
+			ctx := context.Background()
+
+			// Token válido recebe o plano do cadastro
+			secret, record, err := store.Create(ctx, tokens.NewToken{Plan: "pro", Owner: "acme"})
+			require.NoError(t, err)
+			w := request(router, secret)
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "50", w.Header().Get("X-RateLimit-Limit"))
+			assert.Equal(t, "pro", w.Header().Get("X-RateLimit-Plan"))
+
+			// Token desconhecido
+			w = request(router, "rl_made_up")
+			assert.Equal(t, expected.code, w.Code)
+			assert.Equal(t, expected.limit, w.Header().Get("X-RateLimit-Limit"))
+
+			// Token revogado segue a mesma política
+			_, err = store.Revoke(ctx, record.ID)
+			require.NoError(t, err)
+			w = request(router, secret)
+			assert.Equal(t, expected.code, w.Code)
+			assert.Equal(t, expected.limit, w.Header().Get("X-RateLimit-Limit"))
+
+			// Sem token, nada muda
+			w = request(router, "")
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "5", w.Header().Get("X-RateLimit-Limit"))
+		})
+	}
+
+	_, err = middleware.ParseInvalidTokenPolicy("block")
+	assert.Error(t, err)
+}
+
+func TestRateLimiterMiddleware_TokenStoreFailure(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	engine, err := rules.Parse([]byte(`
+rules:
+  - name: payments
+    path: /payments
+    on_error: closed
+`))
+	require.NoError(t, err)
+
+	// Só o cadastro de tokens fica fora do ar; o rate limiter continua respondendo
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+
+	cfg := &config.Config{RateLimitIPRPS: 5, RateLimitTokenRPS: 10, RateLimitAlgorithm: "fixed_window"}
+	rateLimiterMiddleware := middleware.NewRateLimiterMiddleware(limiter.NewRateLimiter(newMockStorage()), cfg,
+		middleware.WithTokenStore(tokens.NewStore(rdb)),
+		middleware.WithRules(engine),
+	)
+
+	router := gin.New()
+	router.Use(rateLimiterMiddleware.Middleware())
+	for _, path := range []string{"/test", "/payments"} {
+		router.GET(path, func(c *gin.Context) { c.JSON(200, gin.H{"message": "ok"}) })
+	}
+
+	request := func(path string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", path, nil)
+		req.Header.Set("API_KEY", "rl_unverified")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	mr.SetError("ERR boom")
+
+	// Fail-open: o token não validado não ganha o limite de token, vale o de IP
+	w := request("/test")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "5", w.Header().Get("X-RateLimit-Limit"))
+
+	// Fail-closed na rota: 503
+	w = request("/payments")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.JSONEq(t, `{"error": "rate limiter unavailable"}`, w.Body.String())
+}
@@ -0,0 +1,211 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/config"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/limiter"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/middleware"
+	"github.com/Guilherme-G-Cadilhe/Go-RateLimiter-Redis-Server-Api/internal/tokens"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const plansYAML = `
+plans:
+  free:       { rps: 10, block_time: 60s, quotas: 1000/day }
+  pro:        { rps: 100, burst: 200, algorithm: token_bucket, block_time: 10s }
+  enterprise: { rps: 1000 }
+tokens:
+  sk_live_vip: enterprise
+prefixes:
+  sk_test_: free
+  sk_live_: pro
+`
+
+func TestTokenRegistry_FileLookup(t *testing.T) {
+	registry, err := tokens.Parse([]byte(plansYAML))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	cases := map[string]string{
+		"sk_live_vip": "enterprise", // Token exato vence o prefixo
+		"sk_live_abc": "pro",
+		"sk_test_abc": "free",
+		"other":       "",
+		"":            "",
+	}
+	for token, expected := range cases {
+		plan, ok, err := registry.Lookup(ctx, token)
+		require.NoError(t, err)
+		if expected == "" {
+			assert.False(t, ok, token)
+			continue
+		}
+		if assert.True(t, ok, token) {
+			assert.Equal(t, expected, plan.Name, token)
+		}
+	}
+
+	plan, _, _ := registry.Lookup(ctx, "sk_test_abc")
+	assert.Equal(t, limiter.LimitConfig{
+		RPS:       10,
+		BlockTime: time.Minute,
+		Algorithm: limiter.FixedWindow,
+		Quotas:    []limiter.Quota{{Limit: 1000, Period: limiter.QuotaDay}},
+	}, plan.Limit)
+
+	// Registro nil (sem planos) nunca encontra plano
+	var none *tokens.Registry
+	_, ok, err := none.Lookup(ctx, "sk_live_abc")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestTokenRegistry_Validation(t *testing.T) {
+	_, err := tokens.Parse([]byte(`
+plans:
+  free: { rps: 0 }
+  pro:  { rps: 10, quotas: 5/week }
+tokens:
+  secret-token-123: gold
+prefixes:
+  sk_: silver
+`))
+	require.Error(t, err)
+
+	for _, expected := range []string{
+		"plans.free: rps deve ser maior que zero",
+		`plans.pro: quota inválido "5/week"`,
+		`tokens.secr****: plano "gold" não declarado`, // O token não aparece inteiro
+		`prefixes.sk_: plano "silver" não declarado`,
+	} {
+		assert.Contains(t, err.Error(), expected)
+	}
+	assert.NotContains(t, err.Error(), "secret-token-123")
+}
+
+func TestTokenRegistry_RedisHash(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+	ctx := context.Background()
+
+	registry, err := tokens.Parse([]byte(plansYAML), tokens.WithRedisHash(rdb, "ratelimit:tokens"))
+	require.NoError(t, err)
+
+	mr.HSet("ratelimit:tokens", "sk_test_upgraded", "pro")
+	mr.HSet("ratelimit:tokens", "acme_*", "enterprise")
+	mr.HSet("ratelimit:tokens", "acme_trial_*", "free")
+
+	cases := map[string]string{
+		"sk_test_upgraded": "pro",        // Redis tem precedência sobre o arquivo
+		"sk_test_other":    "free",       // Fora do Redis, vale o arquivo
+		"acme_prod_1":      "enterprise", // Prefixo no Redis
+		"acme_trial_1":     "free",       // Prefixo mais longo vence
+		"unknown":          "",
+	}
+	for token, expected := range cases {
+		plan, ok, err := registry.Lookup(ctx, token)
+		require.NoError(t, err)
+		if expected == "" {
+			assert.False(t, ok, token)
+			continue
+		}
+		if assert.True(t, ok, token) {
+			assert.Equal(t, expected, plan.Name, token)
+		}
+	}
+
+	// Associação a um plano inexistente é erro, não o limite padrão em silêncio
+	mr.HSet("ratelimit:tokens", "broken", "platinum")
+	_, _, err = registry.Lookup(ctx, "broken")
+	assert.ErrorIs(t, err, tokens.ErrUnknownPlan)
+	assert.ErrorContains(t, err, `"platinum"`)
+}
+
+func TestTokenRegistry_Diff(t *testing.T) {
+	old, err := tokens.Parse([]byte(plansYAML))
+	require.NoError(t, err)
+
+	updated, err := tokens.Parse([]byte(`
+plans:
+  free: { rps: 10, block_time: 60s, quotas: 1000/day }
+  pro:  { rps: 200 }
+tokens:
+  sk_live_vip: pro
+prefixes:
+  sk_test_: free
+`))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"plano pro alterado",
+		"plano enterprise removido",
+		"token sk_l**** → plano pro",
+		"prefixo sk_live_ removido",
+	}, tokens.Diff(old, updated))
+}
+
+func TestRateLimiterMiddleware_TokenPlans(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+
+	registry, err := tokens.Parse([]byte(`
+plans:
+  free: { rps: 2 }
+  pro:  { rps: 50 }
+prefixes:
+  sk_test_: free
+`), tokens.WithRedisHash(rdb, "ratelimit:tokens"))
+	require.NoError(t, err)
+	mr.HSet("ratelimit:tokens", "sk_live_1", "pro")
+
+	cfg := &config.Config{RateLimitIPRPS: 5, RateLimitTokenRPS: 10}
+	rl := limiter.NewRateLimiter(limiter.NewRedisStrategy(rdb))
+	rateLimiterMiddleware := middleware.NewRateLimiterMiddleware(rl, cfg, middleware.WithTokenRegistry(registry))
+
+	router := gin.New()
+	router.Use(rateLimiterMiddleware.Middleware())
+	router.GET("/", func(c *gin.Context) { c.JSON(200, gin.H{"message": "ok"}) })
+
+	request := func(token string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("API_KEY", token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request("sk_live_1")
+	assert.Equal(t, "50", w.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "pro", w.Header().Get("X-RateLimit-Plan"))
+
+	for i := 0; i < 2; i++ {
+		w = request("sk_test_1")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "free", w.Header().Get("X-RateLimit-Plan"))
+	}
+	w = request("sk_test_1")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+
+	// Token sem plano usa RATE_LIMIT_TOKEN_RPS
+	w = request("unknown")
+	assert.Equal(t, "10", w.Header().Get("X-RateLimit-Limit"))
+	assert.Empty(t, w.Header().Get("X-RateLimit-Plan"))
+
+	// O plano muda em tempo de execução, sem deploy nem reload
+	mr.HSet("ratelimit:tokens", "unknown", "pro")
+	w = request("unknown")
+	assert.Equal(t, "50", w.Header().Get("X-RateLimit-Limit"))
+}